@@ -0,0 +1,80 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/wmbryce/agent-c/app/store"
+	"github.com/wmbryce/agent-c/app/types"
+	"github.com/wmbryce/agent-c/app/utils"
+)
+
+// Locals keys set on requests authenticated with a consumer key
+const (
+	ConsumerIDLocalsKey  = "consumer_id"
+	ConsumerLocalsKey    = "consumer"
+	ConsumerKeyLocalsKey = "consumer_key"
+)
+
+// ConsumerKeyStore looks up the consumer keys issued by agent-c.
+type ConsumerKeyStore interface {
+	GetConsumerKeyByPrefix(ctx context.Context, prefix string) (*types.ConsumerKey, *types.Consumer, error)
+}
+
+// ConsumerKeyProtected func for specify routes group with consumer api key authentication.
+// The key is read from a Bearer Authorization header or, as Anthropic SDKs send it, from
+// x-api-key. Its scopes are checked by PermissionRequired.
+func ConsumerKeyProtected(keys ConsumerKeyStore) func(*fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		raw := consumerKeyFromRequest(c)
+		if raw == "" {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"error": true,
+				"msg":   "missing api key",
+			})
+		}
+
+		prefix, secret, ok := utils.ParseConsumerKey(raw)
+		if !ok {
+			return invalidConsumerKey(c)
+		}
+
+		key, consumer, err := keys.GetConsumerKeyByPrefix(c.UserContext(), prefix)
+		if errors.Is(err, store.ErrNotFound) {
+			return invalidConsumerKey(c)
+		}
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": true,
+				"msg":   "failed to check api key",
+			})
+		}
+		if key.RevokedAt != nil || !utils.ComparePasswords(key.KeyHash, secret) {
+			return invalidConsumerKey(c)
+		}
+
+		c.Locals(ConsumerIDLocalsKey, consumer.ID)
+		c.Locals(ConsumerLocalsKey, consumer)
+		c.Locals(ConsumerKeyLocalsKey, key)
+		return c.Next()
+	}
+}
+
+// consumerKeyFromRequest reads the api key from the Authorization or x-api-key header
+func consumerKeyFromRequest(c *fiber.Ctx) string {
+	if auth := c.Get(fiber.HeaderAuthorization); auth != "" {
+		if token, ok := strings.CutPrefix(auth, "Bearer "); ok {
+			return strings.TrimSpace(token)
+		}
+	}
+	return strings.TrimSpace(c.Get("x-api-key"))
+}
+
+func invalidConsumerKey(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+		"error": true,
+		"msg":   "invalid api key",
+	})
+}
@@ -14,5 +14,9 @@ func FiberMiddleware(a *fiber.App) {
 		cors.New(),
 		// Add simple logger.
 		logger.New(),
+		// Trace each request, continuing the caller's trace.
+		Tracing(),
+		// Count requests and their latency per route.
+		Metrics(),
 	)
 }
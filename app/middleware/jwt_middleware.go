@@ -1,19 +1,19 @@
 package middleware
 
 import (
-	"os"
-
 	"github.com/gofiber/fiber/v2"
+	"github.com/wmbryce/agent-c/app/utils"
 
 	jwtMiddleware "github.com/gofiber/contrib/jwt"
 )
 
 // JWTProtected func for specify routes group with JWT authentication.
+// Tokens are verified with the key of keys named by their kid header.
 // See: https://github.com/gofiber/contrib/jwt
-func JWTProtected() func(*fiber.Ctx) error {
+func JWTProtected(keys *utils.JWTKeySet) func(*fiber.Ctx) error {
 	// Create config for JWT authentication middleware.
 	config := jwtMiddleware.Config{
-		SigningKey:   jwtMiddleware.SigningKey{Key: []byte(os.Getenv("JWT_SECRET_KEY"))},
+		KeyFunc:      keys.Keyfunc,
 		ContextKey:   "jwt", // used in private routes
 		ErrorHandler: jwtError,
 	}
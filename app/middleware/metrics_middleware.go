@@ -0,0 +1,93 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"errors"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	httpRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "agc_http_requests_total",
+		Help: "HTTP requests served, by method, route and status code.",
+	}, []string{"method", "route", "status"})
+
+	httpRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "agc_http_request_duration_seconds",
+		Help:    "Time taken to serve HTTP requests, by method and route.",
+		Buckets: []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 120},
+	}, []string{"method", "route"})
+)
+
+// Metrics func for counting requests and their latency per route. Routes are labelled by
+// their pattern, such as /api/v1/admin/models/:id, so ids do not explode the label set.
+// Streamed responses are measured until their handler returns, not until the stream ends.
+func Metrics() func(*fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		started := time.Now()
+		err := c.Next()
+
+		status := responseStatus(c, err)
+		route := routePattern(c, status)
+
+		// Fiber reuses the method's buffer once the request is done, and labels outlive it
+		method := strings.Clone(c.Method())
+		httpRequests.WithLabelValues(method, route, strconv.Itoa(status)).Inc()
+		httpRequestDuration.WithLabelValues(method, route).Observe(time.Since(started).Seconds())
+		return err
+	}
+}
+
+// responseStatus returns the status a request is answered with. Errors returned by handlers
+// are only turned into a response after the middleware chain.
+func responseStatus(c *fiber.Ctx, err error) int {
+	var fiberErr *fiber.Error
+	if errors.As(err, &fiberErr) {
+		return fiberErr.Code
+	}
+	if err != nil {
+		return fiber.StatusInternalServerError
+	}
+	return c.Response().StatusCode()
+}
+
+// routePattern returns the pattern of the route that served a request, with every unmatched
+// path reported as unmatched rather than as the catch-all route
+func routePattern(c *fiber.Ctx, status int) string {
+	route := c.Route().Path
+	if status == fiber.StatusNotFound && route == "/" && c.Path() != "/" {
+		return "unmatched"
+	}
+	return route
+}
+
+// MetricsProtected func for guarding the Prometheus scrape endpoint with the bearer token in
+// METRICS_TOKEN. Metrics are unavailable while no token is configured.
+func MetricsProtected() func(*fiber.Ctx) error {
+	token := os.Getenv("METRICS_TOKEN")
+
+	return func(c *fiber.Ctx) error {
+		if token == "" {
+			return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
+				"error": true,
+				"msg":   "metrics are unavailable",
+			})
+		}
+
+		sent, ok := strings.CutPrefix(c.Get(fiber.HeaderAuthorization), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(strings.TrimSpace(sent)), []byte(token)) != 1 {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"error": true,
+				"msg":   "invalid metrics token",
+			})
+		}
+		return c.Next()
+	}
+}
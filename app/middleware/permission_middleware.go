@@ -0,0 +1,53 @@
+package middleware
+
+import (
+	"slices"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/wmbryce/agent-c/app/types"
+	"github.com/wmbryce/agent-c/app/utils"
+)
+
+// PermissionRequired func for restricting routes to requests granted every permission.
+// Mount it after JWTProtected, whose tokens carry the permissions of their roles, or after
+// ConsumerKeyProtected, whose keys carry their scopes.
+func PermissionRequired(permissions ...string) func(*fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		granted, ok := grantedPermissions(c)
+		if !ok {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"error": true,
+				"msg":   "missing or malformed credentials",
+			})
+		}
+
+		for _, permission := range permissions {
+			if !slices.Contains(granted, permission) {
+				return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+					"error": true,
+					"msg":   "missing the " + permission + " permission",
+				})
+			}
+		}
+
+		return c.Next()
+	}
+}
+
+// grantedPermissions returns the permissions of the request's consumer key or JWT
+func grantedPermissions(c *fiber.Ctx) ([]string, bool) {
+	if key, ok := c.Locals(ConsumerKeyLocalsKey).(*types.ConsumerKey); ok {
+		return key.Scopes, true
+	}
+
+	token, ok := c.Locals("jwt").(*jwt.Token)
+	if !ok {
+		return nil, false
+	}
+	claims, err := utils.TokenMetadataFromToken(token)
+	if err != nil {
+		return nil, false
+	}
+	return claims.Permissions, true
+}
@@ -0,0 +1,88 @@
+package middleware
+
+import (
+	"math"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/wmbryce/agent-c/app/types"
+)
+
+var rateLimited = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "agc_rate_limited_total",
+	Help: "Requests rejected by a rate limit, by scope (consumer, model or api_key) and unit (requests or tokens).",
+}, []string{"scope", "unit"})
+
+// RateLimiter counts requests and tokens against per-minute limits.
+type RateLimiter interface {
+	AllowRate(scope, id, unit string, limit, need, add int) (*types.RateLimitStatus, error)
+}
+
+// ConsumerRateLimited func for limiting the requests and tokens per minute of the consumer
+// authenticated by ConsumerKeyProtected. Requests are let through when the limiter is unavailable.
+func ConsumerRateLimited(limiter RateLimiter) func(*fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		consumer, ok := c.Locals(ConsumerLocalsKey).(*types.Consumer)
+		if !ok || limiter == nil {
+			return c.Next()
+		}
+
+		status, err := CheckRateLimit(limiter, types.RateLimitScopeConsumer, consumer.ID, consumer.RateLimit)
+		if err != nil || status == nil {
+			return c.Next()
+		}
+
+		SetRateLimitHeaders(c, status)
+		if !status.Allowed {
+			return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{
+				"error": true,
+				"msg":   "consumer rate limit exceeded",
+			})
+		}
+
+		return c.Next()
+	}
+}
+
+// CheckRateLimit counts a request against the limits of a consumer, model or seller key. Tokens
+// are only known once a call completes, so a request is admitted while the tokens counted in
+// the window are under the limit. It returns the status of the limit that rejected the request,
+// or else of the requests limit, and nil when neither limit is set.
+func CheckRateLimit(limiter RateLimiter, scope, id string, limit types.RateLimit) (*types.RateLimitStatus, error) {
+	var status *types.RateLimitStatus
+	var err error
+
+	if limit.TokensPerMinute != nil {
+		status, err = limiter.AllowRate(scope, id, types.RateLimitTokens, *limit.TokensPerMinute, 1, 0)
+		if err != nil {
+			return status, err
+		}
+		if !status.Allowed {
+			rateLimited.WithLabelValues(scope, types.RateLimitTokens).Inc()
+			return status, nil
+		}
+	}
+
+	if limit.RequestsPerMinute != nil {
+		status, err = limiter.AllowRate(scope, id, types.RateLimitRequests, *limit.RequestsPerMinute, 1, 1)
+		if err == nil && !status.Allowed {
+			rateLimited.WithLabelValues(scope, types.RateLimitRequests).Inc()
+		}
+	}
+	return status, err
+}
+
+// SetRateLimitHeaders reports a rate limit in RateLimit-Limit, RateLimit-Remaining and
+// RateLimit-Reset headers, with Retry-After when the request was rejected.
+func SetRateLimitHeaders(c *fiber.Ctx, status *types.RateLimitStatus) {
+	reset := strconv.Itoa(int(math.Ceil(status.Reset.Seconds())))
+
+	c.Set("RateLimit-Limit", strconv.Itoa(status.Limit))
+	c.Set("RateLimit-Remaining", strconv.Itoa(status.Remaining))
+	c.Set("RateLimit-Reset", reset)
+	if !status.Allowed {
+		c.Set(fiber.HeaderRetryAfter, reset)
+	}
+}
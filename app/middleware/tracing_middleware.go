@@ -0,0 +1,45 @@
+package middleware
+
+import (
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/wmbryce/agent-c/app/utils"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Tracing func for tracing each request in a server span, continuing the caller's trace when
+// it sends a traceparent header. Handlers reach the span through c.UserContext(), so the
+// queries and provider calls they make are traced beneath it. Streamed responses are traced
+// until their handler returns, not until the stream ends.
+func Tracing() func(*fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		// Fiber reuses its buffers once the request is done, and spans outlive them
+		method := strings.Clone(c.Method())
+
+		ctx := utils.TraceContext.Extract(c.UserContext(), propagation.HeaderCarrier(c.GetReqHeaders()))
+		ctx, span := utils.Tracer().Start(ctx, method, trace.WithSpanKind(trace.SpanKindServer), trace.WithAttributes(
+			semconv.HTTPRequestMethodKey.String(method),
+			semconv.URLPath(strings.Clone(c.Path())),
+		))
+		defer span.End()
+		c.SetUserContext(ctx)
+
+		err := c.Next()
+
+		status := responseStatus(c, err)
+		route := routePattern(c, status)
+		span.SetName(method + " " + route)
+		span.SetAttributes(semconv.HTTPRoute(route), semconv.HTTPResponseStatusCode(status))
+		if err != nil {
+			span.RecordError(err)
+		}
+		if status >= fiber.StatusInternalServerError {
+			span.SetStatus(codes.Error, "")
+		}
+		return err
+	}
+}
@@ -4,7 +4,11 @@ import (
 	_ "embed"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/adaptor"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/wmbryce/agent-c/app/middleware"
 	"github.com/wmbryce/agent-c/app/service"
+	"github.com/wmbryce/agent-c/app/types"
 	"github.com/yokeTH/gofiber-scalar/scalar/v2"
 )
 
@@ -13,17 +17,93 @@ var swaggerJSON string
 
 type Routes struct {
 	service *service.Service
+	// consumerKeys resolves the api keys consumers authenticate with
+	consumerKeys middleware.ConsumerKeyStore
+	// limiter enforces the consumers' rate limits
+	limiter middleware.RateLimiter
 }
 
-func New(svc *service.Service) *Routes {
-	return &Routes{service: svc}
+func New(svc *service.Service, consumerKeys middleware.ConsumerKeyStore, limiter middleware.RateLimiter) *Routes {
+	return &Routes{service: svc, consumerKeys: consumerKeys, limiter: limiter}
 }
 
 func (r *Routes) Setup(app *fiber.App) {
+	jwtProtected := middleware.JWTProtected(r.service.JWTKeys())
+
 	v1 := app.Group("api/v1")
 	v1.Get("/ai/models", r.service.GetModels)
-	v1.Post("/ai/models", r.service.CreateModel)
-	v1.Post("/ai/consume", r.service.ConsumeModel)
+	models := middleware.PermissionRequired(types.PermissionModelsWrite)
+	v1.Post("/ai/models", jwtProtected, models, r.service.CreateModel)
+	v1.Post("/ai/models/:id/prices", jwtProtected, models, r.service.CreateModelPrice)
+	v1.Get("/ai/providers/health", r.service.GetProvidersHealth)
+
+	// Consumer API, behind agent-c issued api keys and the consumer's rate limits
+	consumerKey := middleware.ConsumerKeyProtected(r.consumerKeys)
+	rateLimited := middleware.ConsumerRateLimited(r.limiter)
+	consume := middleware.PermissionRequired(types.PermissionConsume)
+	usage := middleware.PermissionRequired(types.PermissionUsageRead)
+	v1.Post("/ai/consume", consumerKey, consume, rateLimited, r.service.ConsumeModel)
+	v1.Get("/usage", consumerKey, usage, r.service.GetUsage)
+	v1.Get("/usage/daily", consumerKey, usage, r.service.GetDailyUsage)
+
+	// Sign-In with Ethereum for sellers, consumers and admins
+	v1.Post("/auth/nonce", r.service.CreateNonce)
+	v1.Post("/auth/login", r.service.Login)
+	v1.Post("/auth/refresh", r.service.Refresh)
+	v1.Post("/auth/logout", r.service.Logout)
+	v1.Get("/auth/sessions", jwtProtected, r.service.GetSessions)
+	v1.Delete("/auth/sessions/:id", jwtProtected, r.service.RevokeSession)
+
+	// Admin API for the provider registry, behind a JWT granting each route's permission
+	admin := v1.Group("/admin", jwtProtected)
+	providers := middleware.PermissionRequired(types.PermissionProvidersAdmin)
+	sellers := middleware.PermissionRequired(types.PermissionSellersAdmin)
+	consumers := middleware.PermissionRequired(types.PermissionConsumersAdmin)
+	admin.Put("/models/:id", models, r.service.UpdateModel)
+	admin.Delete("/models/:id", models, r.service.DeleteModel)
+	admin.Get("/providers", providers, r.service.GetProviders)
+	admin.Post("/providers", providers, r.service.CreateProvider)
+	admin.Get("/providers/:id", providers, r.service.GetProvider)
+	admin.Put("/providers/:id", providers, r.service.UpdateProvider)
+	admin.Delete("/providers/:id", providers, r.service.DeleteProvider)
+	admin.Get("/credentials_cache", providers, r.service.GetCredentialsCacheStats)
+	admin.Get("/schemas", providers, r.service.GetModelSchemas)
+	admin.Post("/schemas", providers, r.service.CreateModelSchema)
+	admin.Get("/schemas/:id", providers, r.service.GetModelSchema)
+	admin.Put("/schemas/:id", providers, r.service.UpdateModelSchema)
+	admin.Delete("/schemas/:id", providers, r.service.DeleteModelSchema)
+	admin.Get("/sellers", sellers, r.service.GetSellers)
+	admin.Post("/sellers", sellers, r.service.CreateSeller)
+	admin.Get("/sellers/:id", sellers, r.service.GetSeller)
+	admin.Put("/sellers/:id", sellers, r.service.UpdateSeller)
+	admin.Delete("/sellers/:id", sellers, r.service.DeleteSeller)
+	admin.Get("/api_keys", sellers, r.service.GetApiKeys)
+	admin.Post("/api_keys", sellers, r.service.CreateApiKey)
+	admin.Get("/api_keys/:id", sellers, r.service.GetApiKey)
+	admin.Put("/api_keys/:id", sellers, r.service.UpdateApiKey)
+	admin.Delete("/api_keys/:id", sellers, r.service.DeleteApiKey)
+	admin.Get("/consumers", consumers, r.service.GetConsumers)
+	admin.Post("/consumers", consumers, r.service.CreateConsumer)
+	admin.Get("/consumers/:id", consumers, r.service.GetConsumer)
+	admin.Put("/consumers/:id", consumers, r.service.UpdateConsumer)
+	admin.Delete("/consumers/:id", consumers, r.service.DeleteConsumer)
+	// Consumers' access tokens also grant usage:read, so reading everyone's usage takes
+	// consumers:admin as well
+	admin.Get("/usage", usage, consumers, r.service.GetUsage)
+	admin.Get("/usage/daily", usage, consumers, r.service.GetDailyUsage)
+	admin.Get("/consumers/:id/keys", consumers, r.service.GetConsumerKeys)
+	admin.Post("/consumers/:id/keys", consumers, r.service.CreateConsumerKey)
+	admin.Delete("/consumers/:id/keys/:key_id", consumers, r.service.RevokeConsumerKey)
+
+	// Provider-compatible facades, served at the paths their SDKs expect
+	compat := app.Group("v1")
+	compat.Post("/chat/completions", consumerKey, consume, rateLimited, r.service.ChatCompletions)
+	compat.Post("/messages", consumerKey, consume, rateLimited, r.service.Messages)
+
+	// Public keys other services verify access tokens with
+	app.Get("/.well-known/jwks.json", r.service.JWKS)
+	// Prometheus metrics, for scrapers holding the metrics token
+	app.Get("/metrics", middleware.MetricsProtected(), adaptor.HTTPHandler(promhttp.Handler()))
 	app.Get("/docs/*", scalar.New(scalar.Config{
 		Title:             "Agent-C API",
 		FileContentString: swaggerJSON,
@@ -0,0 +1,33 @@
+package service
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/wmbryce/agent-c/app/store"
+	"github.com/wmbryce/agent-c/app/utils"
+)
+
+// storeError responds to a failed admin store call. Missing rows, duplicates, rows still
+// in use and broken references are the caller's to fix, anything else is a server error.
+func storeError(c *fiber.Ctx, err error) error {
+	status := fiber.StatusInternalServerError
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		status = fiber.StatusNotFound
+	case errors.Is(err, store.ErrDuplicate), errors.Is(err, store.ErrInUse):
+		status = fiber.StatusConflict
+	case errors.Is(err, store.ErrInvalidReference):
+		status = fiber.StatusBadRequest
+	}
+
+	return c.Status(status).JSON(fiber.Map{
+		"error": true,
+		"msg":   err.Error(),
+	})
+}
+
+// validID reports whether the :id route parameter is a uuid
+func validID(c *fiber.Ctx) bool {
+	return utils.NewValidator().Var(c.Params("id"), "uuid") == nil
+}
@@ -0,0 +1,253 @@
+package service
+
+import (
+	"io"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/wmbryce/agent-c/app/types"
+	"github.com/wmbryce/agent-c/app/utils"
+)
+
+// Messages func serves the Anthropic Messages API on top of the model registry.
+// @Description Accept an Anthropic Messages request and return an Anthropic-shaped response, whichever provider serves the model.
+// @Description Requests are billed like consume requests. max_cost is optional and defaults to DEFAULT_MAX_COST.
+// @Summary Anthropic-compatible messages
+// @Tags Anthropic
+// @Accept json
+// @Produce json
+// @Param request body types.AnthropicMessagesRequest true "Messages request"
+// @Success 200 {object} types.AnthropicMessagesResponse
+// @Security ApiKeyAuth
+// @Router /v1/messages [post]
+func (s *Service) Messages(c *fiber.Ctx) error {
+	request := &types.AnthropicMessagesRequest{}
+	if err := c.BodyParser(request); err != nil {
+		return anthropicError(c, fiber.StatusBadRequest, err.Error())
+	}
+
+	validate := utils.NewValidator()
+	if err := validate.Struct(request); err != nil {
+		return anthropicError(c, fiber.StatusBadRequest, err.Error())
+	}
+
+	result, cerr := s.consume(c, anthropicConsumeRequest(request, s.defaultMaxCost))
+	if cerr != nil {
+		return anthropicError(c, cerr.status, cerr.msg)
+	}
+
+	if result.stream != nil {
+		return s.streamResponse(c, result, &anthropicStream{
+			id:    anthropicMessageID(""),
+			model: result.creds.ModelKey,
+		})
+	}
+
+	response := result.response
+	return c.JSON(types.AnthropicMessagesResponse{
+		ID:    anthropicMessageID(response.ID),
+		Type:  "message",
+		Role:  "assistant",
+		Model: result.creds.ModelKey,
+		Content: []types.AnthropicContentBlock{{
+			Type: "text",
+			Text: response.Content,
+		}},
+		StopReason: anthropicStopReason(response.FinishReason),
+		Usage: types.AnthropicUsage{
+			InputTokens:  response.PromptTokens,
+			OutputTokens: response.CompletionTokens,
+		},
+	})
+}
+
+// anthropicConsumeRequest converts an Anthropic messages request into a consume request,
+// moving the system prompt into a leading system message
+func anthropicConsumeRequest(request *types.AnthropicMessagesRequest, defaultMaxCost float64) *types.ConsumeModelRequest {
+	messages := make([]types.ChatMessage, 0, len(request.Messages)+1)
+	if system := request.System.Text(); system != "" {
+		messages = append(messages, types.ChatMessage{Role: "system", Content: system})
+	}
+	for _, message := range request.Messages {
+		messages = append(messages, types.ChatMessage{
+			Role:    message.Role,
+			Content: message.Content.Text(),
+		})
+	}
+
+	options := map[string]interface{}{
+		"max_tokens": request.MaxTokens,
+	}
+	if request.Temperature != nil {
+		options["temperature"] = *request.Temperature
+	}
+	if request.TopP != nil {
+		options["top_p"] = *request.TopP
+	}
+	if len(request.StopSequences) > 0 {
+		options["stop"] = request.StopSequences
+	}
+
+	return &types.ConsumeModelRequest{
+		ModelKey:       request.Model,
+		Messages:       messages,
+		Options:        options,
+		MaxCost:        maxCostOrDefault(request.MaxCost, defaultMaxCost),
+		Stream:         request.Stream,
+		FallbackModels: request.FallbackModels,
+	}
+}
+
+// anthropicStream writes a relayed stream as Anthropic message events. The message and
+// its single text block are opened before the first delta and closed when the stream ends.
+type anthropicStream struct {
+	id         string
+	model      string
+	started    bool
+	stopReason string
+}
+
+func (a *anthropicStream) start(w io.Writer) error {
+	if a.started {
+		return nil
+	}
+	a.started = true
+
+	err := utils.WriteSSE(w, "message_start", fiber.Map{
+		"type": "message_start",
+		"message": types.AnthropicMessagesResponse{
+			ID:      a.id,
+			Type:    "message",
+			Role:    "assistant",
+			Model:   a.model,
+			Content: []types.AnthropicContentBlock{},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	return utils.WriteSSE(w, "content_block_start", fiber.Map{
+		"type":          "content_block_start",
+		"index":         0,
+		"content_block": types.AnthropicContentBlock{Type: "text"},
+	})
+}
+
+func (a *anthropicStream) delta(w io.Writer, delta *types.GeneralChatResponse) error {
+	if err := a.start(w); err != nil {
+		return err
+	}
+	if delta.FinishReason != "" {
+		a.stopReason = delta.FinishReason
+	}
+	if delta.Content == "" {
+		return nil
+	}
+
+	return utils.WriteSSE(w, "content_block_delta", fiber.Map{
+		"type":  "content_block_delta",
+		"index": 0,
+		"delta": fiber.Map{
+			"type": "text_delta",
+			"text": delta.Content,
+		},
+	})
+}
+
+func (a *anthropicStream) done(w io.Writer, final *types.GeneralChatResponse) error {
+	if err := a.start(w); err != nil {
+		return err
+	}
+
+	stopReason := a.stopReason
+	if final.FinishReason != "" {
+		stopReason = final.FinishReason
+	}
+
+	events := []struct {
+		event   string
+		payload fiber.Map
+	}{
+		{"content_block_stop", fiber.Map{"type": "content_block_stop", "index": 0}},
+		// Usage is only known once the provider finishes, so input tokens are reported here too
+		{"message_delta", fiber.Map{
+			"type": "message_delta",
+			"delta": fiber.Map{
+				"stop_reason":   anthropicStopReason(stopReason),
+				"stop_sequence": nil,
+			},
+			"usage": types.AnthropicUsage{
+				InputTokens:  final.PromptTokens,
+				OutputTokens: final.CompletionTokens,
+			},
+		}},
+		{"message_stop", fiber.Map{"type": "message_stop"}},
+	}
+	for _, e := range events {
+		if err := utils.WriteSSE(w, e.event, e.payload); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (a *anthropicStream) fail(w io.Writer, msg string) error {
+	return utils.WriteSSE(w, "error", anthropicErrorBody(fiber.StatusBadGateway, msg))
+}
+
+// anthropicMessageID keeps a provider message id or generates one in Anthropic's format
+func anthropicMessageID(id string) string {
+	if id != "" {
+		return id
+	}
+	return "msg_" + strings.ReplaceAll(uuid.New().String(), "-", "")
+}
+
+// anthropicStopReason maps a provider finish reason to its Anthropic equivalent
+func anthropicStopReason(reason string) string {
+	switch reason {
+	case "", "stop", "STOP":
+		return "end_turn"
+	case "length", "MAX_TOKENS":
+		return "max_tokens"
+	case "tool_calls", "function_call":
+		return "tool_use"
+	default:
+		return reason
+	}
+}
+
+// anthropicError responds with an Anthropic-shaped error so SDKs can surface it
+func anthropicError(c *fiber.Ctx, status int, msg string) error {
+	return c.Status(status).JSON(anthropicErrorBody(status, msg))
+}
+
+func anthropicErrorBody(status int, msg string) fiber.Map {
+	errorType := "api_error"
+	switch status {
+	case fiber.StatusBadRequest:
+		errorType = "invalid_request_error"
+	case fiber.StatusUnauthorized:
+		errorType = "authentication_error"
+	case fiber.StatusPaymentRequired:
+		errorType = "billing_error"
+	case fiber.StatusForbidden:
+		errorType = "permission_error"
+	case fiber.StatusNotFound:
+		errorType = "not_found_error"
+	case fiber.StatusTooManyRequests:
+		errorType = "rate_limit_error"
+	case fiber.StatusServiceUnavailable:
+		errorType = "overloaded_error"
+	}
+
+	return fiber.Map{
+		"type": "error",
+		"error": fiber.Map{
+			"type":    errorType,
+			"message": msg,
+		},
+	}
+}
@@ -0,0 +1,213 @@
+package service
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/wmbryce/agent-c/app/types"
+	"github.com/wmbryce/agent-c/app/utils"
+)
+
+// CreateApiKey func adds an api key.
+// @Description Add a seller's provider api key. The key is encrypted at rest and never returned, only its fingerprint and last four characters; its starting balance is recorded in the token ledger.
+// @Summary create an api key
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Param key body types.ApiKey true "API key"
+// @Success 200 {object} types.ApiKey
+// @Security ApiKeyAuth
+// @Router /v1/admin/api_keys [post]
+func (s *Service) CreateApiKey(c *fiber.Ctx) error {
+	key := &types.ApiKey{}
+	if err := c.BodyParser(key); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": true,
+			"msg":   err.Error(),
+		})
+	}
+
+	validate := utils.NewValidator()
+	if err := validate.Struct(key); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": true,
+			"msg":   utils.ValidatorErrors(err),
+		})
+	}
+	if key.ApiKey == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": true,
+			"msg":   "api_key is required",
+		})
+	}
+
+	if err := s.sealApiKey(key); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": true,
+			"msg":   "failed to encrypt api key",
+		})
+	}
+
+	created, err := s.store.CreateApiKey(key)
+	if err != nil {
+		return storeError(c, err)
+	}
+	s.invalidateCredentials()
+
+	return c.JSON(fiber.Map{
+		"error":   false,
+		"msg":     "API key created successfully",
+		"api_key": created,
+	})
+}
+
+// GetApiKeys func returns every api key.
+// @Description List all api keys with their remaining tokens. Keys themselves are never returned.
+// @Summary list api keys
+// @Tags Admin
+// @Produce json
+// @Success 200 {array} types.ApiKey
+// @Security ApiKeyAuth
+// @Router /v1/admin/api_keys [get]
+func (s *Service) GetApiKeys(c *fiber.Ctx) error {
+	keys, err := s.store.GetApiKeys()
+	if err != nil {
+		return storeError(c, err)
+	}
+
+	return c.JSON(fiber.Map{
+		"error":    false,
+		"msg":      nil,
+		"api_keys": keys,
+	})
+}
+
+// GetApiKey func returns an api key by id.
+// @Description Get an api key by id.
+// @Summary get an api key
+// @Tags Admin
+// @Produce json
+// @Param id path string true "API key ID"
+// @Success 200 {object} types.ApiKey
+// @Security ApiKeyAuth
+// @Router /v1/admin/api_keys/{id} [get]
+func (s *Service) GetApiKey(c *fiber.Ctx) error {
+	if !validID(c) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": true,
+			"msg":   "invalid api key id",
+		})
+	}
+
+	key, err := s.store.GetApiKey(c.Params("id"))
+	if err != nil {
+		return storeError(c, err)
+	}
+
+	return c.JSON(fiber.Map{
+		"error":   false,
+		"msg":     nil,
+		"api_key": key,
+	})
+}
+
+// UpdateApiKey func replaces an api key.
+// @Description Replace an api key. Omit api_key to keep the stored key; balance changes are recorded in the token ledger.
+// @Summary update an api key
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Param id path string true "API key ID"
+// @Param key body types.ApiKey true "API key"
+// @Success 200 {object} types.ApiKey
+// @Security ApiKeyAuth
+// @Router /v1/admin/api_keys/{id} [put]
+func (s *Service) UpdateApiKey(c *fiber.Ctx) error {
+	key := &types.ApiKey{}
+	if err := c.BodyParser(key); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": true,
+			"msg":   err.Error(),
+		})
+	}
+
+	validate := utils.NewValidator()
+	if err := validate.Struct(key); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": true,
+			"msg":   utils.ValidatorErrors(err),
+		})
+	}
+	if !validID(c) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": true,
+			"msg":   "invalid api key id",
+		})
+	}
+	key.ID = c.Params("id")
+	if err := s.sealApiKey(key); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": true,
+			"msg":   "failed to encrypt api key",
+		})
+	}
+
+	updated, err := s.store.UpdateApiKey(key)
+	if err != nil {
+		return storeError(c, err)
+	}
+	s.invalidateCredentials()
+
+	return c.JSON(fiber.Map{
+		"error":   false,
+		"msg":     "API key updated successfully",
+		"api_key": updated,
+	})
+}
+
+// DeleteApiKey func removes an api key.
+// @Description Delete an api key.
+// @Summary delete an api key
+// @Tags Admin
+// @Produce json
+// @Param id path string true "API key ID"
+// @Success 200 {object} map[string]interface{}
+// @Security ApiKeyAuth
+// @Router /v1/admin/api_keys/{id} [delete]
+func (s *Service) DeleteApiKey(c *fiber.Ctx) error {
+	if !validID(c) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": true,
+			"msg":   "invalid api key id",
+		})
+	}
+
+	if err := s.store.DeleteApiKey(c.Params("id")); err != nil {
+		return storeError(c, err)
+	}
+	s.invalidateCredentials()
+
+	return c.JSON(fiber.Map{
+		"error": false,
+		"msg":   "API key deleted successfully",
+	})
+}
+
+// sealApiKey encrypts the plaintext key of an admin request and drops it, keeping only
+// the sealed key, its fingerprint and last four characters. Keys without plaintext are left unsealed.
+func (s *Service) sealApiKey(key *types.ApiKey) error {
+	key.Sealed, key.Fingerprint, key.Last4 = nil, "", ""
+	if key.ApiKey == "" {
+		return nil
+	}
+
+	sealed, err := s.keyRing.Seal(key.ApiKey)
+	if err != nil {
+		s.logger.Error().Err(err).Msg("failed to encrypt api key")
+		return err
+	}
+
+	key.Sealed = sealed
+	key.Fingerprint = utils.KeyFingerprint(key.ApiKey)
+	key.Last4 = utils.KeyLast4(key.ApiKey)
+	key.ApiKey = ""
+	return nil
+}
@@ -0,0 +1,523 @@
+package service
+
+import (
+	"errors"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/wmbryce/agent-c/app/store"
+	"github.com/wmbryce/agent-c/app/types"
+	"github.com/wmbryce/agent-c/app/utils"
+)
+
+// siweNonceTTL bounds how long a sign-in nonce can be used
+const siweNonceTTL = 10 * time.Minute
+
+// siweConfig holds what sign-in messages are checked against
+type siweConfig struct {
+	// domain the message must be issued for; sign-in is unavailable when it is not configured,
+	// since the request's Host header is chosen by the client and proves nothing
+	domain string
+	// chainID the message must be issued for, any chain when 0
+	chainID int64
+	// adminWallets are granted the admin role
+	adminWallets []string
+}
+
+// newSiweConfig reads the sign-in settings from SIWE_DOMAIN, SIWE_CHAIN_ID and ADMIN_WALLETS
+func newSiweConfig() siweConfig {
+	chainID, _ := strconv.ParseInt(os.Getenv("SIWE_CHAIN_ID"), 10, 64)
+
+	var admins []string
+	for _, wallet := range strings.Split(os.Getenv("ADMIN_WALLETS"), ",") {
+		if wallet = strings.TrimSpace(wallet); wallet != "" {
+			admins = append(admins, wallet)
+		}
+	}
+
+	return siweConfig{
+		domain:       os.Getenv("SIWE_DOMAIN"),
+		chainID:      chainID,
+		adminWallets: admins,
+	}
+}
+
+// CreateNonce func issues a nonce for a Sign-In with Ethereum message.
+// @Description Issue a single-use nonce to include in an EIP-4361 sign-in message. Nonces expire after 10 minutes.
+// @Summary issue a sign-in nonce
+// @Tags Auth
+// @Produce json
+// @Success 200 {object} types.SiweNonce
+// @Router /v1/auth/nonce [post]
+func (s *Service) CreateNonce(c *fiber.Ctx) error {
+	if !s.signInAvailable() {
+		return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
+			"error": true,
+			"msg":   "sign-in is unavailable",
+		})
+	}
+
+	nonce, err := utils.GenerateSiweNonce()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": true,
+			"msg":   err.Error(),
+		})
+	}
+	if err := s.cache.SaveNonce(nonce, siweNonceTTL); err != nil {
+		s.logger.Error().Err(err).Msg("failed to save sign-in nonce")
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": true,
+			"msg":   "failed to issue nonce",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"error": false,
+		"msg":   nil,
+		"nonce": types.SiweNonce{
+			Nonce:     nonce,
+			ExpiresAt: time.Now().Add(siweNonceTTL),
+		},
+	})
+}
+
+// Login func signs a wallet in with a signed Sign-In with Ethereum message.
+// @Description Verify a signed EIP-4361 message and issue access and refresh tokens.
+// @Description The access token carries the wallet's roles: seller and consumer for registered wallets, admin for configured admin wallets.
+// @Summary sign in with ethereum
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param login body types.SiweLoginRequest true "Signed sign-in message"
+// @Success 200 {object} types.AuthTokens
+// @Router /v1/auth/login [post]
+func (s *Service) Login(c *fiber.Ctx) error {
+	if !s.signInAvailable() {
+		return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
+			"error": true,
+			"msg":   "sign-in is unavailable",
+		})
+	}
+
+	request := &types.SiweLoginRequest{}
+	if err := c.BodyParser(request); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": true,
+			"msg":   err.Error(),
+		})
+	}
+
+	validate := utils.NewValidator()
+	if err := validate.Struct(request); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": true,
+			"msg":   utils.ValidatorErrors(err),
+		})
+	}
+
+	message, err := utils.ParseSiweMessage(request.Message)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": true,
+			"msg":   "invalid sign-in message: " + err.Error(),
+		})
+	}
+
+	if err := message.Validate(s.siwe.domain, s.siwe.chainID, time.Now()); err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": true,
+			"msg":   err.Error(),
+		})
+	}
+	if err := utils.VerifySiweSignature(request.Message, message.Address, request.Signature); err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": true,
+			"msg":   err.Error(),
+		})
+	}
+
+	subject, err := s.tokenSubject(message.Address)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": true,
+			"msg":   err.Error(),
+		})
+	}
+	if len(subject.Roles) == 0 {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": true,
+			"msg":   "wallet is not registered",
+		})
+	}
+
+	tokens, err := utils.GenerateNewTokens(s.jwtKeys, subject)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": true,
+			"msg":   err.Error(),
+		})
+	}
+
+	// Each nonce signs in once, so a captured message cannot be replayed. It is only used up
+	// once the tokens are issued, so a failure above lets the wallet retry the same message.
+	valid, err := s.cache.ConsumeNonce(message.Nonce)
+	if err != nil {
+		s.logger.Error().Err(err).Msg("failed to consume sign-in nonce")
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": true,
+			"msg":   "failed to check nonce",
+		})
+	}
+	if !valid {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": true,
+			"msg":   "invalid or expired nonce",
+		})
+	}
+
+	session, err := s.cache.CreateSession(&types.Session{
+		Wallet:    subject.Wallet,
+		UserAgent: c.Get(fiber.HeaderUserAgent),
+		IP:        c.IP(),
+	}, tokens.Refresh, refreshTTL(tokens.Refresh))
+	if err != nil {
+		s.logger.Error().Err(err).Msg("failed to create session")
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": true,
+			"msg":   "failed to create session",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"error": false,
+		"msg":   nil,
+		"tokens": types.AuthTokens{
+			Access:    tokens.Access,
+			Refresh:   tokens.Refresh,
+			SessionID: session.ID,
+			Wallet:    subject.Wallet,
+			Roles:     subject.Roles,
+		},
+	})
+}
+
+// Refresh func exchanges a refresh token for new tokens.
+// @Description Rotate a refresh token: the token is used up and a new access and refresh token are issued for the same session, with the wallet's current roles.
+// @Description Presenting a used refresh token again revokes its whole session.
+// @Summary refresh tokens
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param refresh body types.RefreshRequest true "Refresh token"
+// @Success 200 {object} types.AuthTokens
+// @Router /v1/auth/refresh [post]
+func (s *Service) Refresh(c *fiber.Ctx) error {
+	if s.cache == nil {
+		return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
+			"error": true,
+			"msg":   "sign-in is unavailable",
+		})
+	}
+
+	request := &types.RefreshRequest{}
+	if err := c.BodyParser(request); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": true,
+			"msg":   err.Error(),
+		})
+	}
+
+	validate := utils.NewValidator()
+	if err := validate.Struct(request); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": true,
+			"msg":   utils.ValidatorErrors(err),
+		})
+	}
+
+	if refreshTTL(request.RefreshToken) <= 0 {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": true,
+			"msg":   store.ErrRefreshTokenInvalid.Error(),
+		})
+	}
+
+	refresh, err := utils.GenerateNewRefreshToken()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": true,
+			"msg":   err.Error(),
+		})
+	}
+
+	session, err := s.cache.RotateRefreshToken(request.RefreshToken, refresh, refreshTTL(refresh))
+	if errors.Is(err, store.ErrRefreshTokenReused) {
+		s.logger.Warn().Msg("refresh token reuse detected, session revoked")
+	}
+	if errors.Is(err, store.ErrRefreshTokenInvalid) || errors.Is(err, store.ErrRefreshTokenReused) {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": true,
+			"msg":   err.Error(),
+		})
+	}
+	if err != nil {
+		s.logger.Error().Err(err).Msg("failed to rotate refresh token")
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": true,
+			"msg":   "failed to refresh tokens",
+		})
+	}
+
+	// Roles are looked up again so registry changes apply from the next refresh
+	subject, err := s.tokenSubject(session.Wallet)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": true,
+			"msg":   err.Error(),
+		})
+	}
+	if len(subject.Roles) == 0 {
+		s.cache.RevokeSession(session.Wallet, session.ID)
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": true,
+			"msg":   "wallet is not registered",
+		})
+	}
+
+	access, err := utils.GenerateNewAccessToken(s.jwtKeys, subject)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": true,
+			"msg":   err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"error": false,
+		"msg":   nil,
+		"tokens": types.AuthTokens{
+			Access:    access,
+			Refresh:   refresh,
+			SessionID: session.ID,
+			Wallet:    subject.Wallet,
+			Roles:     subject.Roles,
+		},
+	})
+}
+
+// Logout func ends the session of a refresh token.
+// @Description Revoke the session a refresh token belongs to. Access tokens already issued stay valid until they expire.
+// @Summary sign out
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param refresh body types.RefreshRequest true "Refresh token"
+// @Success 200 {object} map[string]interface{}
+// @Router /v1/auth/logout [post]
+func (s *Service) Logout(c *fiber.Ctx) error {
+	if s.cache == nil {
+		return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
+			"error": true,
+			"msg":   "sign-in is unavailable",
+		})
+	}
+
+	request := &types.RefreshRequest{}
+	if err := c.BodyParser(request); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": true,
+			"msg":   err.Error(),
+		})
+	}
+
+	validate := utils.NewValidator()
+	if err := validate.Struct(request); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": true,
+			"msg":   utils.ValidatorErrors(err),
+		})
+	}
+
+	err := s.cache.RevokeSessionByToken(request.RefreshToken)
+	if errors.Is(err, store.ErrRefreshTokenInvalid) {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": true,
+			"msg":   err.Error(),
+		})
+	}
+	if err != nil {
+		s.logger.Error().Err(err).Msg("failed to revoke session")
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": true,
+			"msg":   "failed to sign out",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"error": false,
+		"msg":   "Signed out successfully",
+	})
+}
+
+// GetSessions func returns the signed-in wallet's sessions.
+// @Description List the active sessions of the wallet the access token was issued to.
+// @Summary list sessions
+// @Tags Auth
+// @Produce json
+// @Success 200 {array} types.Session
+// @Security ApiKeyAuth
+// @Router /v1/auth/sessions [get]
+func (s *Service) GetSessions(c *fiber.Ctx) error {
+	claims, err := tokenMetadata(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": true,
+			"msg":   err.Error(),
+		})
+	}
+	if s.cache == nil {
+		return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
+			"error": true,
+			"msg":   "sign-in is unavailable",
+		})
+	}
+
+	sessions, err := s.cache.GetSessions(claims.Wallet)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": true,
+			"msg":   err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"error":    false,
+		"msg":      nil,
+		"sessions": sessions,
+	})
+}
+
+// RevokeSession func ends one of the signed-in wallet's sessions.
+// @Description Revoke a session of the wallet the access token was issued to, such as a lost device.
+// @Summary revoke a session
+// @Tags Auth
+// @Produce json
+// @Param id path string true "Session ID"
+// @Success 200 {object} map[string]interface{}
+// @Security ApiKeyAuth
+// @Router /v1/auth/sessions/{id} [delete]
+func (s *Service) RevokeSession(c *fiber.Ctx) error {
+	claims, err := tokenMetadata(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": true,
+			"msg":   err.Error(),
+		})
+	}
+	if s.cache == nil {
+		return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
+			"error": true,
+			"msg":   "sign-in is unavailable",
+		})
+	}
+
+	err = s.cache.RevokeSession(claims.Wallet, c.Params("id"))
+	if errors.Is(err, store.ErrSessionNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": true,
+			"msg":   err.Error(),
+		})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": true,
+			"msg":   err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"error": false,
+		"msg":   "Session revoked successfully",
+	})
+}
+
+// JWKS func serves the public keys access tokens are signed with.
+// @Description Get the JSON Web Key Set that verifies agent-c access tokens. Tokens name their key in the kid header.
+// @Summary get the token signing keys
+// @Tags Auth
+// @Produce json
+// @Success 200 {object} types.JWKS
+// @Router /.well-known/jwks.json [get]
+func (s *Service) JWKS(c *fiber.Ctx) error {
+	c.Set(fiber.HeaderCacheControl, "public, max-age=300")
+	return c.JSON(s.jwtKeys.JWKS())
+}
+
+// signInAvailable reports whether Sign-In with Ethereum is configured: nonces need Redis,
+// messages a SIWE_DOMAIN and tokens a signing key
+func (s *Service) signInAvailable() bool {
+	return s.cache != nil && s.siwe.domain != "" && s.jwtKeys != nil
+}
+
+// JWTKeys returns the keys access tokens are signed and verified with.
+func (s *Service) JWTKeys() *utils.JWTKeySet {
+	return s.jwtKeys
+}
+
+// refreshTTL returns how long a refresh token has left, zero or less when it is
+// malformed or expired
+func refreshTTL(refreshToken string) time.Duration {
+	expires, err := utils.ParseRefreshToken(refreshToken)
+	if err != nil {
+		return 0
+	}
+	return time.Until(time.Unix(expires, 0))
+}
+
+// tokenMetadata reads the claims of the JWT verified by middleware.JWTProtected
+func tokenMetadata(c *fiber.Ctx) (*utils.TokenMetadata, error) {
+	token, ok := c.Locals("jwt").(*jwt.Token)
+	if !ok {
+		return nil, errors.New("missing or malformed JWT")
+	}
+	return utils.TokenMetadataFromToken(token)
+}
+
+// tokenSubject collects the roles a wallet holds, seller and consumer when it is registered
+// as one and admin when it is a configured admin wallet, and the permissions they grant
+func (s *Service) tokenSubject(wallet string) (*utils.TokenSubject, error) {
+	subject := &utils.TokenSubject{Wallet: wallet, Roles: []string{}}
+
+	seller, err := s.store.GetSellerByWallet(wallet)
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		return nil, err
+	}
+	if seller != nil {
+		subject.Roles = append(subject.Roles, types.RoleSeller)
+		subject.SellerID = seller.ID
+	}
+
+	consumer, err := s.store.GetConsumerByWallet(wallet)
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		return nil, err
+	}
+	if consumer != nil {
+		subject.Roles = append(subject.Roles, types.RoleConsumer)
+		subject.ConsumerID = consumer.ID
+	}
+
+	for _, admin := range s.siwe.adminWallets {
+		if strings.EqualFold(admin, wallet) {
+			subject.Roles = append(subject.Roles, types.RoleAdmin)
+			break
+		}
+	}
+
+	subject.Permissions = types.PermissionsForRoles(subject.Roles)
+	return subject, nil
+}
@@ -0,0 +1,68 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/wmbryce/agent-c/app/types"
+	"github.com/wmbryce/agent-c/app/utils"
+)
+
+// consumeCall holds the billing state of a single consume request
+type consumeCall struct {
+	// ctx carries the request's trace to the queries that complete the call
+	ctx         context.Context
+	price       *types.ModelPrice
+	provider    string
+	reservation *types.TokenReservation
+	usage       *types.UsageRecord
+	started     time.Time
+	// promptTokens is the estimated prompt, charged when the provider's usage never arrives
+	promptTokens int
+	// tokenLimits are the tokens per minute limits the call's tokens count against
+	tokenLimits []tokenLimit
+	// detached is set once the stream writer owns completing the call
+	detached bool
+	done     bool
+}
+
+// completeCall prices the response, settles the reservation at the tokens the provider
+// reported and records the call's usage. A nil response releases the reservation in full.
+func (s *Service) completeCall(call *consumeCall, response *types.GeneralChatResponse) {
+	if call.done {
+		return
+	}
+	call.done = true
+
+	if response != nil {
+		response.Cost = utils.CalculateCost(call.price, response)
+		s.settleTokens(call.ctx, call.reservation, response.TotalTokens)
+		s.countTokens(call.tokenLimits, response.TotalTokens)
+	} else {
+		s.releaseTokens(call.ctx, call.reservation)
+	}
+	observeCall(call, response)
+
+	s.recordUsage(call.ctx, call.usage, call.started, response)
+}
+
+// settleTokens charges the reservation for the tokens the provider actually used
+func (s *Service) settleTokens(ctx context.Context, reservation *types.TokenReservation, tokens int) {
+	if _, err := s.store.SettleTokens(ctx, reservation.ID, tokens); err != nil {
+		s.logger.Error().
+			Err(err).
+			Str("reservation_id", reservation.ID).
+			Int("tokens", tokens).
+			Msg("failed to settle token reservation")
+	}
+}
+
+// releaseTokens refunds a reservation in full when no upstream usage was incurred
+func (s *Service) releaseTokens(ctx context.Context, reservation *types.TokenReservation) {
+	if err := s.store.ReleaseTokens(ctx, reservation.ID); err != nil {
+		s.logger.Error().
+			Err(err).
+			Str("reservation_id", reservation.ID).
+			Msg("failed to release token reservation")
+	}
+}
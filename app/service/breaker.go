@@ -0,0 +1,239 @@
+package service
+
+import (
+	"errors"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/wmbryce/agent-c/app/types"
+)
+
+// errCircuitOpen is returned when a provider's circuit rejects a call
+var errCircuitOpen = errors.New("provider circuit is open")
+
+// defaultCircuitBreaker applies to providers without a circuit_breaker config and fills unset fields
+var defaultCircuitBreaker = types.CircuitBreakerConfig{
+	FailureThreshold: 5,
+	OpenDurationMs:   30000,
+	HalfOpenRequests: 1,
+	WindowSize:       100,
+}
+
+// callSample is the outcome of one upstream call, kept for health reporting
+type callSample struct {
+	failed  bool
+	latency time.Duration
+}
+
+// circuitBreaker tracks one provider. It opens after FailureThreshold consecutive
+// failures, rejects calls for OpenDuration, then lets HalfOpenRequests probes through:
+// a successful probe closes the circuit and a failed one opens it again.
+type circuitBreaker struct {
+	mu       sync.Mutex
+	config   types.CircuitBreakerConfig
+	state    string
+	failures int
+	openedAt time.Time
+	probes   int
+	samples  []callSample
+	next     int
+	drift    int
+}
+
+func newCircuitBreaker(config types.CircuitBreakerConfig) *circuitBreaker {
+	return &circuitBreaker{config: config, state: types.CircuitClosed}
+}
+
+// ready reports whether a call could currently be attempted, without taking a probe slot
+func (b *circuitBreaker) ready() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.advance()
+	return b.state != types.CircuitOpen &&
+		(b.state != types.CircuitHalfOpen || b.probes < b.config.HalfOpenRequests)
+}
+
+// allow admits a call, taking a probe slot while half-open. Every admitted call must be recorded.
+func (b *circuitBreaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.advance()
+	switch b.state {
+	case types.CircuitOpen:
+		return false
+	case types.CircuitHalfOpen:
+		if b.probes >= b.config.HalfOpenRequests {
+			return false
+		}
+		b.probes++
+	}
+	return true
+}
+
+// record stores the outcome of an admitted call and moves the circuit between states
+func (b *circuitBreaker) record(failed bool, latency time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if len(b.samples) < b.config.WindowSize {
+		b.samples = append(b.samples, callSample{failed: failed, latency: latency})
+	} else {
+		b.samples[b.next] = callSample{failed: failed, latency: latency}
+		b.next = (b.next + 1) % b.config.WindowSize
+	}
+
+	if b.state == types.CircuitHalfOpen {
+		b.probes = max(b.probes-1, 0)
+		if failed {
+			b.trip()
+		} else {
+			b.state = types.CircuitClosed
+			b.failures = 0
+		}
+		return
+	}
+
+	if !failed {
+		b.failures = 0
+		return
+	}
+	b.failures++
+	if b.state == types.CircuitClosed && b.failures >= b.config.FailureThreshold {
+		b.trip()
+	}
+}
+
+// recordDrift counts a response that did not match its response schema. Drift is reported
+// in the provider's health but does not move the circuit, since the call itself succeeded.
+func (b *circuitBreaker) recordDrift() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.drift++
+}
+
+func (b *circuitBreaker) trip() {
+	b.state = types.CircuitOpen
+	b.openedAt = time.Now()
+	b.probes = 0
+}
+
+// advance moves an open circuit to half-open once its open duration has passed
+func (b *circuitBreaker) advance() {
+	openFor := time.Duration(b.config.OpenDurationMs) * time.Millisecond
+	if b.state == types.CircuitOpen && time.Since(b.openedAt) >= openFor {
+		b.state = types.CircuitHalfOpen
+		b.probes = 0
+	}
+}
+
+func (b *circuitBreaker) health(provider string) types.ProviderHealth {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.advance()
+	health := types.ProviderHealth{
+		Provider:            provider,
+		State:               b.state,
+		ConsecutiveFailures: b.failures,
+		RecentRequests:      len(b.samples),
+		SchemaDrift:         b.drift,
+	}
+	if b.state != types.CircuitClosed {
+		openedAt := b.openedAt
+		health.OpenedAt = &openedAt
+	}
+	if len(b.samples) == 0 {
+		return health
+	}
+
+	failed := 0
+	var total time.Duration
+	latencies := make([]time.Duration, 0, len(b.samples))
+	for _, s := range b.samples {
+		if s.failed {
+			failed++
+		}
+		total += s.latency
+		latencies = append(latencies, s.latency)
+	}
+	slices.Sort(latencies)
+
+	health.ErrorRate = float64(failed) / float64(len(b.samples))
+	health.AvgLatencyMs = (total / time.Duration(len(b.samples))).Milliseconds()
+	health.P95LatencyMs = latencies[(len(latencies)*95-1)/100].Milliseconds()
+	return health
+}
+
+// circuitBreakers holds one breaker per provider, created on first use
+type circuitBreakers struct {
+	mu       sync.Mutex
+	breakers map[string]*circuitBreaker
+}
+
+func newCircuitBreakers() *circuitBreakers {
+	return &circuitBreakers{breakers: make(map[string]*circuitBreaker)}
+}
+
+// get returns the provider's breaker, applying the latest thresholds from its config
+func (c *circuitBreakers) get(provider string, providerConfig *types.ProviderConfig) *circuitBreaker {
+	config := defaultCircuitBreaker
+	if providerConfig != nil && providerConfig.CircuitBreaker != nil {
+		if providerConfig.CircuitBreaker.FailureThreshold > 0 {
+			config.FailureThreshold = providerConfig.CircuitBreaker.FailureThreshold
+		}
+		if providerConfig.CircuitBreaker.OpenDurationMs > 0 {
+			config.OpenDurationMs = providerConfig.CircuitBreaker.OpenDurationMs
+		}
+		if providerConfig.CircuitBreaker.HalfOpenRequests > 0 {
+			config.HalfOpenRequests = providerConfig.CircuitBreaker.HalfOpenRequests
+		}
+		if providerConfig.CircuitBreaker.WindowSize > 0 {
+			config.WindowSize = providerConfig.CircuitBreaker.WindowSize
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	b, ok := c.breakers[provider]
+	if !ok {
+		b = newCircuitBreaker(config)
+		c.breakers[provider] = b
+		return b
+	}
+
+	b.mu.Lock()
+	if config.WindowSize != b.config.WindowSize {
+		b.samples, b.next = nil, 0
+	}
+	b.config = config
+	b.mu.Unlock()
+
+	return b
+}
+
+// health reports every provider that has been called since startup, sorted by name
+func (c *circuitBreakers) health() []types.ProviderHealth {
+	c.mu.Lock()
+	providers := make([]string, 0, len(c.breakers))
+	for provider := range c.breakers {
+		providers = append(providers, provider)
+	}
+	sort.Strings(providers)
+	breakers := make([]*circuitBreaker, len(providers))
+	for i, provider := range providers {
+		breakers[i] = c.breakers[provider]
+	}
+	c.mu.Unlock()
+
+	health := make([]types.ProviderHealth, len(providers))
+	for i, b := range breakers {
+		health[i] = b.health(providers[i])
+	}
+	return health
+}
@@ -2,17 +2,67 @@ package service
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
 	"net/http"
+	"os"
+	"strconv"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/wmbryce/agent-c/app/middleware"
+	"github.com/wmbryce/agent-c/app/store"
 	"github.com/wmbryce/agent-c/app/types"
 	"github.com/wmbryce/agent-c/app/utils"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
+// consumeError describes why a consume request failed and the status to report it with
+type consumeError struct {
+	status int
+	msg    string
+	// failover is set when another seller key may still serve the request
+	failover bool
+	// fallback is set when another model may still serve the request
+	fallback bool
+	// violations lists the options that do not satisfy the model's options schema
+	violations []utils.SchemaViolation
+	// rateLimit is the limit that rejected the request
+	rateLimit *types.RateLimitStatus
+	// spent is the cost already charged for the failed attempts, which fallbacks count against max_cost
+	spent float64
+}
+
+func (e *consumeError) Error() string {
+	return e.msg
+}
+
+// consumeResult is a successful provider response. Either response is set and the call
+// is already completed, or stream holds the open provider body and the call is detached.
+// Responses served from the response cache are cached and have no call.
+type consumeResult struct {
+	creds    *types.ModelCredentials
+	call     *consumeCall
+	response *types.GeneralChatResponse
+	stream   *http.Response
+	cached   bool
+}
+
 // ConsumeModel func sends a request to the AI model provider.
 // @Description Send a consume model request to the AI provider.
+// @Description Set stream to true to receive the completion as server-sent delta events followed by a usage event.
+// @Description On provider errors the fallback_models, or else the model's default fallback chain, are tried in order.
+// @Description The model that served the request is returned as model_key and in the X-Model-Key header.
+// @Description Options are validated against the model's options schema, whose defaults are applied; violations are listed by path.
+// @Description Requests with temperature 0, or with cache set, are served from the response cache when an identical request was answered before.
+// @Description Cache hits are free and reported as cached and in the X-Cache header; send Cache-Control: no-cache to bypass the cache.
 // @Summary consume an AI model
 // @Tags AI
 // @Accept json
@@ -38,74 +88,333 @@ func (s *Service) ConsumeModel(c *fiber.Ctx) error {
 		})
 	}
 
-	// Get model credentials (endpoint URL and API key) in one query
-	creds, err := s.store.GetModelCredentials(request.ModelKey)
-	if err != nil {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
-			"error": true,
-			"msg":   "model not found or no API key available",
+	result, cerr := s.consume(c, request)
+	if cerr != nil && cerr.violations != nil {
+		return c.Status(cerr.status).JSON(fiber.Map{
+			"error":      true,
+			"msg":        cerr.msg,
+			"violations": cerr.violations,
 		})
 	}
-
-	// Check if tokens available cover the max cost
-	if float64(creds.TokensAvailable) < request.MaxCost {
-		return c.Status(fiber.StatusPaymentRequired).JSON(fiber.Map{
+	if cerr != nil {
+		return c.Status(cerr.status).JSON(fiber.Map{
 			"error": true,
-			"msg":   "insufficient tokens available",
+			"msg":   cerr.msg,
 		})
 	}
 
-	// Build the request payload for the provider
-	providerRequest := map[string]interface{}{
-		"model":    request.ModelKey,
-		"messages": request.Messages,
+	if result.stream != nil {
+		return s.streamResponse(c, result, nativeStream{})
 	}
-	for k, v := range request.Options {
-		providerRequest[k] = v
+
+	return c.JSON(fiber.Map{
+		"error":     false,
+		"msg":       nil,
+		"model_key": result.creds.ModelKey,
+		"cached":    result.cached,
+		"response":  result.response,
+	})
+}
+
+// consume sends a validated request to the requested model, then to each fallback model
+// in turn when the model cannot serve it. Fallbacks come from the request, or else from
+// the requested model's default chain.
+func (s *Service) consume(c *fiber.Ctx, request *types.ConsumeModelRequest) (result *consumeResult, cerr *consumeError) {
+	// Tell rate limited callers when to retry
+	defer func() {
+		if cerr != nil && cerr.rateLimit != nil {
+			middleware.SetRateLimitHeaders(c, cerr.rateLimit)
+		}
+	}()
+
+	result, cerr, defaults := s.consumeModel(c, request, request.ModelKey)
+	if cerr == nil || !cerr.fallback {
+		return result, cerr
 	}
 
-	// Apply provider-specific request defaults
-	if creds.ProviderConfig != nil {
-		utils.ApplyRequestDefaults(providerRequest, creds.ProviderConfig.RequestDefaults)
+	fallbacks := request.FallbackModels
+	if fallbacks == nil {
+		fallbacks = defaults
+	}
+
+	// Each fallback is priced against what is left of max_cost once earlier attempts are paid for
+	spent := cerr.spent
+	tried := map[string]bool{request.ModelKey: true}
+	for _, modelKey := range fallbacks {
+		if tried[modelKey] {
+			continue
+		}
+		tried[modelKey] = true
+
+		s.logger.Warn().
+			Str("model_key", modelKey).
+			Int("status_code", cerr.status).
+			Str("reason", cerr.msg).
+			Float64("spent", spent).
+			Msg("model failed, trying fallback model")
+
+		remaining := *request
+		remaining.MaxCost = request.MaxCost - spent
+		next, nextErr, _ := s.consumeModel(c, &remaining, modelKey)
+		if nextErr == nil || !nextErr.fallback {
+			return next, nextErr
+		}
+		spent += nextErr.spent
+		cerr = nextErr
+	}
+
+	return nil, cerr
+}
+
+// consumeModel prices the request for one model and sends it to the model's provider, trying
+// each eligible seller key in the order chosen by the key selector until one succeeds.
+// It also returns the model's default fallback chain.
+func (s *Service) consumeModel(c *fiber.Ctx, request *types.ConsumeModelRequest, modelKey string) (*consumeResult, *consumeError, []string) {
+	// Get every eligible seller key for the model, cached between calls
+	keys, err := s.modelCredentials(c.UserContext(), modelKey)
+	if err != nil || len(keys) == 0 {
+		return nil, &consumeError{
+			status:   fiber.StatusNotFound,
+			msg:      "model not found or no API key available",
+			fallback: true,
+		}, nil
 	}
 
-	payload, err := json.Marshal(providerRequest)
+	// Endpoint, provider config, price and fallbacks are the same for every key
+	model := &keys[0]
+
+	// Validate the options for this model and fill in its defaults, leaving the caller's request untouched
+	options, violations, err := s.prepareOptions(request.Options, model.OptionsSchemaID)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": true,
-			"msg":   "failed to marshal request",
-		})
+		s.logger.Error().
+			Err(err).
+			Str("model_key", modelKey).
+			Msg("failed to load options schema")
+		return nil, &consumeError{
+			status:   fiber.StatusInternalServerError,
+			msg:      "failed to load model options schema",
+			fallback: true,
+		}, model.FallbackModels
+	}
+	if len(violations) > 0 {
+		// Options valid for the requested model may not be for a fallback, so only those move on
+		return nil, &consumeError{
+			status:     fiber.StatusBadRequest,
+			msg:        fmt.Sprintf("invalid options for model %s: %s", modelKey, utils.FormatViolations(violations)),
+			fallback:   modelKey != request.ModelKey,
+			violations: violations,
+		}, model.FallbackModels
+	}
+	modelRequest := *request
+	modelRequest.Options = options
+	request = &modelRequest
+
+	// Serve repeated deterministic requests from the response cache, free of charge
+	cachePolicy := s.responseCachePolicy(c, request, model)
+	if cachePolicy != nil {
+		if cached := s.cachedResponse(cachePolicy); cached != nil {
+			c.Set("X-Cache", "HIT")
+			c.Set("X-Model-Key", modelKey)
+			return &consumeResult{creds: model, response: cached, cached: true}, nil, model.FallbackModels
+		}
+		c.Set("X-Cache", "MISS")
+	}
+
+	// Price the worst case before any upstream spend happens
+	if model.Price == nil {
+		return nil, &consumeError{
+			status:   fiber.StatusInternalServerError,
+			msg:      "model has no price configured",
+			fallback: true,
+		}, model.FallbackModels
+	}
+
+	// Skip the provider entirely while its circuit is open
+	if !s.breakers.get(model.ProviderName, model.ProviderConfig).ready() {
+		return nil, &consumeError{
+			status:   fiber.StatusServiceUnavailable,
+			msg:      "model provider is temporarily unavailable",
+			fallback: true,
+		}, model.FallbackModels
+	}
+
+	var requestDefaults map[string]any
+	if model.ProviderConfig != nil {
+		requestDefaults = model.ProviderConfig.RequestDefaults
+	}
+	promptTokens := utils.EstimatePromptTokens(request.Messages)
+	maxOutputTokens := utils.MaxOutputTokens(request.Options, requestDefaults)
+
+	if estimate := utils.EstimateCost(model.Price, promptTokens, maxOutputTokens); estimate > request.MaxCost {
+		return nil, &consumeError{
+			status:   fiber.StatusPaymentRequired,
+			msg:      fmt.Sprintf("estimated cost %.6f %s exceeds max_cost", estimate, model.Price.Currency),
+			fallback: true,
+		}, model.FallbackModels
 	}
 
-	// Send request to the model provider
-	httpReq, err := http.NewRequest("POST", creds.RequestURL, bytes.NewBuffer(payload))
+	// Hold the model to its requests and tokens per minute, another model may still serve the request
+	if cerr := s.checkRateLimit(types.RateLimitScopeModel, modelKey, model.ModelRateLimit); cerr != nil {
+		cerr.fallback = true
+		return nil, cerr, model.FallbackModels
+	}
+
+	payload, err := buildProviderPayload(request, modelKey, model.ProviderConfig)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": true,
-			"msg":   "failed to create request",
-		})
+		s.logger.Error().
+			Err(err).
+			Str("model_key", modelKey).
+			Msg("failed to build provider request")
+		return nil, &consumeError{
+			status: fiber.StatusInternalServerError,
+			msg:    "failed to marshal request",
+		}, model.FallbackModels
 	}
 
-	httpReq.Header.Set("Content-Type", "application/json")
+	var lastErr *consumeError
+	var spent float64
+	for _, creds := range s.keySelector.Order(modelKey, keys) {
+		result, cerr := s.consumeWithKey(c, request, &creds, payload, promptTokens+maxOutputTokens)
+		if cerr != nil {
+			spent += cerr.spent
+			cerr.spent = spent
+		}
+		if cerr == nil {
+			if cachePolicy != nil {
+				s.saveResponse(cachePolicy, result.response)
+			}
+			// Report which model served the request, which differs from model_key after a fallback
+			c.Set("X-Model-Key", modelKey)
+			return result, nil, model.FallbackModels
+		}
+		if !cerr.failover {
+			return nil, cerr, model.FallbackModels
+		}
 
-	// Set provider-specific headers using config
-	utils.SetProviderHeaders(httpReq, creds.ProviderConfig, creds.ApiKey)
+		s.logger.Warn().
+			Str("model_key", modelKey).
+			Str("api_key_id", creds.ApiKeyID).
+			Int("status_code", cerr.status).
+			Msg("seller key failed, trying next key")
+		lastErr = cerr
+	}
+
+	// Every seller key failed on the provider side, another model may still serve the request
+	lastErr.fallback = true
+	return nil, lastErr, model.FallbackModels
+}
+
+// consumeWithKey reserves the worst-case tokens against a single seller key and sends the request with it
+func (s *Service) consumeWithKey(c *fiber.Ctx, request *types.ConsumeModelRequest, creds *types.ModelCredentials, payload []byte, reserveTokens int) (result *consumeResult, cerr *consumeError) {
+	// Keep within the seller's provider quota, another key may still serve the request
+	if cerr := s.checkRateLimit(types.RateLimitScopeApiKey, creds.ApiKeyID, creds.KeyRateLimit); cerr != nil {
+		cerr.failover = true
+		return nil, cerr
+	}
 
-	resp, err := s.httpClient.Do(httpReq)
+	// Check if tokens available cover the worst-case usage
+	if creds.TokensAvailable < reserveTokens {
+		return nil, &consumeError{
+			status:   fiber.StatusPaymentRequired,
+			msg:      "insufficient tokens available",
+			failover: true,
+		}
+	}
+
+	// Hold the worst-case tokens against the api key until the actual usage is known
+	reservation, err := s.store.ReserveTokens(c.UserContext(), creds.ApiKeyID, creds.ModelKey, reserveTokens, s.reservationTTL)
+	if errors.Is(err, store.ErrInsufficientTokens) {
+		return nil, &consumeError{
+			status:   fiber.StatusPaymentRequired,
+			msg:      "insufficient tokens available",
+			failover: true,
+		}
+	}
 	if err != nil {
-		return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{
-			"error": true,
-			"msg":   "failed to reach model provider",
-		})
+		s.logger.Error().
+			Err(err).
+			Str("model_key", creds.ModelKey).
+			Msg("failed to reserve tokens")
+		return nil, &consumeError{
+			status: fiber.StatusInternalServerError,
+			msg:    "failed to reserve tokens",
+		}
+	}
+
+	call := &consumeCall{
+		ctx:         c.UserContext(),
+		price:       creds.Price,
+		provider:    creds.ProviderName,
+		reservation: reservation,
+		usage: &types.UsageRecord{
+			ConsumerID: consumerID(c),
+			ModelKey:   creds.ModelKey,
+			ApiKeyID:   &creds.ApiKeyID,
+			Currency:   &creds.Price.Currency,
+			Stream:     request.Stream,
+		},
+		started:      time.Now(),
+		promptTokens: utils.EstimatePromptTokens(request.Messages),
+		tokenLimits:  tokenLimits(c, creds),
+	}
+
+	// Release the reservation and record usage on every path that does not complete the call,
+	// and report what a failed call was still charged
+	defer func() {
+		if !call.detached {
+			s.completeCall(call, nil)
+		}
+		if cerr != nil {
+			cerr.spent = call.usage.Cost
+		}
+	}()
+
+	resp, err := s.sendProviderRequest(c.UserContext(), creds, payload)
+	if errors.Is(err, errCircuitOpen) {
+		return nil, &consumeError{
+			status:   fiber.StatusServiceUnavailable,
+			msg:      "model provider is temporarily unavailable",
+			fallback: true,
+		}
+	}
+	if errors.Is(err, utils.ErrKeyDecryption) {
+		s.logger.Error().
+			Err(err).
+			Str("api_key_id", creds.ApiKeyID).
+			Msg("failed to decrypt seller api key")
+		return nil, &consumeError{
+			status:   fiber.StatusInternalServerError,
+			msg:      "failed to decrypt seller api key",
+			failover: true,
+		}
+	}
+	if err != nil {
+		s.logger.Error().
+			Err(err).
+			Str("model_key", creds.ModelKey).
+			Msg("failed to reach model provider")
+		return nil, &consumeError{
+			status:   fiber.StatusBadGateway,
+			msg:      "failed to reach model provider",
+			failover: true,
+		}
+	}
+
+	call.usage.ProviderStatus = resp.StatusCode
+
+	// Hand the open body over to the stream writer, which closes it when done
+	if request.Stream && resp.StatusCode == http.StatusOK {
+		call.detached = true
+		return &consumeResult{creds: creds, call: call, stream: resp}, nil
 	}
 	defer resp.Body.Close()
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": true,
-			"msg":   "failed to read response",
-		})
+		return nil, &consumeError{
+			status: fiber.StatusInternalServerError,
+			msg:    "failed to read response",
+		}
 	}
 
 	// Check if the provider returned an error status
@@ -114,44 +423,195 @@ func (s *Service) ConsumeModel(c *fiber.Ctx) error {
 			Int("status_code", resp.StatusCode).
 			Str("body", string(body)).
 			Msg("model provider returned error")
-		return c.Status(resp.StatusCode).JSON(fiber.Map{
-			"error": true,
-			"msg":   "model provider error: " + string(body),
-		})
+		return nil, &consumeError{
+			status:   resp.StatusCode,
+			msg:      "model provider error: " + string(body),
+			failover: isFailoverStatus(resp.StatusCode),
+		}
+	}
+
+	// Catch provider format changes before the response mapping silently reads empty fields.
+	// Streams are relayed as they arrive and are not checked.
+	// The provider has already served the request, so the call is charged rather than retried
+	if s.checkResponse(creds, body) {
+		s.completeCall(call, driftedUsage(body, creds.ProviderConfig, request.Messages, reserveTokens))
+		return nil, &consumeError{
+			status: fiber.StatusBadGateway,
+			msg:    "model provider response does not match the response schema",
+		}
+	}
+
+	_, transform := utils.Tracer().Start(c.UserContext(), "transform response")
+	response, err := parseProviderResponse(body, creds.ProviderConfig)
+	if err != nil {
+		transform.RecordError(err)
+		transform.SetStatus(codes.Error, "failed to parse provider response")
+	}
+	transform.End()
+	if err != nil {
+		s.logger.Error().
+			Err(err).
+			Str("body", string(body)).
+			Msg("failed to parse provider response")
+		// The provider has already served the request, so the call is charged like a rejected one
+		s.completeCall(call, driftedUsage(body, creds.ProviderConfig, request.Messages, reserveTokens))
+		return nil, &consumeError{
+			status: fiber.StatusInternalServerError,
+			msg:    "failed to parse provider response",
+		}
 	}
 
-	// Transform the provider response to GeneralChatResponse
-	var response *types.GeneralChatResponse
-	if creds.ProviderConfig != nil && len(creds.ProviderConfig.ResponseMapping) > 0 {
-		response, err = utils.TransformResponse(body, creds.ProviderConfig.ResponseMapping)
+	s.completeCall(call, response)
+
+	return &consumeResult{creds: creds, call: call, response: response}, nil
+}
+
+// driftedUsage returns the usage to charge for a response rejected by the response schema, or
+// that could not be parsed: what the provider reported when the response still parses,
+// otherwise the estimated prompt and the rest of the reservation as completion.
+func driftedUsage(body []byte, config *types.ProviderConfig, messages []types.ChatMessage, reserveTokens int) *types.GeneralChatResponse {
+	if response, err := parseProviderResponse(body, config); err == nil && response.TotalTokens > 0 {
+		return response
+	}
+
+	promptTokens := min(utils.EstimatePromptTokens(messages), reserveTokens)
+	return &types.GeneralChatResponse{
+		PromptTokens:     promptTokens,
+		CompletionTokens: reserveTokens - promptTokens,
+		TotalTokens:      reserveTokens,
+	}
+}
+
+// buildProviderPayload builds the JSON request body sent to the model provider, shaped by
+// the provider's request mapping when it has one
+func buildProviderPayload(request *types.ConsumeModelRequest, modelKey string, config *types.ProviderConfig) ([]byte, error) {
+	var providerRequest map[string]interface{}
+
+	if config != nil && len(config.RequestMapping) > 0 {
+		var err error
+		providerRequest, err = utils.TransformRequest(config.RequestMapping, request, modelKey)
 		if err != nil {
-			s.logger.Error().
-				Err(err).
-				Str("body", string(body)).
-				Msg("failed to transform provider response")
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-				"error": true,
-				"msg":   "failed to parse provider response",
-			})
+			return nil, err
 		}
 	} else {
-		// Fallback: try to parse as GeneralChatResponse directly
-		response = &types.GeneralChatResponse{}
-		if err := json.Unmarshal(body, response); err != nil {
-			s.logger.Error().
-				Err(err).
-				Str("body", string(body)).
-				Msg("failed to parse provider response")
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-				"error": true,
-				"msg":   "failed to parse provider response",
-			})
+		providerRequest = map[string]interface{}{
+			"model":    modelKey,
+			"messages": request.Messages,
+		}
+		for k, v := range request.Options {
+			providerRequest[k] = v
+		}
+
+		if request.Stream {
+			providerRequest["stream"] = true
 		}
 	}
 
-	return c.JSON(fiber.Map{
-		"error":    false,
-		"msg":      nil,
-		"response": response,
-	})
+	// Apply provider-specific request defaults
+	if config != nil {
+		utils.ApplyRequestDefaults(providerRequest, config.RequestDefaults)
+		if request.Stream {
+			utils.ApplyRequestDefaults(providerRequest, config.StreamDefaults)
+		}
+	}
+
+	return json.Marshal(providerRequest)
+}
+
+// defaultMaxCost applies when DEFAULT_MAX_COST is not set
+const defaultMaxCost = 1.0
+
+// defaultMaxCostFromEnv reads the max_cost applied to compatibility facade requests that omit
+// one from DEFAULT_MAX_COST
+func defaultMaxCostFromEnv() float64 {
+	maxCost, err := strconv.ParseFloat(os.Getenv("DEFAULT_MAX_COST"), 64)
+	if err != nil || maxCost <= 0 {
+		return defaultMaxCost
+	}
+	return maxCost
+}
+
+// maxCostOrDefault returns the optional max_cost of a compatibility facade request. Their
+// clients have no notion of a cost cap, so the configured default applies when it is omitted.
+func maxCostOrDefault(maxCost *float64, fallback float64) float64 {
+	if maxCost == nil {
+		return fallback
+	}
+	return *maxCost
+}
+
+// sendProviderRequest posts the payload to the model endpoint using the seller key in creds,
+// retrying transient errors under the provider's retry policy and feeding the provider's circuit breaker.
+// The call is traced in a client span whose context the provider receives in a traceparent header;
+// for streams the span ends once the response headers arrive.
+func (s *Service) sendProviderRequest(ctx context.Context, creds *types.ModelCredentials, payload []byte) (resp *http.Response, err error) {
+	ctx, span := utils.Tracer().Start(ctx, "provider "+creds.ProviderName, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(
+		attribute.String("agc.model_key", creds.ModelKey),
+		attribute.String("agc.provider", creds.ProviderName),
+		attribute.String("agc.api_key_id", creds.ApiKeyID),
+	))
+	defer func() {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		} else {
+			span.SetAttributes(semconv.HTTPResponseStatusCode(resp.StatusCode))
+			if resp.StatusCode >= http.StatusInternalServerError {
+				span.SetStatus(codes.Error, "")
+			}
+		}
+		span.End()
+	}()
+
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", creds.RequestURL, bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+
+	httpReq.Header.Set("Content-Type", "application/json")
+	utils.TraceContext.Inject(ctx, propagation.HeaderCarrier(httpReq.Header))
+
+	// Open the seller key only to sign this request
+	apiKey, err := s.keyRing.Open(creds.SealedKey)
+	if err != nil {
+		return nil, err
+	}
+
+	// Set provider-specific headers using config
+	utils.SetProviderHeaders(httpReq, creds.ProviderConfig, apiKey)
+
+	breaker := s.breakers.get(creds.ProviderName, creds.ProviderConfig)
+	if !breaker.allow() {
+		return nil, errCircuitOpen
+	}
+
+	client := newRetryClient(s.httpClient, creds.ProviderConfig, s.logger)
+	started := time.Now()
+	resp, err = client.Do(httpReq)
+	breaker.record(err != nil || resp.StatusCode >= http.StatusInternalServerError, time.Since(started))
+
+	return resp, err
+}
+
+// parseProviderResponse transforms the provider response body to GeneralChatResponse
+func parseProviderResponse(body []byte, config *types.ProviderConfig) (*types.GeneralChatResponse, error) {
+	if config != nil && len(config.ResponseMapping) > 0 {
+		return utils.TransformResponse(body, config.ResponseMapping)
+	}
+
+	// Fallback: try to parse as GeneralChatResponse directly
+	response := &types.GeneralChatResponse{}
+	if err := json.Unmarshal(body, response); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+// isFailoverStatus reports whether a provider status means the seller key itself is
+// unusable right now (revoked, rate limited or provider-side failure)
+func isFailoverStatus(status int) bool {
+	return status == http.StatusUnauthorized ||
+		status == http.StatusForbidden ||
+		status == http.StatusTooManyRequests ||
+		status >= http.StatusInternalServerError
 }
@@ -0,0 +1,287 @@
+package service
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/wmbryce/agent-c/app/types"
+	"github.com/wmbryce/agent-c/app/utils"
+)
+
+// CreateConsumer func adds a consumer.
+// @Description Create a consumer.
+// @Summary create a consumer
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Param consumer body types.Consumer true "Consumer"
+// @Success 200 {object} types.Consumer
+// @Security ApiKeyAuth
+// @Router /v1/admin/consumers [post]
+func (s *Service) CreateConsumer(c *fiber.Ctx) error {
+	consumer := &types.Consumer{}
+	if err := c.BodyParser(consumer); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": true,
+			"msg":   err.Error(),
+		})
+	}
+
+	validate := utils.NewValidator()
+	if err := validate.Struct(consumer); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": true,
+			"msg":   utils.ValidatorErrors(err),
+		})
+	}
+
+	created, err := s.store.CreateConsumer(consumer)
+	if err != nil {
+		return storeError(c, err)
+	}
+
+	return c.JSON(fiber.Map{
+		"error":    false,
+		"msg":      "Consumer created successfully",
+		"consumer": created,
+	})
+}
+
+// GetConsumers func returns every consumer.
+// @Description List all consumers.
+// @Summary list consumers
+// @Tags Admin
+// @Produce json
+// @Success 200 {array} types.Consumer
+// @Security ApiKeyAuth
+// @Router /v1/admin/consumers [get]
+func (s *Service) GetConsumers(c *fiber.Ctx) error {
+	consumers, err := s.store.GetConsumers()
+	if err != nil {
+		return storeError(c, err)
+	}
+
+	return c.JSON(fiber.Map{
+		"error":     false,
+		"msg":       nil,
+		"consumers": consumers,
+	})
+}
+
+// GetConsumer func returns a consumer by id.
+// @Description Get a consumer by id.
+// @Summary get a consumer
+// @Tags Admin
+// @Produce json
+// @Param id path string true "Consumer ID"
+// @Success 200 {object} types.Consumer
+// @Security ApiKeyAuth
+// @Router /v1/admin/consumers/{id} [get]
+func (s *Service) GetConsumer(c *fiber.Ctx) error {
+	if !validID(c) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": true,
+			"msg":   "invalid consumer id",
+		})
+	}
+
+	consumer, err := s.store.GetConsumer(c.Params("id"))
+	if err != nil {
+		return storeError(c, err)
+	}
+
+	return c.JSON(fiber.Map{
+		"error":    false,
+		"msg":      nil,
+		"consumer": consumer,
+	})
+}
+
+// UpdateConsumer func replaces a consumer.
+// @Description Replace a consumer.
+// @Summary update a consumer
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Param id path string true "Consumer ID"
+// @Param consumer body types.Consumer true "Consumer"
+// @Success 200 {object} types.Consumer
+// @Security ApiKeyAuth
+// @Router /v1/admin/consumers/{id} [put]
+func (s *Service) UpdateConsumer(c *fiber.Ctx) error {
+	consumer := &types.Consumer{}
+	if err := c.BodyParser(consumer); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": true,
+			"msg":   err.Error(),
+		})
+	}
+
+	validate := utils.NewValidator()
+	if err := validate.Struct(consumer); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": true,
+			"msg":   utils.ValidatorErrors(err),
+		})
+	}
+	if !validID(c) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": true,
+			"msg":   "invalid consumer id",
+		})
+	}
+	consumer.ID = c.Params("id")
+
+	updated, err := s.store.UpdateConsumer(consumer)
+	if err != nil {
+		return storeError(c, err)
+	}
+
+	return c.JSON(fiber.Map{
+		"error":    false,
+		"msg":      "Consumer updated successfully",
+		"consumer": updated,
+	})
+}
+
+// DeleteConsumer func removes a consumer.
+// @Description Delete a consumer along with its api keys.
+// @Summary delete a consumer
+// @Tags Admin
+// @Produce json
+// @Param id path string true "Consumer ID"
+// @Success 200 {object} map[string]interface{}
+// @Security ApiKeyAuth
+// @Router /v1/admin/consumers/{id} [delete]
+func (s *Service) DeleteConsumer(c *fiber.Ctx) error {
+	if !validID(c) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": true,
+			"msg":   "invalid consumer id",
+		})
+	}
+
+	if err := s.store.DeleteConsumer(c.Params("id")); err != nil {
+		return storeError(c, err)
+	}
+
+	return c.JSON(fiber.Map{
+		"error": false,
+		"msg":   "Consumer deleted successfully",
+	})
+}
+
+// CreateConsumerKey func issues an api key to a consumer.
+// @Description Issue an api key to a consumer, scoped to consume and/or usage. The key is only returned in this response; agent-c stores a hash of it.
+// @Summary issue a consumer api key
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Param id path string true "Consumer ID"
+// @Param key body types.ConsumerKey true "Consumer key"
+// @Success 200 {object} types.NewConsumerKey
+// @Security ApiKeyAuth
+// @Router /v1/admin/consumers/{id}/keys [post]
+func (s *Service) CreateConsumerKey(c *fiber.Ctx) error {
+	key := &types.ConsumerKey{}
+	if err := c.BodyParser(key); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": true,
+			"msg":   err.Error(),
+		})
+	}
+
+	validate := utils.NewValidator()
+	if err := validate.Struct(key); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": true,
+			"msg":   utils.ValidatorErrors(err),
+		})
+	}
+	if !validID(c) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": true,
+			"msg":   "invalid consumer id",
+		})
+	}
+
+	raw, prefix, secret, err := utils.GenerateConsumerKey()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": true,
+			"msg":   err.Error(),
+		})
+	}
+	key.ConsumerID = c.Params("id")
+	key.Prefix = prefix
+	key.KeyHash = utils.GeneratePassword(secret)
+
+	created, err := s.store.CreateConsumerKey(key)
+	if err != nil {
+		return storeError(c, err)
+	}
+
+	return c.JSON(fiber.Map{
+		"error": false,
+		"msg":   "Consumer key created successfully, it will not be shown again",
+		"key":   types.NewConsumerKey{ConsumerKey: *created, Key: raw},
+	})
+}
+
+// GetConsumerKeys func returns every key issued to a consumer.
+// @Description List a consumer's api keys, revoked ones included. Keys themselves are never returned.
+// @Summary list consumer api keys
+// @Tags Admin
+// @Produce json
+// @Param id path string true "Consumer ID"
+// @Success 200 {array} types.ConsumerKey
+// @Security ApiKeyAuth
+// @Router /v1/admin/consumers/{id}/keys [get]
+func (s *Service) GetConsumerKeys(c *fiber.Ctx) error {
+	if !validID(c) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": true,
+			"msg":   "invalid consumer id",
+		})
+	}
+
+	keys, err := s.store.GetConsumerKeys(c.Params("id"))
+	if err != nil {
+		return storeError(c, err)
+	}
+
+	return c.JSON(fiber.Map{
+		"error": false,
+		"msg":   nil,
+		"keys":  keys,
+	})
+}
+
+// RevokeConsumerKey func revokes a consumer's api key.
+// @Description Revoke a consumer api key. Revoked keys stop authenticating immediately but stay listed.
+// @Summary revoke a consumer api key
+// @Tags Admin
+// @Produce json
+// @Param id path string true "Consumer ID"
+// @Param key_id path string true "Consumer key ID"
+// @Success 200 {object} types.ConsumerKey
+// @Security ApiKeyAuth
+// @Router /v1/admin/consumers/{id}/keys/{key_id} [delete]
+func (s *Service) RevokeConsumerKey(c *fiber.Ctx) error {
+	validate := utils.NewValidator()
+	if !validID(c) || validate.Var(c.Params("key_id"), "uuid") != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": true,
+			"msg":   "invalid consumer key id",
+		})
+	}
+
+	key, err := s.store.RevokeConsumerKey(c.Params("id"), c.Params("key_id"))
+	if err != nil {
+		return storeError(c, err)
+	}
+
+	return c.JSON(fiber.Map{
+		"error": false,
+		"msg":   "Consumer key revoked successfully",
+		"key":   key,
+	})
+}
@@ -0,0 +1,195 @@
+package service
+
+import (
+	"container/list"
+	"context"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/wmbryce/agent-c/app/types"
+)
+
+// defaultCredentialsCacheSize applies when CREDENTIALS_CACHE_SIZE is not set
+const defaultCredentialsCacheSize = 1000
+
+// defaultCredentialsCacheTTL applies when CREDENTIALS_CACHE_TTL_SECONDS is not set. It bounds
+// how stale key balances get, and how long an invalidation lost while Redis is unreachable
+// goes unnoticed.
+const defaultCredentialsCacheTTL = time.Minute
+
+// credentialsCache is a least recently used cache of each model's seller keys, provider config
+// and price by model key. Entries are dropped together whenever an admin changes a provider,
+// model or key, since one provider or seller change touches many models.
+type credentialsCache struct {
+	mu       sync.Mutex
+	capacity int
+	ttl      time.Duration
+	entries  map[string]*list.Element
+	// recent orders entries from most to least recently used
+	recent *list.List
+	// generation moves on every purge, so loads that raced an invalidation are not cached
+	generation uint64
+
+	hits          uint64
+	misses        uint64
+	invalidations uint64
+}
+
+type credentialsEntry struct {
+	modelKey string
+	keys     []types.ModelCredentials
+	loaded   time.Time
+}
+
+// newCredentialsCache reads its size from CREDENTIALS_CACHE_SIZE and its TTL from
+// CREDENTIALS_CACHE_TTL_SECONDS. A size or TTL of 0 disables the cache.
+func newCredentialsCache() *credentialsCache {
+	capacity, err := strconv.Atoi(os.Getenv("CREDENTIALS_CACHE_SIZE"))
+	if err != nil {
+		capacity = defaultCredentialsCacheSize
+	}
+	ttl := defaultCredentialsCacheTTL
+	if seconds, err := strconv.Atoi(os.Getenv("CREDENTIALS_CACHE_TTL_SECONDS")); err == nil {
+		ttl = time.Duration(seconds) * time.Second
+	}
+
+	return &credentialsCache{
+		capacity: capacity,
+		ttl:      ttl,
+		entries:  map[string]*list.Element{},
+		recent:   list.New(),
+	}
+}
+
+// modelCredentials returns every seller key that can serve the model, loading them from the
+// store on a cache miss. Balances may be stale; reserving tokens in the store is authoritative.
+func (s *Service) modelCredentials(ctx context.Context, modelKey string) ([]types.ModelCredentials, error) {
+	keys, generation, ok := s.credentials.get(modelKey)
+	if ok {
+		return keys, nil
+	}
+
+	keys, err := s.store.GetModelCredentials(ctx, modelKey)
+	if err != nil {
+		return nil, err
+	}
+
+	// Models without keys are not cached, so a key added for them is used right away
+	if len(keys) > 0 {
+		s.credentials.put(modelKey, keys, generation)
+	}
+	return keys, nil
+}
+
+// invalidateCredentials drops the cached credentials of this instance and tells every other
+// instance to do the same. Called after an admin changes a provider, model, key or seller.
+func (s *Service) invalidateCredentials() {
+	s.credentials.purge()
+
+	if s.cache == nil {
+		return
+	}
+	if err := s.cache.PublishCredentialsInvalidation(); err != nil {
+		s.logger.Warn().Err(err).Msg("failed to publish credentials invalidation")
+	}
+}
+
+// watchCredentialsInvalidation drops the cached credentials whenever any instance publishes an
+// invalidation
+func (s *Service) watchCredentialsInvalidation() {
+	if s.cache == nil {
+		return
+	}
+	if err := s.cache.SubscribeCredentialsInvalidation(context.Background(), s.credentials.purge); err != nil {
+		s.logger.Warn().Err(err).Msg("failed to subscribe to credentials invalidations, changes made by other instances apply once cached credentials expire")
+	}
+}
+
+// CredentialsCacheStats reports the hit rate of the credentials cache
+func (s *Service) CredentialsCacheStats() types.CredentialsCacheStats {
+	return s.credentials.stats()
+}
+
+// get returns a copy of the cached keys for a model, so callers may reorder them, along with
+// the generation to cache a load under on a miss
+func (c *credentialsCache) get(modelKey string) ([]types.ModelCredentials, uint64, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, ok := c.entries[modelKey]
+	if ok && time.Since(element.Value.(*credentialsEntry).loaded) >= c.ttl {
+		c.remove(element)
+		ok = false
+	}
+	credentialsCacheLookups.WithLabelValues(cacheResult(ok)).Inc()
+	if !ok {
+		c.misses++
+		return nil, c.generation, false
+	}
+
+	c.hits++
+	c.recent.MoveToFront(element)
+	return append([]types.ModelCredentials(nil), element.Value.(*credentialsEntry).keys...), c.generation, true
+}
+
+// put caches the keys loaded for a model, unless the cache was purged since the load began
+func (c *credentialsCache) put(modelKey string, keys []types.ModelCredentials, generation uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.capacity <= 0 || c.ttl <= 0 || generation != c.generation {
+		return
+	}
+
+	entry := &credentialsEntry{
+		modelKey: modelKey,
+		keys:     append([]types.ModelCredentials(nil), keys...),
+		loaded:   time.Now(),
+	}
+	if element, ok := c.entries[modelKey]; ok {
+		element.Value = entry
+		c.recent.MoveToFront(element)
+		return
+	}
+
+	c.entries[modelKey] = c.recent.PushFront(entry)
+	for c.recent.Len() > c.capacity {
+		c.remove(c.recent.Back())
+	}
+}
+
+// purge drops every cached model
+func (c *credentialsCache) purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries = map[string]*list.Element{}
+	c.recent.Init()
+	c.generation++
+	c.invalidations++
+	credentialsCacheInvalidations.Inc()
+}
+
+func (c *credentialsCache) remove(element *list.Element) {
+	delete(c.entries, element.Value.(*credentialsEntry).modelKey)
+	c.recent.Remove(element)
+}
+
+func (c *credentialsCache) stats() types.CredentialsCacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stats := types.CredentialsCacheStats{
+		Entries:       c.recent.Len(),
+		Capacity:      c.capacity,
+		Hits:          c.hits,
+		Misses:        c.misses,
+		Invalidations: c.invalidations,
+	}
+	if lookups := c.hits + c.misses; lookups > 0 {
+		stats.HitRate = float64(c.hits) / float64(lookups)
+	}
+	return stats
+}
@@ -2,10 +2,13 @@ package service
 
 import (
 	"net/http"
+	"os"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/rs/zerolog"
 	"github.com/wmbryce/agent-c/app/store"
+	"github.com/wmbryce/agent-c/app/utils"
 )
 
 // HTTPClient interface for making HTTP requests (allows mocking in tests)
@@ -16,18 +19,79 @@ type HTTPClient interface {
 type Service struct {
 	logger     *zerolog.Logger
 	store      store.SqlStore
+	cache      store.CacheStore
 	fiber      *fiber.App
 	httpClient HTTPClient
+	// keySelector orders seller keys for load balancing and failover
+	keySelector KeySelector
+	// breakers track the health of each provider
+	breakers *circuitBreakers
+	// schemas caches compiled model schemas
+	schemas *schemaCache
+	// credentials caches each model's seller keys, provider config and price
+	credentials *credentialsCache
+	// responseSchemaFailClosed rejects provider responses that do not match the response schema
+	responseSchemaFailClosed bool
+	// keyRing seals seller api keys at rest and opens them when signing provider requests
+	keyRing *utils.KeyRing
+	// siwe configures Sign-In with Ethereum
+	siwe siweConfig
+	// jwtKeys sign access tokens and verify them by kid
+	jwtKeys *utils.JWTKeySet
+	// responseCacheTTL applies to models without a response cache TTL of their own
+	responseCacheTTL time.Duration
+	// defaultMaxCost caps compatibility facade requests that do not set max_cost
+	defaultMaxCost float64
+	// reservationTTL is how long a reservation stays open before it is swept as abandoned
+	reservationTTL time.Duration
 }
 
-func New(logger *zerolog.Logger, sqlStore store.SqlStore, fiber *fiber.App, client HTTPClient) *Service {
+// providerResponseHeaderTimeout bounds how long the default client waits for a provider to respond.
+// Streams are not cut off once headers arrive.
+const providerResponseHeaderTimeout = 120 * time.Second
+
+func New(logger *zerolog.Logger, sqlStore store.SqlStore, cacheStore store.CacheStore, fiber *fiber.App, client HTTPClient) *Service {
 	if client == nil {
-		client = &http.Client{}
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.ResponseHeaderTimeout = providerResponseHeaderTimeout
+		client = &http.Client{Transport: transport}
+	}
+
+	keyRing, err := utils.KeyRingFromEnv()
+	if err != nil {
+		logger.Warn().Err(err).Msg("api key master key not configured, seller keys cannot be stored or used")
+	}
+
+	siwe := newSiweConfig()
+	if siwe.domain == "" {
+		logger.Warn().Msg("SIWE_DOMAIN not configured, sign-in is unavailable")
 	}
-	return &Service{
-		logger:     logger,
-		store:      sqlStore,
-		fiber:      fiber,
-		httpClient: client,
+
+	jwtKeys, err := utils.JWTKeySetFromEnv()
+	if err != nil {
+		logger.Warn().Err(err).Msg("jwt signing keys not configured, sign-in is unavailable")
 	}
+
+	svc := &Service{
+		logger:      logger,
+		store:       sqlStore,
+		cache:       cacheStore,
+		fiber:       fiber,
+		httpClient:  client,
+		keySelector: NewKeySelector(os.Getenv("KEY_SELECTION_STRATEGY")),
+		breakers:    newCircuitBreakers(),
+		schemas:     newSchemaCache(),
+		credentials: newCredentialsCache(),
+		keyRing:     keyRing,
+		siwe:        siwe,
+		jwtKeys:     jwtKeys,
+
+		responseSchemaFailClosed: os.Getenv("RESPONSE_SCHEMA_FAIL_CLOSED") == "true",
+		responseCacheTTL:         responseCacheTTLFromEnv(),
+		defaultMaxCost:           defaultMaxCostFromEnv(),
+		reservationTTL:           reservationTTLFromEnv(),
+	}
+	svc.watchCredentialsInvalidation()
+
+	return svc
 }
@@ -0,0 +1,109 @@
+package service
+
+import (
+	"math/rand/v2"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/wmbryce/agent-c/app/types"
+)
+
+// Key selection strategies, chosen with KEY_SELECTION_STRATEGY
+const (
+	KeySelectionRoundRobin      = "round_robin"
+	KeySelectionWeighted        = "weighted"
+	KeySelectionLeastRecentUsed = "least_recently_used"
+)
+
+// KeySelector orders the seller keys of a model. The consume pipeline tries the
+// keys in the returned order, failing over to the next one when a key is unusable.
+type KeySelector interface {
+	Order(modelKey string, keys []types.ModelCredentials) []types.ModelCredentials
+}
+
+// NewKeySelector returns the selector for strategy, defaulting to round robin
+func NewKeySelector(strategy string) KeySelector {
+	switch strategy {
+	case KeySelectionWeighted:
+		return &weightedSelector{}
+	case KeySelectionLeastRecentUsed:
+		return &lruSelector{lastUsed: make(map[string]time.Time)}
+	default:
+		return &roundRobinSelector{next: make(map[string]int)}
+	}
+}
+
+// roundRobinSelector rotates the starting key per model on every request
+type roundRobinSelector struct {
+	mu   sync.Mutex
+	next map[string]int
+}
+
+func (r *roundRobinSelector) Order(modelKey string, keys []types.ModelCredentials) []types.ModelCredentials {
+	if len(keys) == 0 {
+		return keys
+	}
+
+	r.mu.Lock()
+	start := r.next[modelKey] % len(keys)
+	r.next[modelKey] = start + 1
+	r.mu.Unlock()
+
+	ordered := make([]types.ModelCredentials, 0, len(keys))
+	ordered = append(ordered, keys[start:]...)
+	return append(ordered, keys[:start]...)
+}
+
+// weightedSelector picks keys at random, weighted by the tokens each has available
+type weightedSelector struct{}
+
+func (w *weightedSelector) Order(modelKey string, keys []types.ModelCredentials) []types.ModelCredentials {
+	remaining := append([]types.ModelCredentials(nil), keys...)
+	ordered := make([]types.ModelCredentials, 0, len(keys))
+
+	for len(remaining) > 0 {
+		total := 0
+		for _, k := range remaining {
+			total += max(k.TokensAvailable, 1)
+		}
+
+		pick := rand.IntN(total)
+		i := 0
+		for ; i < len(remaining)-1; i++ {
+			pick -= max(remaining[i].TokensAvailable, 1)
+			if pick < 0 {
+				break
+			}
+		}
+
+		ordered = append(ordered, remaining[i])
+		remaining = append(remaining[:i], remaining[i+1:]...)
+	}
+
+	return ordered
+}
+
+// lruSelector prefers the key that has gone unused the longest
+type lruSelector struct {
+	mu       sync.Mutex
+	lastUsed map[string]time.Time
+}
+
+func (l *lruSelector) Order(modelKey string, keys []types.ModelCredentials) []types.ModelCredentials {
+	ordered := append([]types.ModelCredentials(nil), keys...)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	// Stable sort keeps the store's order (most tokens first) between keys never used
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return l.lastUsed[ordered[i].ApiKeyID].Before(l.lastUsed[ordered[j].ApiKeyID])
+	})
+
+	if len(ordered) > 0 {
+		l.lastUsed[ordered[0].ApiKeyID] = time.Now()
+	}
+
+	return ordered
+}
@@ -0,0 +1,77 @@
+package service
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/wmbryce/agent-c/app/types"
+)
+
+var (
+	providerRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "agc_provider_requests_total",
+		Help: "Calls to model providers, by model, provider and upstream status code (error when the provider was not reached).",
+	}, []string{"model_key", "provider", "status"})
+
+	providerRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "agc_provider_request_duration_seconds",
+		Help:    "Time from reserving tokens to completing a provider call, by model and provider. Streams are measured until they end.",
+		Buckets: []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 20, 30, 60, 120, 300},
+	}, []string{"model_key", "provider"})
+
+	tokensConsumed = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "agc_tokens_total",
+		Help: "Tokens reported by model providers, by model, provider and type (prompt or completion).",
+	}, []string{"model_key", "provider", "type"})
+
+	costCharged = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "agc_cost_total",
+		Help: "Cost charged for provider calls, by model, provider and currency.",
+	}, []string{"model_key", "provider", "currency"})
+
+	responseCacheLookups = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "agc_response_cache_lookups_total",
+		Help: "Response cache lookups, by model and result (hit or miss).",
+	}, []string{"model_key", "result"})
+
+	credentialsCacheLookups = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "agc_credentials_cache_lookups_total",
+		Help: "Model credentials cache lookups, by result (hit or miss).",
+	}, []string{"result"})
+
+	credentialsCacheInvalidations = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "agc_credentials_cache_invalidations_total",
+		Help: "Times the model credentials cache was dropped after an admin change on any instance.",
+	})
+)
+
+// observeCall records a completed provider call. A nil response means the call failed.
+func observeCall(call *consumeCall, response *types.GeneralChatResponse) {
+	record := call.usage
+
+	status := "error"
+	if record.ProviderStatus != 0 {
+		status = strconv.Itoa(record.ProviderStatus)
+	}
+	providerRequests.WithLabelValues(record.ModelKey, call.provider, status).Inc()
+	providerRequestDuration.WithLabelValues(record.ModelKey, call.provider).Observe(time.Since(call.started).Seconds())
+
+	if response == nil {
+		return
+	}
+	tokensConsumed.WithLabelValues(record.ModelKey, call.provider, "prompt").Add(float64(response.PromptTokens))
+	tokensConsumed.WithLabelValues(record.ModelKey, call.provider, "completion").Add(float64(response.CompletionTokens))
+	if call.price != nil {
+		costCharged.WithLabelValues(record.ModelKey, call.provider, call.price.Currency).Add(response.Cost)
+	}
+}
+
+// cacheResult labels a cache lookup
+func cacheResult(hit bool) string {
+	if hit {
+		return "hit"
+	}
+	return "miss"
+}
@@ -6,6 +6,13 @@ import (
 	"github.com/wmbryce/agent-c/app/utils"
 )
 
+// GetModels func returns all models with the price currently in effect.
+// @Description List all models, including their current per-million-token prices.
+// @Summary list models
+// @Tags AI
+// @Produce json
+// @Success 200 {array} types.Model
+// @Router /v1/ai/models [get]
 func (s *Service) GetModels(c *fiber.Ctx) error {
 	models, err := s.store.GetModels()
 	if err != nil {
@@ -46,6 +53,7 @@ func (s *Service) CreateModel(c *fiber.Ctx) error {
 			"msg":   err.Error(),
 		})
 	}
+	s.invalidateCredentials()
 
 	return c.JSON(fiber.Map{
 		"error": false,
@@ -53,3 +61,129 @@ func (s *Service) CreateModel(c *fiber.Ctx) error {
 		"model": createdModel,
 	})
 }
+
+// CreateModelPrice func adds a new price version for a model.
+// @Description Add a price version for a model. Prices are per million tokens; omit effective_from to apply immediately.
+// @Summary add a model price
+// @Tags AI
+// @Accept json
+// @Produce json
+// @Param id path string true "Model ID"
+// @Param price body types.ModelPrice true "Model price"
+// @Success 200 {object} types.ModelPrice
+// @Security ApiKeyAuth
+// @Router /v1/ai/models/{id}/prices [post]
+func (s *Service) CreateModelPrice(c *fiber.Ctx) error {
+	price := &types.ModelPrice{}
+	if err := c.BodyParser(price); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": true,
+			"msg":   err.Error(),
+		})
+	}
+
+	validate := utils.NewValidator()
+	if err := validate.Struct(price); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": true,
+			"msg":   utils.ValidatorErrors(err),
+		})
+	}
+	if err := validate.Var(c.Params("id"), "uuid"); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": true,
+			"msg":   "invalid model id",
+		})
+	}
+
+	createdPrice, err := s.store.CreateModelPrice(c.Params("id"), price)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": true,
+			"msg":   err.Error(),
+		})
+	}
+	s.invalidateCredentials()
+
+	return c.JSON(fiber.Map{
+		"error": false,
+		"msg":   "Model price created successfully",
+		"price": createdPrice,
+	})
+}
+
+// UpdateModel func replaces a model.
+// @Description Replace a model's fields. Prices are versioned separately and are left unchanged.
+// @Summary update a model
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Param id path string true "Model ID"
+// @Param model body types.Model true "Model"
+// @Success 200 {object} types.Model
+// @Security ApiKeyAuth
+// @Router /v1/admin/models/{id} [put]
+func (s *Service) UpdateModel(c *fiber.Ctx) error {
+	model := &types.Model{}
+	if err := c.BodyParser(model); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": true,
+			"msg":   err.Error(),
+		})
+	}
+
+	validate := utils.NewValidator()
+	if err := validate.Struct(model); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": true,
+			"msg":   utils.ValidatorErrors(err),
+		})
+	}
+	if !validID(c) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": true,
+			"msg":   "invalid model id",
+		})
+	}
+	model.ID = c.Params("id")
+
+	updatedModel, err := s.store.UpdateModel(model)
+	if err != nil {
+		return storeError(c, err)
+	}
+	s.invalidateCredentials()
+
+	return c.JSON(fiber.Map{
+		"error": false,
+		"msg":   "Model updated successfully",
+		"model": updatedModel,
+	})
+}
+
+// DeleteModel func removes a model.
+// @Description Delete a model along with its prices.
+// @Summary delete a model
+// @Tags Admin
+// @Produce json
+// @Param id path string true "Model ID"
+// @Success 200 {object} map[string]interface{}
+// @Security ApiKeyAuth
+// @Router /v1/admin/models/{id} [delete]
+func (s *Service) DeleteModel(c *fiber.Ctx) error {
+	if !validID(c) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": true,
+			"msg":   "invalid model id",
+		})
+	}
+
+	if err := s.store.DeleteModel(c.Params("id")); err != nil {
+		return storeError(c, err)
+	}
+	s.invalidateCredentials()
+
+	return c.JSON(fiber.Map{
+		"error": false,
+		"msg":   "Model deleted successfully",
+	})
+}
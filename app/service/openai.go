@@ -0,0 +1,209 @@
+package service
+
+import (
+	"io"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/wmbryce/agent-c/app/types"
+	"github.com/wmbryce/agent-c/app/utils"
+)
+
+// ChatCompletions func serves the OpenAI chat completions API on top of the model registry.
+// @Description Accept an OpenAI chat completion request and return an OpenAI-shaped response, whichever provider serves the model.
+// @Description Requests are billed like consume requests. max_cost is optional and defaults to DEFAULT_MAX_COST.
+// @Summary OpenAI-compatible chat completions
+// @Tags OpenAI
+// @Accept json
+// @Produce json
+// @Param request body types.OpenAIChatCompletionRequest true "Chat completion request"
+// @Success 200 {object} types.ChatCompletionResponse
+// @Security ApiKeyAuth
+// @Router /v1/chat/completions [post]
+func (s *Service) ChatCompletions(c *fiber.Ctx) error {
+	request := &types.OpenAIChatCompletionRequest{}
+	if err := c.BodyParser(request); err != nil {
+		return openAIError(c, fiber.StatusBadRequest, err.Error())
+	}
+
+	validate := utils.NewValidator()
+	if err := validate.Struct(request); err != nil {
+		return openAIError(c, fiber.StatusBadRequest, err.Error())
+	}
+
+	result, cerr := s.consume(c, openAIConsumeRequest(request, s.defaultMaxCost))
+	if cerr != nil {
+		return openAIError(c, cerr.status, cerr.msg)
+	}
+
+	if result.stream != nil {
+		includeUsage := request.StreamOptions != nil && request.StreamOptions.IncludeUsage
+		return s.streamResponse(c, result, &openAIStream{
+			id:           "chatcmpl-" + uuid.New().String(),
+			model:        result.creds.ModelKey,
+			created:      time.Now().Unix(),
+			includeUsage: includeUsage,
+		})
+	}
+
+	response := result.response
+	id := response.ID
+	if id == "" {
+		id = "chatcmpl-" + uuid.New().String()
+	}
+
+	return c.JSON(types.ChatCompletionResponse{
+		ID:      id,
+		Object:  "chat.completion",
+		Created: time.Now().Unix(),
+		Model:   result.creds.ModelKey,
+		Choices: []types.Choice{{
+			Index: 0,
+			Message: types.ChatMessage{
+				Role:    "assistant",
+				Content: response.Content,
+			},
+			FinishReason: openAIFinishReason(response.FinishReason),
+		}},
+		Usage: types.Usage{
+			PromptTokens:     response.PromptTokens,
+			CompletionTokens: response.CompletionTokens,
+			TotalTokens:      response.TotalTokens,
+		},
+	})
+}
+
+// openAIConsumeRequest converts an OpenAI chat completion request into a consume request
+func openAIConsumeRequest(request *types.OpenAIChatCompletionRequest, defaultMaxCost float64) *types.ConsumeModelRequest {
+	options := map[string]interface{}{}
+	if request.Temperature != nil {
+		options["temperature"] = *request.Temperature
+	}
+	if request.TopP != nil {
+		options["top_p"] = *request.TopP
+	}
+	if request.MaxTokens != nil {
+		options["max_tokens"] = *request.MaxTokens
+	}
+	if request.MaxCompletionTokens != nil {
+		options["max_completion_tokens"] = *request.MaxCompletionTokens
+	}
+	if request.PresencePenalty != nil {
+		options["presence_penalty"] = *request.PresencePenalty
+	}
+	if request.FrequencyPenalty != nil {
+		options["frequency_penalty"] = *request.FrequencyPenalty
+	}
+	if request.Stop != nil {
+		options["stop"] = request.Stop
+	}
+	if request.User != "" {
+		options["user"] = request.User
+	}
+
+	return &types.ConsumeModelRequest{
+		ModelKey:       request.Model,
+		Messages:       request.Messages,
+		Options:        options,
+		MaxCost:        maxCostOrDefault(request.MaxCost, defaultMaxCost),
+		Stream:         request.Stream,
+		FallbackModels: request.FallbackModels,
+	}
+}
+
+// openAIStream writes a relayed stream as OpenAI chat.completion.chunk events ending in [DONE]
+type openAIStream struct {
+	id           string
+	model        string
+	created      int64
+	includeUsage bool
+	started      bool
+}
+
+func (o *openAIStream) chunk(choices []types.ChunkChoice, usage *types.Usage) types.ChatCompletionChunk {
+	return types.ChatCompletionChunk{
+		ID:      o.id,
+		Object:  "chat.completion.chunk",
+		Created: o.created,
+		Model:   o.model,
+		Choices: choices,
+		Usage:   usage,
+	}
+}
+
+func (o *openAIStream) delta(w io.Writer, delta *types.GeneralChatResponse) error {
+	choice := types.ChunkChoice{Delta: types.ChunkDelta{Content: delta.Content}}
+	if !o.started {
+		choice.Delta.Role = "assistant"
+		o.started = true
+	}
+	if delta.FinishReason != "" {
+		reason := openAIFinishReason(delta.FinishReason)
+		choice.FinishReason = &reason
+	}
+	return utils.WriteSSE(w, "", o.chunk([]types.ChunkChoice{choice}, nil))
+}
+
+func (o *openAIStream) done(w io.Writer, final *types.GeneralChatResponse) error {
+	if o.includeUsage {
+		usage := &types.Usage{
+			PromptTokens:     final.PromptTokens,
+			CompletionTokens: final.CompletionTokens,
+			TotalTokens:      final.TotalTokens,
+		}
+		if err := utils.WriteSSE(w, "", o.chunk([]types.ChunkChoice{}, usage)); err != nil {
+			return err
+		}
+	}
+	return utils.WriteSSEDone(w)
+}
+
+func (o *openAIStream) fail(w io.Writer, msg string) error {
+	return utils.WriteSSE(w, "", openAIErrorBody(fiber.StatusBadGateway, msg))
+}
+
+// openAIFinishReason maps a provider stop reason to its OpenAI equivalent
+func openAIFinishReason(reason string) string {
+	switch reason {
+	case "", "end_turn", "stop_sequence", "STOP":
+		return "stop"
+	case "max_tokens", "MAX_TOKENS":
+		return "length"
+	case "tool_use":
+		return "tool_calls"
+	default:
+		return reason
+	}
+}
+
+// openAIError responds with an OpenAI-shaped error so SDKs can surface it
+func openAIError(c *fiber.Ctx, status int, msg string) error {
+	return c.Status(status).JSON(openAIErrorBody(status, msg))
+}
+
+func openAIErrorBody(status int, msg string) fiber.Map {
+	errorType := "api_error"
+	switch status {
+	case fiber.StatusBadRequest:
+		errorType = "invalid_request_error"
+	case fiber.StatusUnauthorized:
+		errorType = "authentication_error"
+	case fiber.StatusPaymentRequired:
+		errorType = "insufficient_quota"
+	case fiber.StatusForbidden:
+		errorType = "permission_error"
+	case fiber.StatusNotFound:
+		errorType = "not_found_error"
+	case fiber.StatusTooManyRequests:
+		errorType = "rate_limit_error"
+	}
+
+	return fiber.Map{
+		"error": fiber.Map{
+			"message": msg,
+			"type":    errorType,
+			"code":    nil,
+		},
+	}
+}
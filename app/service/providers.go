@@ -0,0 +1,206 @@
+package service
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/wmbryce/agent-c/app/types"
+	"github.com/wmbryce/agent-c/app/utils"
+)
+
+// GetProvidersHealth func reports the circuit breaker state of each provider.
+// @Description Report each provider's circuit state, error rate and latencies over its recent calls.
+// @Description schema_drift counts responses that did not match their model's response schema.
+// @Description Providers that have not been called since the service started are not listed.
+// @Summary provider health
+// @Tags AI
+// @Produce json
+// @Success 200 {array} types.ProviderHealth
+// @Router /v1/ai/providers/health [get]
+func (s *Service) GetProvidersHealth(c *fiber.Ctx) error {
+	return c.JSON(fiber.Map{
+		"error":     false,
+		"msg":       nil,
+		"providers": s.breakers.health(),
+	})
+}
+
+// GetCredentialsCacheStats func reports the hit rate of the model credentials cache.
+// @Description Report how often model credentials, provider config and prices were served from memory
+// @Description instead of Postgres since the service started, and how often admin changes dropped them.
+// @Summary credentials cache stats
+// @Tags Admin
+// @Produce json
+// @Success 200 {object} types.CredentialsCacheStats
+// @Security ApiKeyAuth
+// @Router /v1/admin/credentials_cache [get]
+func (s *Service) GetCredentialsCacheStats(c *fiber.Ctx) error {
+	return c.JSON(fiber.Map{
+		"error": false,
+		"msg":   nil,
+		"stats": s.CredentialsCacheStats(),
+	})
+}
+
+// CreateProvider func adds a provider.
+// @Description Register a provider along with its auth, request and response mapping config.
+// @Summary create a provider
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Param provider body types.Provider true "Provider"
+// @Success 200 {object} types.Provider
+// @Security ApiKeyAuth
+// @Router /v1/admin/providers [post]
+func (s *Service) CreateProvider(c *fiber.Ctx) error {
+	provider := &types.Provider{}
+	if err := c.BodyParser(provider); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": true,
+			"msg":   err.Error(),
+		})
+	}
+
+	validate := utils.NewValidator()
+	if err := validate.Struct(provider); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": true,
+			"msg":   utils.ValidatorErrors(err),
+		})
+	}
+
+	created, err := s.store.CreateProvider(provider)
+	if err != nil {
+		return storeError(c, err)
+	}
+
+	return c.JSON(fiber.Map{
+		"error":    false,
+		"msg":      "Provider created successfully",
+		"provider": created,
+	})
+}
+
+// GetProviders func returns every provider.
+// @Description List all providers.
+// @Summary list providers
+// @Tags Admin
+// @Produce json
+// @Success 200 {array} types.Provider
+// @Security ApiKeyAuth
+// @Router /v1/admin/providers [get]
+func (s *Service) GetProviders(c *fiber.Ctx) error {
+	providers, err := s.store.GetProviders()
+	if err != nil {
+		return storeError(c, err)
+	}
+
+	return c.JSON(fiber.Map{
+		"error":     false,
+		"msg":       nil,
+		"providers": providers,
+	})
+}
+
+// GetProvider func returns a provider by id.
+// @Description Get a provider by id.
+// @Summary get a provider
+// @Tags Admin
+// @Produce json
+// @Param id path string true "Provider ID"
+// @Success 200 {object} types.Provider
+// @Security ApiKeyAuth
+// @Router /v1/admin/providers/{id} [get]
+func (s *Service) GetProvider(c *fiber.Ctx) error {
+	if !validID(c) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": true,
+			"msg":   "invalid provider id",
+		})
+	}
+
+	provider, err := s.store.GetProvider(c.Params("id"))
+	if err != nil {
+		return storeError(c, err)
+	}
+
+	return c.JSON(fiber.Map{
+		"error":    false,
+		"msg":      nil,
+		"provider": provider,
+	})
+}
+
+// UpdateProvider func replaces a provider.
+// @Description Replace a provider and its config. Config changes apply to the next request.
+// @Summary update a provider
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Param id path string true "Provider ID"
+// @Param provider body types.Provider true "Provider"
+// @Success 200 {object} types.Provider
+// @Security ApiKeyAuth
+// @Router /v1/admin/providers/{id} [put]
+func (s *Service) UpdateProvider(c *fiber.Ctx) error {
+	provider := &types.Provider{}
+	if err := c.BodyParser(provider); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": true,
+			"msg":   err.Error(),
+		})
+	}
+
+	validate := utils.NewValidator()
+	if err := validate.Struct(provider); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": true,
+			"msg":   utils.ValidatorErrors(err),
+		})
+	}
+	if !validID(c) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": true,
+			"msg":   "invalid provider id",
+		})
+	}
+	provider.ID = c.Params("id")
+
+	updated, err := s.store.UpdateProvider(provider)
+	if err != nil {
+		return storeError(c, err)
+	}
+	s.invalidateCredentials()
+
+	return c.JSON(fiber.Map{
+		"error":    false,
+		"msg":      "Provider updated successfully",
+		"provider": updated,
+	})
+}
+
+// DeleteProvider func removes a provider.
+// @Description Delete a provider along with its models and api keys.
+// @Summary delete a provider
+// @Tags Admin
+// @Produce json
+// @Param id path string true "Provider ID"
+// @Success 200 {object} map[string]interface{}
+// @Security ApiKeyAuth
+// @Router /v1/admin/providers/{id} [delete]
+func (s *Service) DeleteProvider(c *fiber.Ctx) error {
+	if !validID(c) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": true,
+			"msg":   "invalid provider id",
+		})
+	}
+
+	if err := s.store.DeleteProvider(c.Params("id")); err != nil {
+		return storeError(c, err)
+	}
+	s.invalidateCredentials()
+
+	return c.JSON(fiber.Map{
+		"error": false,
+		"msg":   "Provider deleted successfully",
+	})
+}
@@ -0,0 +1,74 @@
+package service
+
+import (
+	"fmt"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/wmbryce/agent-c/app/middleware"
+	"github.com/wmbryce/agent-c/app/types"
+)
+
+// tokenLimit is a tokens per minute limit a call counts against
+type tokenLimit struct {
+	scope string
+	id    string
+}
+
+// checkRateLimit counts a request against the per-minute limits of a model or seller key.
+// Requests are let through when the limiter is unavailable.
+func (s *Service) checkRateLimit(scope, id string, limit types.RateLimit) *consumeError {
+	if s.cache == nil {
+		return nil
+	}
+
+	status, err := middleware.CheckRateLimit(s.cache, scope, id, limit)
+	if err != nil {
+		s.logger.Warn().
+			Err(err).
+			Str("scope", scope).
+			Str("id", id).
+			Msg("failed to check rate limit")
+		return nil
+	}
+	if status == nil || status.Allowed {
+		return nil
+	}
+
+	return &consumeError{
+		status:    fiber.StatusTooManyRequests,
+		msg:       fmt.Sprintf("%s rate limit exceeded", scope),
+		rateLimit: status,
+	}
+}
+
+// tokenLimits returns the tokens per minute limits of the consumer, model and seller key a call counts against
+func tokenLimits(c *fiber.Ctx, creds *types.ModelCredentials) []tokenLimit {
+	limits := []tokenLimit{}
+	if consumer, ok := c.Locals(middleware.ConsumerLocalsKey).(*types.Consumer); ok && consumer.TokensPerMinute != nil {
+		limits = append(limits, tokenLimit{types.RateLimitScopeConsumer, consumer.ID})
+	}
+	if creds.ModelRateLimit.TokensPerMinute != nil {
+		limits = append(limits, tokenLimit{types.RateLimitScopeModel, creds.ModelKey})
+	}
+	if creds.KeyRateLimit.TokensPerMinute != nil {
+		limits = append(limits, tokenLimit{types.RateLimitScopeApiKey, creds.ApiKeyID})
+	}
+	return limits
+}
+
+// countTokens counts the tokens a call used against its tokens per minute limits
+func (s *Service) countTokens(limits []tokenLimit, tokens int) {
+	if s.cache == nil || tokens == 0 {
+		return
+	}
+
+	for _, limit := range limits {
+		if err := s.cache.AddRateUsage(limit.scope, limit.id, types.RateLimitTokens, tokens); err != nil {
+			s.logger.Warn().
+				Err(err).
+				Str("scope", limit.scope).
+				Str("id", limit.id).
+				Msg("failed to count rate limited tokens")
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package service
+
+import (
+	"context"
+	"os"
+	"strconv"
+	"time"
+)
+
+// defaultReservationTTL applies when RESERVATION_TTL_SECONDS is not set. It must outlast the
+// longest stream, since a reservation released while its request is still running is not charged.
+const defaultReservationTTL = time.Hour
+
+// reservationSweepInterval is how often abandoned reservations are looked for
+const reservationSweepInterval = time.Minute
+
+// reservationTTLFromEnv reads how long a reservation may stay open from RESERVATION_TTL_SECONDS
+func reservationTTLFromEnv() time.Duration {
+	seconds, err := strconv.Atoi(os.Getenv("RESERVATION_TTL_SECONDS"))
+	if err != nil || seconds <= 0 {
+		return defaultReservationTTL
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// SweepReservations releases reservations left open past their expiry, by a crash, a restart
+// mid-stream or a failed settlement, back to their seller keys. It sweeps right away and then
+// every minute until ctx is done. Every instance may sweep; each reservation is released once.
+func (s *Service) SweepReservations(ctx context.Context) {
+	ticker := time.NewTicker(reservationSweepInterval)
+	defer ticker.Stop()
+
+	for {
+		released, err := s.store.ReleaseExpiredReservations(ctx)
+		if err != nil {
+			s.logger.Error().
+				Err(err).
+				Msg("failed to release expired token reservations")
+		}
+		if released > 0 {
+			s.logger.Warn().
+				Int("released", released).
+				Msg("released expired token reservations")
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package service
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/wmbryce/agent-c/app/types"
+)
+
+// defaultResponseCacheTTL applies when RESPONSE_CACHE_TTL_SECONDS is not set
+const defaultResponseCacheTTL = time.Hour
+
+// responseCachePolicy describes how a request uses the response cache
+type responseCachePolicy struct {
+	// key is the hash of the request's model, messages and options
+	key      string
+	modelKey string
+	ttl      time.Duration
+	// lookup is unset when the caller sent Cache-Control: no-cache
+	lookup bool
+	// save is unset when the caller sent Cache-Control: no-store
+	save bool
+}
+
+// responseCacheTTLFromEnv reads the default response cache TTL from RESPONSE_CACHE_TTL_SECONDS
+func responseCacheTTLFromEnv() time.Duration {
+	seconds, err := strconv.Atoi(os.Getenv("RESPONSE_CACHE_TTL_SECONDS"))
+	if err != nil {
+		return defaultResponseCacheTTL
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// responseCachePolicy returns how the request uses the response cache, or nil when it is not
+// cacheable. Only complete responses to deterministic requests are cached: requests with
+// temperature 0, or that opt in with cache, and that do not stream.
+func (s *Service) responseCachePolicy(c *fiber.Ctx, request *types.ConsumeModelRequest, model *types.ModelCredentials) *responseCachePolicy {
+	if s.cache == nil || request.Stream || !(request.Cache || zeroTemperature(request.Options)) {
+		return nil
+	}
+
+	ttl := s.responseCacheTTL
+	if model.ResponseCacheTTL != nil {
+		ttl = time.Duration(*model.ResponseCacheTTL) * time.Second
+	}
+	if ttl <= 0 {
+		return nil
+	}
+
+	key, err := responseCacheKey(model.ModelKey, request)
+	if err != nil {
+		return nil
+	}
+
+	policy := &responseCachePolicy{key: key, modelKey: model.ModelKey, ttl: ttl, lookup: true, save: true}
+	for _, directive := range strings.Split(c.Get(fiber.HeaderCacheControl), ",") {
+		switch strings.ToLower(strings.TrimSpace(directive)) {
+		case "no-cache":
+			policy.lookup = false
+		case "no-store":
+			policy.save = false
+		}
+	}
+	return policy
+}
+
+// cachedResponse returns the cached response for the policy, or nil on a miss. A hit is not
+// charged, so its cost is zero.
+func (s *Service) cachedResponse(policy *responseCachePolicy) *types.GeneralChatResponse {
+	if !policy.lookup {
+		return nil
+	}
+
+	response, err := s.cache.GetResponse(policy.key)
+	responseCacheLookups.WithLabelValues(policy.modelKey, cacheResult(response != nil)).Inc()
+	if err != nil {
+		s.logger.Warn().Err(err).Msg("failed to read response cache")
+		return nil
+	}
+	if response != nil {
+		response.Cost = 0
+	}
+	return response
+}
+
+// saveResponse caches a provider response for the policy
+func (s *Service) saveResponse(policy *responseCachePolicy, response *types.GeneralChatResponse) {
+	if !policy.save || response == nil {
+		return
+	}
+	if err := s.cache.SaveResponse(policy.key, response, policy.ttl); err != nil {
+		s.logger.Warn().Err(err).Msg("failed to save response cache")
+	}
+}
+
+// responseCacheKey hashes the model, messages and options of a request. Options are the ones
+// sent to the provider, with the model's defaults applied, and encode with sorted keys.
+func responseCacheKey(modelKey string, request *types.ConsumeModelRequest) (string, error) {
+	canonical, err := json.Marshal(struct {
+		ModelKey string              `json:"model_key"`
+		Messages []types.ChatMessage `json:"messages"`
+		Options  map[string]any      `json:"options"`
+	}{modelKey, request.Messages, request.Options})
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(canonical)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// zeroTemperature reports whether the options set temperature to 0, whether they were
+// decoded from a consume request or built by the OpenAI and Anthropic facades
+func zeroTemperature(options map[string]any) bool {
+	switch temperature := options["temperature"].(type) {
+	case float64:
+		return temperature == 0
+	case float32:
+		return temperature == 0
+	case int:
+		return temperature == 0
+	case json.Number:
+		value, err := temperature.Float64()
+		return err == nil && value == 0
+	default:
+		return false
+	}
+}
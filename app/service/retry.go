@@ -0,0 +1,135 @@
+package service
+
+import (
+	"context"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+	"github.com/wmbryce/agent-c/app/types"
+	"github.com/wmbryce/agent-c/app/utils"
+)
+
+// defaultRetryPolicy applies to providers without a retry_policy and fills unset policy fields.
+// Only statuses that mean the provider did not process the request are retried by default.
+var defaultRetryPolicy = types.RetryPolicy{
+	MaxAttempts:   3,
+	BaseDelayMs:   250,
+	MaxDelayMs:    5000,
+	RetryStatuses: []int{http.StatusTooManyRequests, http.StatusServiceUnavailable},
+}
+
+// retryClient wraps an HTTPClient and retries transient provider statuses with jittered
+// exponential backoff, honoring Retry-After and provider rate-limit reset headers.
+//
+// To avoid billing a request twice it never retries transport errors, where the
+// provider may already have processed the request, and sends the same Idempotency-Key
+// on every attempt. All attempts share the caller's single token reservation.
+type retryClient struct {
+	next   HTTPClient
+	policy types.RetryPolicy
+	logger *zerolog.Logger
+}
+
+func newRetryClient(next HTTPClient, config *types.ProviderConfig, logger *zerolog.Logger) *retryClient {
+	policy := defaultRetryPolicy
+	if config != nil && config.RetryPolicy != nil {
+		if config.RetryPolicy.MaxAttempts > 0 {
+			policy.MaxAttempts = config.RetryPolicy.MaxAttempts
+		}
+		if config.RetryPolicy.BaseDelayMs > 0 {
+			policy.BaseDelayMs = config.RetryPolicy.BaseDelayMs
+		}
+		if config.RetryPolicy.MaxDelayMs > 0 {
+			policy.MaxDelayMs = config.RetryPolicy.MaxDelayMs
+		}
+		if len(config.RetryPolicy.RetryStatuses) > 0 {
+			policy.RetryStatuses = config.RetryPolicy.RetryStatuses
+		}
+	}
+	return &retryClient{next: next, policy: policy, logger: logger}
+}
+
+func (r *retryClient) Do(req *http.Request) (*http.Response, error) {
+	if req.Header.Get("Idempotency-Key") == "" {
+		req.Header.Set("Idempotency-Key", uuid.New().String())
+	}
+
+	for attempt := 1; ; attempt++ {
+		attemptReq := req
+		if attempt > 1 {
+			var err error
+			if attemptReq, err = rewindRequest(req); err != nil {
+				return nil, err
+			}
+		}
+
+		resp, err := r.next.Do(attemptReq)
+		if err != nil || attempt >= r.policy.MaxAttempts || !slices.Contains(r.policy.RetryStatuses, resp.StatusCode) {
+			return resp, err
+		}
+
+		delay, ok := r.backoff(attempt, resp.Header)
+		if !ok {
+			// The provider asked for a longer wait than the policy allows, let the caller fail over
+			return resp, nil
+		}
+
+		r.logger.Warn().
+			Int("status_code", resp.StatusCode).
+			Int("attempt", attempt).
+			Dur("delay", delay).
+			Msg("retrying model provider request")
+
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+
+		if err := sleepContext(req.Context(), delay); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// backoff returns the wait before the next attempt. A provider supplied wait takes
+// precedence and is rejected when it exceeds the policy's maximum delay.
+func (r *retryClient) backoff(attempt int, header http.Header) (time.Duration, bool) {
+	maxDelay := time.Duration(r.policy.MaxDelayMs) * time.Millisecond
+
+	if wait, ok := utils.RetryAfter(header, time.Now()); ok {
+		return wait, wait <= maxDelay
+	}
+
+	// Full jitter: a random wait up to the exponential step, capped at the maximum delay
+	step := time.Duration(r.policy.BaseDelayMs) * time.Millisecond << (attempt - 1)
+	step = min(step, maxDelay)
+	return time.Duration(rand.Int64N(int64(step) + 1)), true
+}
+
+// rewindRequest returns a copy of req with a fresh body for another attempt
+func rewindRequest(req *http.Request) (*http.Request, error) {
+	clone := req.Clone(req.Context())
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		clone.Body = body
+	}
+	return clone, nil
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
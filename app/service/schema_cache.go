@@ -0,0 +1,122 @@
+package service
+
+import (
+	"sync"
+	"time"
+
+	"github.com/wmbryce/agent-c/app/types"
+	"github.com/wmbryce/agent-c/app/utils"
+)
+
+// schemaCacheTTL bounds how long a compiled schema is reused before it is reloaded,
+// so schema edits take effect without a restart
+const schemaCacheTTL = 5 * time.Minute
+
+// schemaCache holds compiled model schemas by schema id
+type schemaCache struct {
+	mu      sync.Mutex
+	entries map[string]schemaEntry
+}
+
+type schemaEntry struct {
+	schema *utils.JSONSchema
+	loaded time.Time
+}
+
+func newSchemaCache() *schemaCache {
+	return &schemaCache{entries: map[string]schemaEntry{}}
+}
+
+// modelSchema returns the compiled schema with the given id, loading and compiling it on a cache miss
+func (s *Service) modelSchema(id string) (*utils.JSONSchema, error) {
+	s.schemas.mu.Lock()
+	entry, ok := s.schemas.entries[id]
+	s.schemas.mu.Unlock()
+	if ok && time.Since(entry.loaded) < schemaCacheTTL {
+		return entry.schema, nil
+	}
+
+	modelSchema, err := s.store.GetModelSchema(id)
+	if err != nil {
+		return nil, err
+	}
+	schema, err := utils.CompileJSONSchema(modelSchema.Schema)
+	if err != nil {
+		return nil, err
+	}
+
+	s.schemas.mu.Lock()
+	s.schemas.entries[id] = schemaEntry{schema: schema, loaded: time.Now()}
+	s.schemas.mu.Unlock()
+
+	return schema, nil
+}
+
+// evict drops a schema so its next use reloads it
+func (c *schemaCache) evict(id string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.entries, id)
+}
+
+// prepareOptions validates the request options against the model's options schema and
+// returns a copy with the schema defaults applied. Models without a schema accept any options.
+func (s *Service) prepareOptions(options map[string]interface{}, schemaID string) (map[string]interface{}, []utils.SchemaViolation, error) {
+	prepared := make(map[string]interface{}, len(options))
+	for k, v := range options {
+		prepared[k] = v
+	}
+	if schemaID == "" {
+		return prepared, nil, nil
+	}
+
+	schema, err := s.modelSchema(schemaID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	violations, err := schema.Validate(prepared)
+	if err != nil || len(violations) > 0 {
+		return nil, violations, err
+	}
+
+	schema.ApplyDefaults(prepared)
+	return prepared, nil, nil
+}
+
+// checkResponse validates a raw provider response body against the model's response schema.
+// Drift is logged and counted against the provider; it reports whether the response must be
+// rejected, which only happens when the service fails closed. Schema load errors never fail a call.
+func (s *Service) checkResponse(creds *types.ModelCredentials, body []byte) bool {
+	if creds.ResponseSchemaID == "" {
+		return false
+	}
+
+	schema, err := s.modelSchema(creds.ResponseSchemaID)
+	if err != nil {
+		s.logger.Error().
+			Err(err).
+			Str("model_key", creds.ModelKey).
+			Msg("failed to load response schema")
+		return false
+	}
+
+	violations, err := schema.ValidateJSON(body)
+	if err != nil {
+		violations = []utils.SchemaViolation{{Message: err.Error()}}
+	}
+	if len(violations) == 0 {
+		return false
+	}
+
+	s.breakers.get(creds.ProviderName, creds.ProviderConfig).recordDrift()
+	s.logger.Warn().
+		Str("model_key", creds.ModelKey).
+		Str("provider", creds.ProviderName).
+		Str("violations", utils.FormatViolations(violations)).
+		Bool("rejected", s.responseSchemaFailClosed).
+		Msg("provider response does not match response schema")
+
+	return s.responseSchemaFailClosed
+}
@@ -0,0 +1,187 @@
+package service
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/wmbryce/agent-c/app/types"
+	"github.com/wmbryce/agent-c/app/utils"
+)
+
+// CreateModelSchema func adds a model schema.
+// @Description Create a JSON Schema for model options or provider responses. The schema must compile.
+// @Summary create a model schema
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Param schema body types.ModelSchema true "Model schema"
+// @Success 200 {object} types.ModelSchema
+// @Security ApiKeyAuth
+// @Router /v1/admin/schemas [post]
+func (s *Service) CreateModelSchema(c *fiber.Ctx) error {
+	schema := &types.ModelSchema{}
+	if err := c.BodyParser(schema); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": true,
+			"msg":   err.Error(),
+		})
+	}
+
+	validate := utils.NewValidator()
+	if err := validate.Struct(schema); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": true,
+			"msg":   utils.ValidatorErrors(err),
+		})
+	}
+
+	if _, err := utils.CompileJSONSchema(schema.Schema); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": true,
+			"msg":   err.Error(),
+		})
+	}
+
+	created, err := s.store.CreateModelSchema(schema)
+	if err != nil {
+		return storeError(c, err)
+	}
+
+	return c.JSON(fiber.Map{
+		"error":  false,
+		"msg":    "Model schema created successfully",
+		"schema": created,
+	})
+}
+
+// GetModelSchemas func returns every model schema.
+// @Description List all model schemas.
+// @Summary list model schemas
+// @Tags Admin
+// @Produce json
+// @Success 200 {array} types.ModelSchema
+// @Security ApiKeyAuth
+// @Router /v1/admin/schemas [get]
+func (s *Service) GetModelSchemas(c *fiber.Ctx) error {
+	schemas, err := s.store.GetModelSchemas()
+	if err != nil {
+		return storeError(c, err)
+	}
+
+	return c.JSON(fiber.Map{
+		"error":   false,
+		"msg":     nil,
+		"schemas": schemas,
+	})
+}
+
+// GetModelSchema func returns a model schema by id.
+// @Description Get a model schema by id.
+// @Summary get a model schema
+// @Tags Admin
+// @Produce json
+// @Param id path string true "Model schema ID"
+// @Success 200 {object} types.ModelSchema
+// @Security ApiKeyAuth
+// @Router /v1/admin/schemas/{id} [get]
+func (s *Service) GetModelSchema(c *fiber.Ctx) error {
+	if !validID(c) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": true,
+			"msg":   "invalid model schema id",
+		})
+	}
+
+	schema, err := s.store.GetModelSchema(c.Params("id"))
+	if err != nil {
+		return storeError(c, err)
+	}
+
+	return c.JSON(fiber.Map{
+		"error":  false,
+		"msg":    nil,
+		"schema": schema,
+	})
+}
+
+// UpdateModelSchema func replaces a model schema.
+// @Description Replace a model schema. The schema must compile; it applies to the next request.
+// @Summary update a model schema
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Param id path string true "Model schema ID"
+// @Param schema body types.ModelSchema true "Model schema"
+// @Success 200 {object} types.ModelSchema
+// @Security ApiKeyAuth
+// @Router /v1/admin/schemas/{id} [put]
+func (s *Service) UpdateModelSchema(c *fiber.Ctx) error {
+	schema := &types.ModelSchema{}
+	if err := c.BodyParser(schema); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": true,
+			"msg":   err.Error(),
+		})
+	}
+
+	validate := utils.NewValidator()
+	if err := validate.Struct(schema); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": true,
+			"msg":   utils.ValidatorErrors(err),
+		})
+	}
+	if !validID(c) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": true,
+			"msg":   "invalid model schema id",
+		})
+	}
+	schema.ID = c.Params("id")
+
+	if _, err := utils.CompileJSONSchema(schema.Schema); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": true,
+			"msg":   err.Error(),
+		})
+	}
+
+	updated, err := s.store.UpdateModelSchema(schema)
+	if err != nil {
+		return storeError(c, err)
+	}
+	s.schemas.evict(updated.ID)
+
+	return c.JSON(fiber.Map{
+		"error":  false,
+		"msg":    "Model schema updated successfully",
+		"schema": updated,
+	})
+}
+
+// DeleteModelSchema func removes a model schema.
+// @Description Delete a model schema. Schemas still used by a model cannot be deleted.
+// @Summary delete a model schema
+// @Tags Admin
+// @Produce json
+// @Param id path string true "Model schema ID"
+// @Success 200 {object} map[string]interface{}
+// @Security ApiKeyAuth
+// @Router /v1/admin/schemas/{id} [delete]
+func (s *Service) DeleteModelSchema(c *fiber.Ctx) error {
+	if !validID(c) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": true,
+			"msg":   "invalid model schema id",
+		})
+	}
+
+	if err := s.store.DeleteModelSchema(c.Params("id")); err != nil {
+		return storeError(c, err)
+	}
+
+	s.schemas.evict(c.Params("id"))
+
+	return c.JSON(fiber.Map{
+		"error": false,
+		"msg":   "Model schema deleted successfully",
+	})
+}
@@ -0,0 +1,171 @@
+package service
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/wmbryce/agent-c/app/types"
+	"github.com/wmbryce/agent-c/app/utils"
+)
+
+// CreateSeller func adds a seller.
+// @Description Create a seller.
+// @Summary create a seller
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Param seller body types.Seller true "Seller"
+// @Success 200 {object} types.Seller
+// @Security ApiKeyAuth
+// @Router /v1/admin/sellers [post]
+func (s *Service) CreateSeller(c *fiber.Ctx) error {
+	seller := &types.Seller{}
+	if err := c.BodyParser(seller); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": true,
+			"msg":   err.Error(),
+		})
+	}
+
+	validate := utils.NewValidator()
+	if err := validate.Struct(seller); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": true,
+			"msg":   utils.ValidatorErrors(err),
+		})
+	}
+
+	created, err := s.store.CreateSeller(seller)
+	if err != nil {
+		return storeError(c, err)
+	}
+
+	return c.JSON(fiber.Map{
+		"error":  false,
+		"msg":    "Seller created successfully",
+		"seller": created,
+	})
+}
+
+// GetSellers func returns every seller.
+// @Description List all sellers.
+// @Summary list sellers
+// @Tags Admin
+// @Produce json
+// @Success 200 {array} types.Seller
+// @Security ApiKeyAuth
+// @Router /v1/admin/sellers [get]
+func (s *Service) GetSellers(c *fiber.Ctx) error {
+	sellers, err := s.store.GetSellers()
+	if err != nil {
+		return storeError(c, err)
+	}
+
+	return c.JSON(fiber.Map{
+		"error":   false,
+		"msg":     nil,
+		"sellers": sellers,
+	})
+}
+
+// GetSeller func returns a seller by id.
+// @Description Get a seller by id.
+// @Summary get a seller
+// @Tags Admin
+// @Produce json
+// @Param id path string true "Seller ID"
+// @Success 200 {object} types.Seller
+// @Security ApiKeyAuth
+// @Router /v1/admin/sellers/{id} [get]
+func (s *Service) GetSeller(c *fiber.Ctx) error {
+	if !validID(c) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": true,
+			"msg":   "invalid seller id",
+		})
+	}
+
+	seller, err := s.store.GetSeller(c.Params("id"))
+	if err != nil {
+		return storeError(c, err)
+	}
+
+	return c.JSON(fiber.Map{
+		"error":  false,
+		"msg":    nil,
+		"seller": seller,
+	})
+}
+
+// UpdateSeller func replaces a seller.
+// @Description Replace a seller.
+// @Summary update a seller
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Param id path string true "Seller ID"
+// @Param seller body types.Seller true "Seller"
+// @Success 200 {object} types.Seller
+// @Security ApiKeyAuth
+// @Router /v1/admin/sellers/{id} [put]
+func (s *Service) UpdateSeller(c *fiber.Ctx) error {
+	seller := &types.Seller{}
+	if err := c.BodyParser(seller); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": true,
+			"msg":   err.Error(),
+		})
+	}
+
+	validate := utils.NewValidator()
+	if err := validate.Struct(seller); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": true,
+			"msg":   utils.ValidatorErrors(err),
+		})
+	}
+	if !validID(c) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": true,
+			"msg":   "invalid seller id",
+		})
+	}
+	seller.ID = c.Params("id")
+
+	updated, err := s.store.UpdateSeller(seller)
+	if err != nil {
+		return storeError(c, err)
+	}
+
+	return c.JSON(fiber.Map{
+		"error":  false,
+		"msg":    "Seller updated successfully",
+		"seller": updated,
+	})
+}
+
+// DeleteSeller func removes a seller.
+// @Description Delete a seller along with its api keys.
+// @Summary delete a seller
+// @Tags Admin
+// @Produce json
+// @Param id path string true "Seller ID"
+// @Success 200 {object} map[string]interface{}
+// @Security ApiKeyAuth
+// @Router /v1/admin/sellers/{id} [delete]
+func (s *Service) DeleteSeller(c *fiber.Ctx) error {
+	if !validID(c) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": true,
+			"msg":   "invalid seller id",
+		})
+	}
+
+	if err := s.store.DeleteSeller(c.Params("id")); err != nil {
+		return storeError(c, err)
+	}
+	s.invalidateCredentials()
+
+	return c.JSON(fiber.Map{
+		"error": false,
+		"msg":   "Seller deleted successfully",
+	})
+}
@@ -0,0 +1,181 @@
+package service
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/wmbryce/agent-c/app/types"
+	"github.com/wmbryce/agent-c/app/utils"
+)
+
+// streamFormat writes a relayed provider stream in the caller's wire format
+type streamFormat interface {
+	// delta writes one content delta
+	delta(w io.Writer, delta *types.GeneralChatResponse) error
+	// done writes the end of the stream, including the final usage
+	done(w io.Writer, final *types.GeneralChatResponse) error
+	// fail reports a stream that broke off before the provider finished
+	fail(w io.Writer, msg string) error
+}
+
+// nativeStream is the ConsumeModel format: GeneralChatResponse delta events, followed by a usage event
+type nativeStream struct{}
+
+func (nativeStream) delta(w io.Writer, delta *types.GeneralChatResponse) error {
+	return utils.WriteSSE(w, "delta", delta)
+}
+
+func (nativeStream) done(w io.Writer, final *types.GeneralChatResponse) error {
+	return utils.WriteSSE(w, "usage", final)
+}
+
+func (nativeStream) fail(w io.Writer, msg string) error {
+	return utils.WriteSSE(w, "error", fiber.Map{
+		"error": true,
+		"msg":   msg,
+	})
+}
+
+// streamResponse proxies the provider's SSE stream to the caller in the given format.
+// The call is completed once the provider ends the stream.
+func (s *Service) streamResponse(c *fiber.Ctx, result *consumeResult, format streamFormat) error {
+	resp, call := result.stream, result.call
+
+	c.Set("Content-Type", "text/event-stream")
+	c.Set("Cache-Control", "no-cache")
+	c.Set("Connection", "keep-alive")
+
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		defer resp.Body.Close()
+
+		final, err := s.relayStream(resp.Body, result.creds.ProviderConfig, func(delta *types.GeneralChatResponse) error {
+			if err := format.delta(w, delta); err != nil {
+				return err
+			}
+			return w.Flush()
+		})
+
+		// Charge for the completion relayed so far, even if the stream broke off before its usage
+		charged := streamedUsage(call, final, err)
+		s.completeCall(call, charged)
+
+		if err != nil {
+			s.logger.Error().
+				Err(err).
+				Msg("failed to relay provider stream")
+			_ = format.fail(w, "provider stream interrupted")
+			_ = w.Flush()
+			return
+		}
+
+		_ = format.done(w, charged)
+		_ = w.Flush()
+	})
+
+	return nil
+}
+
+// streamedUsage returns the usage to charge for a relayed stream. Providers report completion
+// usage in their last events, so a stream that broke off or never reported usage is charged
+// at least the estimated prompt and the tokens of the content already relayed, within the
+// reservation.
+func streamedUsage(call *consumeCall, final *types.GeneralChatResponse, err error) *types.GeneralChatResponse {
+	if err == nil && final.TotalTokens > 0 {
+		return final
+	}
+
+	usage := *final
+	usage.PromptTokens = max(final.PromptTokens, call.promptTokens)
+	usage.CompletionTokens = max(final.CompletionTokens, utils.EstimateTextTokens(final.Content))
+	usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
+	if reserved := call.reservation.ReservedTokens; usage.TotalTokens > reserved && final.TotalTokens <= reserved {
+		usage.CompletionTokens = max(reserved-usage.PromptTokens, 0)
+		usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
+	}
+	return &usage
+}
+
+// relayStream reads provider SSE events from body, calls emit with each content delta
+// and returns the accumulated response once the provider ends the stream.
+// On error the response accumulated so far is returned alongside it.
+func (s *Service) relayStream(body io.Reader, config *types.ProviderConfig, emit func(delta *types.GeneralChatResponse) error) (*types.GeneralChatResponse, error) {
+	var mapping map[string]string
+	if config != nil {
+		mapping = config.StreamMapping
+	}
+
+	final := &types.GeneralChatResponse{}
+
+	err := utils.ReadSSE(body, func(event utils.SSEEvent) error {
+		if event.Event == "error" {
+			return fmt.Errorf("provider stream error: %s", event.Data)
+		}
+
+		delta, err := parseStreamChunk([]byte(event.Data), mapping)
+		if err != nil {
+			return err
+		}
+
+		mergeStreamDelta(final, delta)
+
+		// Only forward chunks that carry content or end the message
+		if delta.Content == "" && delta.FinishReason == "" {
+			return nil
+		}
+
+		return emit(&types.GeneralChatResponse{
+			ID:           final.ID,
+			Model:        final.Model,
+			Role:         final.Role,
+			Content:      delta.Content,
+			FinishReason: delta.FinishReason,
+		})
+	})
+
+	// Calculate total_tokens if the provider does not report it
+	if final.TotalTokens < final.PromptTokens+final.CompletionTokens {
+		final.TotalTokens = final.PromptTokens + final.CompletionTokens
+	}
+
+	return final, err
+}
+
+// parseStreamChunk converts a single provider stream chunk into a partial GeneralChatResponse
+func parseStreamChunk(data []byte, mapping map[string]string) (*types.GeneralChatResponse, error) {
+	if len(mapping) > 0 {
+		return utils.TransformResponse(data, mapping)
+	}
+
+	// Fallback: try to parse as GeneralChatResponse directly
+	delta := &types.GeneralChatResponse{}
+	if err := json.Unmarshal(data, delta); err != nil {
+		return nil, err
+	}
+	return delta, nil
+}
+
+// mergeStreamDelta folds a stream chunk into the accumulated response
+func mergeStreamDelta(final, delta *types.GeneralChatResponse) {
+	if delta.ID != "" {
+		final.ID = delta.ID
+	}
+	if delta.Model != "" {
+		final.Model = delta.Model
+	}
+	if delta.Role != "" {
+		final.Role = delta.Role
+	}
+	if delta.FinishReason != "" {
+		final.FinishReason = delta.FinishReason
+	}
+	final.Content += delta.Content
+
+	// Providers report usage cumulatively, so keep the largest value seen
+	final.PromptTokens = max(final.PromptTokens, delta.PromptTokens)
+	final.CachedTokens = max(final.CachedTokens, delta.CachedTokens)
+	final.CompletionTokens = max(final.CompletionTokens, delta.CompletionTokens)
+	final.TotalTokens = max(final.TotalTokens, delta.TotalTokens)
+}
@@ -0,0 +1,206 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+	"github.com/wmbryce/agent-c/app/service"
+	"github.com/wmbryce/agent-c/app/types"
+	"github.com/wmbryce/agent-c/app/utils"
+)
+
+// adminApp serves the admin handlers without authentication
+func adminApp(store *MockStore, httpClient *MockHTTPClient) *fiber.App {
+	logger := zerolog.Nop()
+	app := fiber.New()
+	svc := service.New(&logger, store, nil, app, httpClient)
+
+	app.Post("/api/v1/ai/consume", svc.ConsumeModel)
+	admin := app.Group("/api/v1/admin")
+	admin.Post("/providers", svc.CreateProvider)
+	admin.Get("/providers/:id", svc.GetProvider)
+	admin.Delete("/providers/:id", svc.DeleteProvider)
+	admin.Post("/schemas", svc.CreateModelSchema)
+	admin.Put("/schemas/:id", svc.UpdateModelSchema)
+	admin.Post("/sellers", svc.CreateSeller)
+	admin.Post("/api_keys", svc.CreateApiKey)
+	admin.Get("/api_keys", svc.GetApiKeys)
+	return app
+}
+
+func adminRequest(t *testing.T, app *fiber.App, method, path string, body any) (int, map[string]any) {
+	t.Helper()
+
+	var reader io.Reader
+	if body != nil {
+		payload, _ := json.Marshal(body)
+		reader = bytes.NewReader(payload)
+	}
+	req := httptest.NewRequest(method, path, reader)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatalf("failed to execute request: %v", err)
+	}
+	var result map[string]any
+	json.NewDecoder(resp.Body).Decode(&result)
+	return resp.StatusCode, result
+}
+
+func TestAdminProviders(t *testing.T) {
+	store := &MockStore{}
+	app := adminApp(store, &MockHTTPClient{})
+
+	provider := types.Provider{
+		Name:        "Mistral",
+		Description: "Mistral AI models",
+		EndpointURL: "https://api.mistral.ai/v1",
+		ProviderConfig: types.ProviderConfig{
+			AuthType: "api_key",
+		},
+	}
+
+	// api_key auth needs the header to send the key in
+	status, _ := adminRequest(t, app, "POST", "/api/v1/admin/providers", provider)
+	if status != 400 {
+		t.Errorf("expected 400 without auth_header, got %d", status)
+	}
+
+	provider.AuthHeader = "x-api-key"
+	status, result := adminRequest(t, app, "POST", "/api/v1/admin/providers", provider)
+	if status != 200 {
+		t.Fatalf("expected 200, got %d: %v", status, result)
+	}
+	id := result["provider"].(map[string]any)["id"].(string)
+
+	tests := []struct {
+		method         string
+		path           string
+		expectedStatus int
+	}{
+		{"GET", "/api/v1/admin/providers/" + id, 200},
+		{"GET", "/api/v1/admin/providers/" + uuid.New().String(), 404},
+		{"GET", "/api/v1/admin/providers/not-a-uuid", 400},
+		{"DELETE", "/api/v1/admin/providers/" + id, 200},
+		{"DELETE", "/api/v1/admin/providers/" + id, 404},
+	}
+	for _, tt := range tests {
+		if status, _ := adminRequest(t, app, tt.method, tt.path, nil); status != tt.expectedStatus {
+			t.Errorf("%s %s: expected %d, got %d", tt.method, tt.path, tt.expectedStatus, status)
+		}
+	}
+}
+
+func TestAdminSellersAndApiKeys(t *testing.T) {
+	providerID := uuid.New().String()
+	store := &MockStore{Providers: map[string]types.Provider{providerID: {ID: providerID}}}
+	app := adminApp(store, &MockHTTPClient{})
+
+	wallet := "0x71C7656EC7ab88b098defB751B7401B5f6d8976F"
+	status, result := adminRequest(t, app, "POST", "/api/v1/admin/sellers", types.Seller{WalletAddress: wallet})
+	if status != 200 {
+		t.Fatalf("expected 200, got %d: %v", status, result)
+	}
+	sellerID := result["seller"].(map[string]any)["id"].(string)
+
+	tests := []struct {
+		name           string
+		path           string
+		body           any
+		expectedStatus int
+	}{
+		{"duplicate wallet", "/api/v1/admin/sellers", types.Seller{WalletAddress: wallet}, 409},
+		{"invalid wallet", "/api/v1/admin/sellers", types.Seller{WalletAddress: "not-a-wallet"}, 400},
+		{"missing key", "/api/v1/admin/api_keys", types.ApiKey{ProviderID: providerID, SellerID: sellerID}, 400},
+		{"negative balance", "/api/v1/admin/api_keys", types.ApiKey{ApiKey: "sk", TokensAvailable: -1, ProviderID: providerID, SellerID: sellerID}, 400},
+		{"unknown provider", "/api/v1/admin/api_keys", types.ApiKey{ApiKey: "sk", ProviderID: uuid.New().String(), SellerID: sellerID}, 400},
+		{"valid key", "/api/v1/admin/api_keys", types.ApiKey{ApiKey: "sk-secret", TokensAvailable: 1000, ProviderID: providerID, SellerID: sellerID}, 200},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if status, result := adminRequest(t, app, "POST", tt.path, tt.body); status != tt.expectedStatus {
+				t.Errorf("expected %d, got %d: %v", tt.expectedStatus, status, result)
+			}
+		})
+	}
+
+	// Stored keys are never returned
+	resp, err := app.Test(httptest.NewRequest("GET", "/api/v1/admin/api_keys", nil))
+	if err != nil {
+		t.Fatalf("failed to execute request: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	if bytes.Contains(body, []byte("sk-secret")) {
+		t.Errorf("expected api keys to be write-only, got %s", body)
+	}
+	if !bytes.Contains(body, []byte(`"last4":"cret"`)) || !bytes.Contains(body, []byte(utils.KeyFingerprint("sk-secret"))) {
+		t.Errorf("expected the key fingerprint and last four characters, got %s", body)
+	}
+
+	// Keys are stored sealed under the master key
+	ring, _ := utils.NewKeyRing(testMasterKeys)
+	for _, key := range store.ApiKeys {
+		if key.ApiKey != "" {
+			t.Errorf("expected the plaintext key not to reach the store")
+		}
+		if opened, err := ring.Open(key.Sealed); err != nil || opened != "sk-secret" {
+			t.Errorf("expected the stored key to open to sk-secret, got %q, %v", opened, err)
+		}
+	}
+}
+
+func TestAdminSchemaUpdateAppliesToNextRequest(t *testing.T) {
+	success := `{"choices": [{"message": {"content": "Hi"}}], "usage": {"prompt_tokens": 7, "completion_tokens": 5}}`
+
+	creds := testKey("key-1", "sk-1")
+	store := &MockStore{Creds: &creds}
+	httpClient := &MockHTTPClient{Responses: []*http.Response{providerResponse(200, nil, success)}}
+	app := adminApp(store, httpClient)
+
+	status, _ := adminRequest(t, app, "POST", "/api/v1/admin/schemas", types.ModelSchema{
+		Type:   types.SchemaTypeOptions,
+		Name:   "broken",
+		Schema: json.RawMessage(`{"type": 5}`),
+	})
+	if status != 400 {
+		t.Errorf("expected 400 for a schema that does not compile, got %d", status)
+	}
+
+	schema := types.ModelSchema{
+		Type:   types.SchemaTypeOptions,
+		Name:   "options",
+		Schema: json.RawMessage(`{"type": "object", "properties": {"temperature": {"maximum": 1}}}`),
+	}
+	status, result := adminRequest(t, app, "POST", "/api/v1/admin/schemas", schema)
+	if status != 200 {
+		t.Fatalf("expected 200, got %d: %v", status, result)
+	}
+	creds.OptionsSchemaID = result["schema"].(map[string]any)["id"].(string)
+
+	consume := types.ConsumeModelRequest{
+		ModelKey: "gpt-4",
+		Messages: []types.ChatMessage{{Role: "user", Content: "Hello"}},
+		Options:  map[string]interface{}{"temperature": 1.5},
+		MaxCost:  1,
+	}
+	if status, _ := adminRequest(t, app, "POST", "/api/v1/ai/consume", consume); status != 400 {
+		t.Fatalf("expected 400 before the schema update, got %d", status)
+	}
+
+	// The cached schema is dropped on update
+	schema.Schema = json.RawMessage(`{"type": "object", "properties": {"temperature": {"maximum": 2}}}`)
+	if status, _ := adminRequest(t, app, "PUT", "/api/v1/admin/schemas/"+creds.OptionsSchemaID, schema); status != 200 {
+		t.Fatalf("expected 200, got %d", status)
+	}
+	if status, result := adminRequest(t, app, "POST", "/api/v1/ai/consume", consume); status != 200 {
+		t.Errorf("expected 200 after the schema update, got %d: %v", status, result)
+	}
+}
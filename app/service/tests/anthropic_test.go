@@ -0,0 +1,256 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/rs/zerolog"
+	"github.com/wmbryce/agent-c/app/service"
+	"github.com/wmbryce/agent-c/app/types"
+	"github.com/wmbryce/agent-c/app/utils"
+)
+
+// openAIStreamCreds serves a model through an OpenAI-style provider that can stream
+func openAIStreamCreds() *types.ModelCredentials {
+	creds := testKey("api-key-id", "sk-test-key")
+	creds.ProviderConfig.StreamMapping = map[string]string{
+		"id":                "$.id",
+		"content":           "$.choices[0].delta.content",
+		"finish_reason":     "$.choices[0].finish_reason",
+		"prompt_tokens":     "$.usage.prompt_tokens",
+		"completion_tokens": "$.usage.completion_tokens",
+		"total_tokens":      "$.usage.total_tokens",
+	}
+	creds.ProviderConfig.ResponseMapping["finish_reason"] = "$.choices[0].finish_reason"
+	return &creds
+}
+
+func TestMessages(t *testing.T) {
+	logger := zerolog.Nop()
+
+	store := &MockStore{Creds: openAIStreamCreds()}
+	httpClient := &MockHTTPClient{Response: &http.Response{
+		StatusCode: 200,
+		Body: io.NopCloser(strings.NewReader(
+			`{"choices": [{"message": {"content": "Hi"}, "finish_reason": "length"}], "usage": {"prompt_tokens": 7, "completion_tokens": 5}}`,
+		)),
+	}}
+
+	app := fiber.New()
+	svc := service.New(&logger, store, nil, app, httpClient)
+	app.Post("/v1/messages", svc.Messages)
+
+	req := httptest.NewRequest("POST", "/v1/messages", strings.NewReader(`{
+		"model": "gpt-4",
+		"max_tokens": 100,
+		"system": "Be brief.",
+		"messages": [
+			{"role": "user", "content": "Hello"},
+			{"role": "assistant", "content": [{"type": "text", "text": "Hi!"}]},
+			{"role": "user", "content": [{"type": "text", "text": "How are"}, {"type": "text", "text": "you?"}]}
+		],
+		"stop_sequences": ["END"]
+	}`))
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatalf("failed to execute request: %v", err)
+	}
+	if resp.StatusCode != 200 {
+		t.Fatalf("expected status 200, got %d", resp.StatusCode)
+	}
+
+	var message types.AnthropicMessagesResponse
+	if err := json.NewDecoder(resp.Body).Decode(&message); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if message.Type != "message" || message.Role != "assistant" || message.Model != "gpt-4" || !strings.HasPrefix(message.ID, "msg_") {
+		t.Errorf("unexpected message: %+v", message)
+	}
+	if len(message.Content) != 1 || message.Content[0].Type != "text" || message.Content[0].Text != "Hi" {
+		t.Errorf("unexpected content: %+v", message.Content)
+	}
+	if message.StopReason != "max_tokens" {
+		t.Errorf("expected stop_reason max_tokens, got %q", message.StopReason)
+	}
+	if message.Usage != (types.AnthropicUsage{InputTokens: 7, OutputTokens: 5}) {
+		t.Errorf("unexpected usage: %+v", message.Usage)
+	}
+
+	// The system prompt becomes a leading system message and content blocks are flattened
+	var sent struct {
+		Messages  []types.ChatMessage `json:"messages"`
+		MaxTokens int                 `json:"max_tokens"`
+		Stop      []string            `json:"stop"`
+	}
+	payload, _ := io.ReadAll(httpClient.Requests[0].Body)
+	json.Unmarshal(payload, &sent)
+	expected := []types.ChatMessage{
+		{Role: "system", Content: "Be brief."},
+		{Role: "user", Content: "Hello"},
+		{Role: "assistant", Content: "Hi!"},
+		{Role: "user", Content: "How are\nyou?"},
+	}
+	if len(sent.Messages) != len(expected) {
+		t.Fatalf("expected %d messages, got %s", len(expected), payload)
+	}
+	for i := range expected {
+		if sent.Messages[i] != expected[i] {
+			t.Errorf("message %d: expected %+v, got %+v", i, expected[i], sent.Messages[i])
+		}
+	}
+	if sent.MaxTokens != 100 || len(sent.Stop) != 1 || sent.Stop[0] != "END" {
+		t.Errorf("expected max_tokens and stop forwarded, got %s", payload)
+	}
+}
+
+func TestMessagesStream(t *testing.T) {
+	logger := zerolog.Nop()
+
+	store := &MockStore{Creds: openAIStreamCreds()}
+	httpClient := &MockHTTPClient{Response: &http.Response{
+		StatusCode: 200,
+		Body: io.NopCloser(strings.NewReader(
+			"data: {\"id\":\"chatcmpl-1\",\"choices\":[{\"delta\":{\"role\":\"assistant\",\"content\":\"\"}}]}\n\n" +
+				"data: {\"id\":\"chatcmpl-1\",\"choices\":[{\"delta\":{\"content\":\"Hi \"}}]}\n\n" +
+				"data: {\"id\":\"chatcmpl-1\",\"choices\":[{\"delta\":{\"content\":\"there!\"}}]}\n\n" +
+				"data: {\"id\":\"chatcmpl-1\",\"choices\":[{\"delta\":{},\"finish_reason\":\"stop\"}]}\n\n" +
+				"data: {\"id\":\"chatcmpl-1\",\"choices\":[],\"usage\":{\"prompt_tokens\":10,\"completion_tokens\":3,\"total_tokens\":13}}\n\n" +
+				"data: [DONE]\n\n",
+		)),
+	}}
+
+	app := fiber.New()
+	svc := service.New(&logger, store, nil, app, httpClient)
+	app.Post("/v1/messages", svc.Messages)
+
+	req := httptest.NewRequest("POST", "/v1/messages", strings.NewReader(
+		`{"model": "gpt-4", "max_tokens": 100, "stream": true, "messages": [{"role": "user", "content": "Hello"}]}`,
+	))
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatalf("failed to execute request: %v", err)
+	}
+	if resp.StatusCode != 200 {
+		t.Fatalf("expected status 200, got %d", resp.StatusCode)
+	}
+
+	raw, _ := io.ReadAll(resp.Body)
+	var events []string
+	var text, stopReason string
+	var usage types.AnthropicUsage
+	err = utils.ReadSSE(bytes.NewReader(raw), func(event utils.SSEEvent) error {
+		events = append(events, event.Event)
+
+		var data struct {
+			Type  string `json:"type"`
+			Delta struct {
+				Text       string `json:"text"`
+				StopReason string `json:"stop_reason"`
+			} `json:"delta"`
+			Usage types.AnthropicUsage `json:"usage"`
+		}
+		if err := json.Unmarshal([]byte(event.Data), &data); err != nil {
+			return err
+		}
+		if data.Type != event.Event {
+			t.Errorf("expected payload type %q, got %q", event.Event, data.Type)
+		}
+		switch event.Event {
+		case "content_block_delta":
+			text += data.Delta.Text
+		case "message_delta":
+			stopReason = data.Delta.StopReason
+			usage = data.Usage
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("failed to read stream: %v", err)
+	}
+
+	expectedEvents := "message_start,content_block_start,content_block_delta,content_block_delta,content_block_stop,message_delta,message_stop"
+	if strings.Join(events, ",") != expectedEvents {
+		t.Errorf("expected events %s, got %s", expectedEvents, strings.Join(events, ","))
+	}
+	if text != "Hi there!" || stopReason != "end_turn" {
+		t.Errorf("expected %q ending with end_turn, got %q ending with %q", "Hi there!", text, stopReason)
+	}
+	if usage != (types.AnthropicUsage{InputTokens: 10, OutputTokens: 3}) {
+		t.Errorf("unexpected usage: %+v", usage)
+	}
+}
+
+func TestMessagesError(t *testing.T) {
+	logger := zerolog.Nop()
+
+	tests := []struct {
+		name           string
+		body           string
+		expectedStatus int
+		expectedType   string
+	}{
+		{
+			name:           "missing max_tokens",
+			body:           `{"model": "gpt-4", "messages": [{"role": "user", "content": "Hello"}]}`,
+			expectedStatus: 400,
+			expectedType:   "invalid_request_error",
+		},
+		{
+			name:           "system role in messages",
+			body:           `{"model": "gpt-4", "max_tokens": 10, "messages": [{"role": "system", "content": "Hello"}]}`,
+			expectedStatus: 400,
+			expectedType:   "invalid_request_error",
+		},
+		{
+			name:           "unknown model",
+			body:           `{"model": "unknown", "max_tokens": 10, "messages": [{"role": "user", "content": "Hello"}]}`,
+			expectedStatus: 404,
+			expectedType:   "not_found_error",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &MockStore{ModelCreds: map[string][]types.ModelCredentials{"gpt-4": {*openAIStreamCreds()}}}
+
+			app := fiber.New()
+			svc := service.New(&logger, store, nil, app, &MockHTTPClient{})
+			app.Post("/v1/messages", svc.Messages)
+
+			req := httptest.NewRequest("POST", "/v1/messages", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatalf("failed to execute request: %v", err)
+			}
+			if resp.StatusCode != tt.expectedStatus {
+				t.Errorf("expected status %d, got %d", tt.expectedStatus, resp.StatusCode)
+			}
+
+			var body struct {
+				Type  string `json:"type"`
+				Error struct {
+					Type    string `json:"type"`
+					Message string `json:"message"`
+				} `json:"error"`
+			}
+			if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+				t.Fatalf("failed to decode error: %v", err)
+			}
+			if body.Type != "error" || body.Error.Type != tt.expectedType || body.Error.Message == "" {
+				t.Errorf("expected %s error, got %+v", tt.expectedType, body)
+			}
+		})
+	}
+}
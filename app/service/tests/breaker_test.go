@@ -0,0 +1,99 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/rs/zerolog"
+	"github.com/wmbryce/agent-c/app/service"
+	"github.com/wmbryce/agent-c/app/types"
+)
+
+func TestProviderCircuitBreaker(t *testing.T) {
+	logger := zerolog.Nop()
+	success := `{"choices": [{"message": {"content": "Hi"}}], "usage": {"prompt_tokens": 7, "completion_tokens": 5}}`
+
+	creds := testKey("key-1", "sk-one")
+	creds.ProviderName = "OpenAI"
+	creds.ProviderConfig.CircuitBreaker = &types.CircuitBreakerConfig{
+		FailureThreshold: 2,
+		OpenDurationMs:   50,
+	}
+	store := &MockStore{Creds: &creds}
+	httpClient := &MockHTTPClient{Responses: []*http.Response{
+		providerResponse(500, nil, `{"error": "down"}`),
+		providerResponse(502, nil, `{"error": "down"}`),
+		providerResponse(200, nil, success),
+	}}
+
+	app := fiber.New()
+	svc := service.New(&logger, store, nil, app, httpClient)
+	app.Post("/api/v1/ai/consume", svc.ConsumeModel)
+	app.Get("/api/v1/ai/providers/health", svc.GetProvidersHealth)
+
+	consume := func() int {
+		body, _ := json.Marshal(types.ConsumeModelRequest{
+			ModelKey: "gpt-4",
+			Messages: []types.ChatMessage{{Role: "user", Content: "Hello"}},
+			MaxCost:  100,
+		})
+		req := httptest.NewRequest("POST", "/api/v1/ai/consume", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatalf("failed to execute request: %v", err)
+		}
+		return resp.StatusCode
+	}
+	health := func() types.ProviderHealth {
+		resp, err := app.Test(httptest.NewRequest("GET", "/api/v1/ai/providers/health", nil))
+		if err != nil {
+			t.Fatalf("failed to execute request: %v", err)
+		}
+		var result struct {
+			Providers []types.ProviderHealth `json:"providers"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+			t.Fatalf("failed to decode health: %v", err)
+		}
+		if len(result.Providers) != 1 {
+			t.Fatalf("expected 1 provider, got %d", len(result.Providers))
+		}
+		return result.Providers[0]
+	}
+
+	// Two consecutive provider failures open the circuit
+	for _, expected := range []int{500, 502} {
+		if status := consume(); status != expected {
+			t.Fatalf("expected status %d, got %d", expected, status)
+		}
+	}
+	if status := consume(); status != fiber.StatusServiceUnavailable {
+		t.Fatalf("expected open circuit to return 503, got %d", status)
+	}
+	if len(httpClient.Requests) != 2 || len(store.Reserved) != 2 {
+		t.Errorf("expected the open circuit to skip the provider, got %d requests and %d reservations",
+			len(httpClient.Requests), len(store.Reserved))
+	}
+
+	h := health()
+	if h.Provider != "OpenAI" || h.State != types.CircuitOpen || h.ErrorRate != 1 || h.RecentRequests != 2 {
+		t.Errorf("unexpected health while open: %+v", h)
+	}
+
+	// After the open duration a successful probe closes the circuit
+	time.Sleep(60 * time.Millisecond)
+	if status := consume(); status != 200 {
+		t.Fatalf("expected half-open probe to succeed, got %d", status)
+	}
+
+	h = health()
+	if h.State != types.CircuitClosed || h.ConsecutiveFailures != 0 || h.RecentRequests != 3 {
+		t.Errorf("unexpected health after recovery: %+v", h)
+	}
+}
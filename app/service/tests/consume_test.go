@@ -7,12 +7,14 @@ import (
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
 	"strings"
 	"testing"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/rs/zerolog"
 	"github.com/wmbryce/agent-c/app/service"
+	"github.com/wmbryce/agent-c/app/store"
 	"github.com/wmbryce/agent-c/app/types"
 )
 
@@ -24,11 +26,15 @@ func TestConsumeModel(t *testing.T) {
 		requestBody    interface{}
 		mockCreds      *types.ModelCredentials
 		mockCredsErr   error
+		mockReserveErr error
 		mockHTTPResp   *http.Response
 		mockHTTPErr    error
 		expectedStatus int
 		expectedError  bool
 		expectedMsg    string
+		// Expected token accounting
+		expectedSettled  []int
+		expectedReleased int
 	}{
 		{
 			name: "successful request",
@@ -42,9 +48,18 @@ func TestConsumeModel(t *testing.T) {
 			mockCreds: &types.ModelCredentials{
 				ModelKey:        "gpt-4",
 				RequestURL:      "https://api.openai.com/v1/chat/completions",
-				ApiKey:          "sk-test-key",
-				TokensAvailable: 1000,
+				SealedKey:       sealKey("sk-test-key"),
+				TokensAvailable: 10000,
 				ProviderName:    "openai",
+				Price:           testPrice,
+				ProviderConfig: &types.ProviderConfig{
+					ResponseMapping: map[string]string{
+						"content":           "$.choices[0].message.content",
+						"prompt_tokens":     "$.usage.prompt_tokens",
+						"completion_tokens": "$.usage.completion_tokens",
+						"total_tokens":      "$.usage.total_tokens",
+					},
+				},
 			},
 			mockHTTPResp: &http.Response{
 				StatusCode: 200,
@@ -57,8 +72,9 @@ func TestConsumeModel(t *testing.T) {
 					"usage": {"prompt_tokens": 10, "completion_tokens": 20, "total_tokens": 30}
 				}`)),
 			},
-			expectedStatus: 200,
-			expectedError:  false,
+			expectedStatus:  200,
+			expectedError:   false,
+			expectedSettled: []int{30},
 		},
 		{
 			name:           "invalid request body",
@@ -101,16 +117,39 @@ func TestConsumeModel(t *testing.T) {
 			mockCreds: &types.ModelCredentials{
 				ModelKey:        "gpt-4",
 				RequestURL:      "https://api.openai.com/v1/chat/completions",
-				ApiKey:          "sk-test-key",
-				TokensAvailable: 100, // less than MaxCost
+				SealedKey:       sealKey("sk-test-key"),
+				TokensAvailable: 100, // less than the worst-case token usage
 				ProviderName:    "openai",
+				Price:           testPrice,
 			},
 			expectedStatus: 402,
 			expectedError:  true,
 			expectedMsg:    "insufficient tokens available",
 		},
 		{
-			name: "provider unreachable",
+			name: "estimated cost exceeds max cost",
+			requestBody: types.ConsumeModelRequest{
+				ModelKey: "gpt-4",
+				Messages: []types.ChatMessage{
+					{Role: "user", Content: "Hello"},
+				},
+				Options: map[string]interface{}{"max_tokens": 4000},
+				MaxCost: 0.01,
+			},
+			mockCreds: &types.ModelCredentials{
+				ModelKey:        "gpt-4",
+				RequestURL:      "https://api.openai.com/v1/chat/completions",
+				SealedKey:       sealKey("sk-test-key"),
+				TokensAvailable: 10000,
+				ProviderName:    "openai",
+				Price:           testPrice,
+			},
+			expectedStatus: 402,
+			expectedError:  true,
+			expectedMsg:    "estimated cost 0.240300 USD exceeds max_cost",
+		},
+		{
+			name: "model without price",
 			requestBody: types.ConsumeModelRequest{
 				ModelKey: "gpt-4",
 				Messages: []types.ChatMessage{
@@ -121,14 +160,58 @@ func TestConsumeModel(t *testing.T) {
 			mockCreds: &types.ModelCredentials{
 				ModelKey:        "gpt-4",
 				RequestURL:      "https://api.openai.com/v1/chat/completions",
-				ApiKey:          "sk-test-key",
-				TokensAvailable: 1000,
+				SealedKey:       sealKey("sk-test-key"),
+				TokensAvailable: 10000,
 				ProviderName:    "openai",
 			},
-			mockHTTPErr:    errors.New("connection refused"),
-			expectedStatus: 502,
+			expectedStatus: 500,
 			expectedError:  true,
-			expectedMsg:    "failed to reach model provider",
+			expectedMsg:    "model has no price configured",
+		},
+		{
+			name: "reservation rejected by concurrent debit",
+			requestBody: types.ConsumeModelRequest{
+				ModelKey: "gpt-4",
+				Messages: []types.ChatMessage{
+					{Role: "user", Content: "Hello"},
+				},
+				MaxCost: 100,
+			},
+			mockCreds: &types.ModelCredentials{
+				ModelKey:        "gpt-4",
+				RequestURL:      "https://api.openai.com/v1/chat/completions",
+				SealedKey:       sealKey("sk-test-key"),
+				TokensAvailable: 10000,
+				ProviderName:    "openai",
+				Price:           testPrice,
+			},
+			mockReserveErr: store.ErrInsufficientTokens,
+			expectedStatus: 402,
+			expectedError:  true,
+			expectedMsg:    "insufficient tokens available",
+		},
+		{
+			name: "provider unreachable",
+			requestBody: types.ConsumeModelRequest{
+				ModelKey: "gpt-4",
+				Messages: []types.ChatMessage{
+					{Role: "user", Content: "Hello"},
+				},
+				MaxCost: 100,
+			},
+			mockCreds: &types.ModelCredentials{
+				ModelKey:        "gpt-4",
+				RequestURL:      "https://api.openai.com/v1/chat/completions",
+				SealedKey:       sealKey("sk-test-key"),
+				TokensAvailable: 10000,
+				ProviderName:    "openai",
+				Price:           testPrice,
+			},
+			mockHTTPErr:      errors.New("connection refused"),
+			expectedStatus:   502,
+			expectedError:    true,
+			expectedMsg:      "failed to reach model provider",
+			expectedReleased: 1,
 		},
 		{
 			name: "invalid provider response",
@@ -142,9 +225,10 @@ func TestConsumeModel(t *testing.T) {
 			mockCreds: &types.ModelCredentials{
 				ModelKey:        "gpt-4",
 				RequestURL:      "https://api.openai.com/v1/chat/completions",
-				ApiKey:          "sk-test-key",
-				TokensAvailable: 1000,
+				SealedKey:       sealKey("sk-test-key"),
+				TokensAvailable: 10000,
 				ProviderName:    "openai",
+				Price:           testPrice,
 			},
 			mockHTTPResp: &http.Response{
 				StatusCode: 200,
@@ -153,6 +237,8 @@ func TestConsumeModel(t *testing.T) {
 			expectedStatus: 500,
 			expectedError:  true,
 			expectedMsg:    "failed to parse provider response",
+			// The provider served the request, so the whole reservation is charged
+			expectedSettled: []int{4106},
 		},
 	}
 
@@ -160,8 +246,9 @@ func TestConsumeModel(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			// Create mock store
 			store := &MockStore{
-				Creds:    tt.mockCreds,
-				CredsErr: tt.mockCredsErr,
+				Creds:      tt.mockCreds,
+				CredsErr:   tt.mockCredsErr,
+				ReserveErr: tt.mockReserveErr,
 			}
 
 			// Create mock HTTP client
@@ -172,7 +259,7 @@ func TestConsumeModel(t *testing.T) {
 
 			// Create Fiber app and service
 			app := fiber.New()
-			svc := service.New(&logger, store, app, httpClient)
+			svc := service.New(&logger, store, nil, app, httpClient)
 
 			// Register route
 			app.Post("/api/v1/ai/consume", svc.ConsumeModel)
@@ -225,6 +312,14 @@ func TestConsumeModel(t *testing.T) {
 					}
 				}
 			}
+
+			// Check token accounting
+			if !reflect.DeepEqual(store.Settled, tt.expectedSettled) {
+				t.Errorf("expected settled=%v, got %v", tt.expectedSettled, store.Settled)
+			}
+			if store.Released != tt.expectedReleased {
+				t.Errorf("expected released=%d, got %d", tt.expectedReleased, store.Released)
+			}
 		})
 	}
 }
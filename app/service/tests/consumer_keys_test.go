@@ -0,0 +1,148 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/rs/zerolog"
+	"github.com/wmbryce/agent-c/app/middleware"
+	"github.com/wmbryce/agent-c/app/service"
+	"github.com/wmbryce/agent-c/app/types"
+)
+
+// consumerApp serves the consumer key admin handlers and a key protected consume route
+func consumerApp(store *MockStore, httpClient *MockHTTPClient) *fiber.App {
+	logger := zerolog.Nop()
+	app := fiber.New()
+	svc := service.New(&logger, store, nil, app, httpClient)
+
+	consumerKey := middleware.ConsumerKeyProtected(store)
+	app.Post("/api/v1/ai/consume", consumerKey, middleware.PermissionRequired(types.PermissionConsume), svc.ConsumeModel)
+	app.Get("/api/v1/usage", consumerKey, middleware.PermissionRequired(types.PermissionUsageRead), svc.GetUsage)
+	admin := app.Group("/api/v1/admin")
+	admin.Post("/consumers", svc.CreateConsumer)
+	admin.Get("/consumers/:id/keys", svc.GetConsumerKeys)
+	admin.Post("/consumers/:id/keys", svc.CreateConsumerKey)
+	admin.Delete("/consumers/:id/keys/:key_id", svc.RevokeConsumerKey)
+	return app
+}
+
+func TestConsumerKeys(t *testing.T) {
+	success := `{"choices": [{"message": {"content": "Hi"}}], "usage": {"prompt_tokens": 7, "completion_tokens": 5}}`
+
+	creds := testKey("key-1", "sk-1")
+	store := &MockStore{Creds: &creds}
+	httpClient := &MockHTTPClient{Responses: []*http.Response{
+		providerResponse(200, nil, success),
+		providerResponse(200, nil, success),
+	}}
+	app := consumerApp(store, httpClient)
+
+	status, result := adminRequest(t, app, "POST", "/api/v1/admin/consumers", types.Consumer{
+		WalletAddress: "0x71C7656EC7ab88b098defB751B7401B5f6d8976F",
+	})
+	if status != 200 {
+		t.Fatalf("expected 200, got %d: %v", status, result)
+	}
+	consumerID := result["consumer"].(map[string]any)["id"].(string)
+	keysPath := "/api/v1/admin/consumers/" + consumerID + "/keys"
+
+	status, _ = adminRequest(t, app, "POST", keysPath, types.ConsumerKey{Scopes: []string{"admin"}})
+	if status != 400 {
+		t.Errorf("expected 400 for an unknown scope, got %d", status)
+	}
+
+	status, result = adminRequest(t, app, "POST", keysPath, types.ConsumerKey{
+		Name:   "ci",
+		Scopes: []string{types.PermissionConsume},
+	})
+	if status != 200 {
+		t.Fatalf("expected 200, got %d: %v", status, result)
+	}
+	issued := result["key"].(map[string]any)
+	key := issued["key"].(string)
+	if !strings.HasPrefix(key, "agc_"+issued["prefix"].(string)+"_") {
+		t.Errorf("expected key agc_<prefix>_<secret>, got %s", key)
+	}
+	for _, stored := range store.ConsumerKeys {
+		if strings.Contains(stored.KeyHash, key[strings.LastIndex(key, "_")+1:]) {
+			t.Error("expected only a hash of the key to be stored")
+		}
+	}
+
+	// Listing keys never returns the key or its hash
+	resp, err := app.Test(httptest.NewRequest("GET", keysPath, nil))
+	if err != nil {
+		t.Fatalf("failed to execute request: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	if bytes.Contains(body, []byte(key)) || bytes.Contains(body, []byte("$2a$")) {
+		t.Errorf("expected consumer keys not to be listed, got %s", body)
+	}
+
+	consume, _ := json.Marshal(types.ConsumeModelRequest{
+		ModelKey: "gpt-4",
+		Messages: []types.ChatMessage{{Role: "user", Content: "Hello"}},
+		MaxCost:  100,
+	})
+	request := func(method, path string, header, value string) int {
+		req := httptest.NewRequest(method, path, bytes.NewReader(consume))
+		req.Header.Set("Content-Type", "application/json")
+		if header != "" {
+			req.Header.Set(header, value)
+		}
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatalf("failed to execute request: %v", err)
+		}
+		return resp.StatusCode
+	}
+
+	tests := []struct {
+		name           string
+		method         string
+		path           string
+		header         string
+		value          string
+		expectedStatus int
+	}{
+		{"bearer key", "POST", "/api/v1/ai/consume", "Authorization", "Bearer " + key, 200},
+		{"x-api-key", "POST", "/api/v1/ai/consume", "x-api-key", key, 200},
+		{"missing key", "POST", "/api/v1/ai/consume", "", "", 401},
+		{"malformed key", "POST", "/api/v1/ai/consume", "Authorization", "Bearer sk-not-ours", 401},
+		{"wrong secret", "POST", "/api/v1/ai/consume", "Authorization", "Bearer " + key[:len(key)-4] + "beef", 401},
+		{"missing scope", "GET", "/api/v1/usage", "Authorization", "Bearer " + key, 403},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if status := request(tt.method, tt.path, tt.header, tt.value); status != tt.expectedStatus {
+				t.Errorf("expected %d, got %d", tt.expectedStatus, status)
+			}
+		})
+	}
+
+	// Usage is attributed to the key's consumer
+	if len(store.Usage) != 2 {
+		t.Fatalf("expected 2 usage records, got %d", len(store.Usage))
+	}
+	for _, record := range store.Usage {
+		if record.ConsumerID == nil || *record.ConsumerID != consumerID {
+			t.Errorf("expected usage for consumer %s, got %v", consumerID, record.ConsumerID)
+		}
+	}
+
+	// Revoked keys stop authenticating
+	status, _ = adminRequest(t, app, "DELETE", keysPath+"/"+issued["id"].(string), nil)
+	if status != 200 {
+		t.Errorf("expected 200 revoking the key, got %d", status)
+	}
+	if status := request("POST", "/api/v1/ai/consume", "Authorization", "Bearer "+key); status != 401 {
+		t.Errorf("expected 401 for a revoked key, got %d", status)
+	}
+}
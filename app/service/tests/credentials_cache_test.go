@@ -0,0 +1,111 @@
+package tests
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gofiber/fiber/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/rs/zerolog"
+	"github.com/wmbryce/agent-c/app/service"
+	"github.com/wmbryce/agent-c/app/store/cache"
+	"github.com/wmbryce/agent-c/app/types"
+)
+
+// credentialsCacheApp serves ConsumeModel and DeleteModel for one service instance, with
+// credentials invalidations published through the given Redis
+func credentialsCacheApp(t *testing.T, redisServer *miniredis.Miniredis, store *MockStore, httpClient *MockHTTPClient) (*fiber.App, *service.Service) {
+	client := redis.NewClient(&redis.Options{Addr: redisServer.Addr()})
+	t.Cleanup(func() { client.Close() })
+
+	logger := zerolog.Nop()
+	app := fiber.New()
+	svc := service.New(&logger, store, cache.New(client), app, httpClient)
+	app.Post("/api/v1/ai/consume", svc.ConsumeModel)
+	app.Delete("/api/v1/admin/models/:id", svc.DeleteModel)
+	return app, svc
+}
+
+func TestCredentialsCache(t *testing.T) {
+	success := `{"choices": [{"message": {"content": "Hi"}}], "usage": {"prompt_tokens": 7, "completion_tokens": 5}}`
+
+	t.Run("serves repeated calls from memory", func(t *testing.T) {
+		creds := testKey("key-1", "sk-1")
+		store := &MockStore{Creds: &creds}
+		app, svc := credentialsCacheApp(t, miniredis.RunT(t), store, &MockHTTPClient{Responses: providerResponses(3, success)})
+
+		for i := 0; i < 3; i++ {
+			if resp := limitedConsume(t, app, ""); resp.StatusCode != 200 {
+				t.Fatalf("expected 200, got %d", resp.StatusCode)
+			}
+		}
+		if store.CredsLoads != 1 {
+			t.Errorf("expected credentials to be loaded once, got %d loads", store.CredsLoads)
+		}
+
+		stats := svc.CredentialsCacheStats()
+		if stats.Hits != 2 || stats.Misses != 1 || stats.Entries != 1 {
+			t.Errorf("expected 2 hits and 1 miss over 1 entry, got %+v", stats)
+		}
+		if stats.HitRate < 0.66 || stats.HitRate > 0.67 {
+			t.Errorf("expected a hit rate of 2/3, got %v", stats.HitRate)
+		}
+	})
+
+	t.Run("admin changes on any instance invalidate", func(t *testing.T) {
+		redisServer := miniredis.RunT(t)
+		creds := testKey("key-1", "sk-1")
+		modelID := "9c1d6a5e-3f4b-4c2a-8d7e-1f2a3b4c5d6e"
+		store := &MockStore{Creds: &creds, Models: []types.Model{{ID: modelID}}}
+		app, svc := credentialsCacheApp(t, redisServer, store, &MockHTTPClient{Responses: providerResponses(2, success)})
+		admin, _ := credentialsCacheApp(t, redisServer, store, &MockHTTPClient{})
+
+		limitedConsume(t, app, "")
+
+		resp, err := admin.Test(httptest.NewRequest("DELETE", "/api/v1/admin/models/"+modelID, nil))
+		if err != nil || resp.StatusCode != 200 {
+			t.Fatalf("expected the model to be deleted, got %v %v", resp, err)
+		}
+
+		// The invalidation reaches the other instance through Redis
+		deadline := time.Now().Add(2 * time.Second)
+		for svc.CredentialsCacheStats().Invalidations == 0 {
+			if time.Now().After(deadline) {
+				t.Fatal("expected the other instance to receive the invalidation")
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+
+		limitedConsume(t, app, "")
+		if store.CredsLoads != 2 {
+			t.Errorf("expected credentials to be reloaded after the change, got %d loads", store.CredsLoads)
+		}
+	})
+
+	t.Run("evicts the least recently used model", func(t *testing.T) {
+		t.Setenv("CREDENTIALS_CACHE_SIZE", "1")
+
+		gpt4 := testKey("key-1", "sk-1")
+		mini := testKey("key-2", "sk-2")
+		mini.ModelKey = "gpt-4o-mini"
+		store := &MockStore{ModelCreds: map[string][]types.ModelCredentials{
+			"gpt-4":       {gpt4},
+			"gpt-4o-mini": {mini},
+		}}
+		app, svc := credentialsCacheApp(t, miniredis.RunT(t), store, &MockHTTPClient{Responses: providerResponses(3, success)})
+
+		limitedConsume(t, app, "")
+		cachedConsume(t, app, map[string]any{
+			"model_key": "gpt-4o-mini",
+			"messages":  []map[string]string{{"role": "user", "content": "Hello"}},
+			"max_cost":  1.0,
+		}, "")
+		limitedConsume(t, app, "")
+
+		if store.CredsLoads != 3 || svc.CredentialsCacheStats().Entries != 1 {
+			t.Errorf("expected gpt-4 to be evicted and reloaded, got %d loads and %+v", store.CredsLoads, svc.CredentialsCacheStats())
+		}
+	})
+}
@@ -0,0 +1,134 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/rs/zerolog"
+	"github.com/wmbryce/agent-c/app/service"
+	"github.com/wmbryce/agent-c/app/types"
+)
+
+func TestConsumeModelFallback(t *testing.T) {
+	logger := zerolog.Nop()
+	success := `{"choices": [{"message": {"content": "Hi"}}], "usage": {"prompt_tokens": 7, "completion_tokens": 5}}`
+
+	modelCreds := func(modelKey string, price *types.ModelPrice, fallbacks ...string) []types.ModelCredentials {
+		creds := testKey(modelKey+"-key", "sk-"+modelKey)
+		creds.ModelKey = modelKey
+		creds.ProviderName = modelKey + "-provider"
+		creds.Price = price
+		creds.FallbackModels = fallbacks
+		return []types.ModelCredentials{creds}
+	}
+	expensive := &types.ModelPrice{Currency: "USD", InputPrice: 30000, OutputPrice: 60000}
+
+	tests := []struct {
+		name             string
+		fallbackModels   []string
+		responses        []*http.Response
+		expectedStatus   int
+		expectedModelKey string
+		expectedModels   []string
+	}{
+		{
+			name:             "request fallbacks after provider error",
+			fallbackModels:   []string{"gpt-4o-mini"},
+			responses:        []*http.Response{providerResponse(503, nil, `{}`), providerResponse(200, nil, success)},
+			expectedStatus:   200,
+			expectedModelKey: "gpt-4o-mini",
+			expectedModels:   []string{"gpt-4", "gpt-4o-mini"},
+		},
+		{
+			name:             "default chain when request has none",
+			responses:        []*http.Response{providerResponse(500, nil, `{}`), providerResponse(200, nil, success)},
+			expectedStatus:   200,
+			expectedModelKey: "claude",
+			expectedModels:   []string{"gpt-4", "claude"},
+		},
+		{
+			name:             "fallback over max_cost is skipped",
+			fallbackModels:   []string{"gpt-4-expensive", "unknown-model", "gpt-4o-mini"},
+			responses:        []*http.Response{providerResponse(429, nil, `{}`), providerResponse(200, nil, success)},
+			expectedStatus:   200,
+			expectedModelKey: "gpt-4o-mini",
+			expectedModels:   []string{"gpt-4", "gpt-4o-mini"},
+		},
+		{
+			name:           "bad request does not fall back",
+			fallbackModels: []string{"gpt-4o-mini"},
+			responses:      []*http.Response{providerResponse(400, nil, `{}`)},
+			expectedStatus: 400,
+			expectedModels: []string{"gpt-4"},
+		},
+		{
+			name:           "all models failing returns last error",
+			fallbackModels: []string{"gpt-4o-mini"},
+			responses:      []*http.Response{providerResponse(500, nil, `{}`), providerResponse(502, nil, `{}`)},
+			expectedStatus: 502,
+			expectedModels: []string{"gpt-4", "gpt-4o-mini"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &MockStore{ModelCreds: map[string][]types.ModelCredentials{
+				"gpt-4":           modelCreds("gpt-4", testPrice, "claude"),
+				"gpt-4o-mini":     modelCreds("gpt-4o-mini", testPrice),
+				"gpt-4-expensive": modelCreds("gpt-4-expensive", expensive),
+				"claude":          modelCreds("claude", testPrice),
+			}}
+			httpClient := &MockHTTPClient{Responses: tt.responses}
+
+			app := fiber.New()
+			svc := service.New(&logger, store, nil, app, httpClient)
+			app.Post("/api/v1/ai/consume", svc.ConsumeModel)
+
+			body, _ := json.Marshal(types.ConsumeModelRequest{
+				ModelKey:       "gpt-4",
+				Messages:       []types.ChatMessage{{Role: "user", Content: "Hello"}},
+				MaxCost:        1,
+				FallbackModels: tt.fallbackModels,
+			})
+			req := httptest.NewRequest("POST", "/api/v1/ai/consume", bytes.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatalf("failed to execute request: %v", err)
+			}
+			if resp.StatusCode != tt.expectedStatus {
+				t.Errorf("expected status %d, got %d", tt.expectedStatus, resp.StatusCode)
+			}
+
+			if tt.expectedModelKey != "" {
+				var result struct {
+					ModelKey string `json:"model_key"`
+				}
+				json.NewDecoder(resp.Body).Decode(&result)
+				if result.ModelKey != tt.expectedModelKey || resp.Header.Get("X-Model-Key") != tt.expectedModelKey {
+					t.Errorf("expected served by %s, got body %q header %q",
+						tt.expectedModelKey, result.ModelKey, resp.Header.Get("X-Model-Key"))
+				}
+			}
+
+			// Each attempt sends its own model key upstream
+			if len(httpClient.Requests) != len(tt.expectedModels) {
+				t.Fatalf("expected %d provider requests, got %d", len(tt.expectedModels), len(httpClient.Requests))
+			}
+			for i, r := range httpClient.Requests {
+				payload, _ := io.ReadAll(r.Body)
+				var sent map[string]any
+				json.Unmarshal(payload, &sent)
+				if sent["model"] != tt.expectedModels[i] {
+					t.Errorf("request %d: expected model %s, got %v", i, tt.expectedModels[i], sent["model"])
+				}
+			}
+		})
+	}
+}
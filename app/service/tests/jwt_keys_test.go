@@ -0,0 +1,175 @@
+package tests
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/wmbryce/agent-c/app/types"
+	"github.com/wmbryce/agent-c/app/utils"
+)
+
+// jwtKeys builds a key set from PKCS#8 encoded keys by kid
+func jwtKeys(t *testing.T, currentID string, keys map[string]any) *utils.JWTKeySet {
+	t.Helper()
+
+	pemKeys := map[string][]byte{}
+	for kid, key := range keys {
+		pemKeys[kid] = pemKey(key)
+	}
+	set, err := utils.NewJWTKeySet(currentID, pemKeys)
+	if err != nil {
+		t.Fatalf("failed to build jwt key set: %v", err)
+	}
+	return set
+}
+
+func testClaims() jwt.MapClaims {
+	return jwt.MapClaims{"id": "0x71C7656EC7ab88b098defB751B7401B5f6d8976F", "exp": time.Now().Add(time.Minute).Unix()}
+}
+
+func TestJWTKeySetSigning(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate RSA key: %v", err)
+	}
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+
+	tests := []struct {
+		name string
+		key  any
+		alg  string
+	}{
+		{"RSA", rsaKey, "RS256"},
+		{"Ed25519", edKey, "EdDSA"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keys := jwtKeys(t, "", map[string]any{"k1": tt.key})
+
+			signed, err := keys.Sign(testClaims())
+			if err != nil {
+				t.Fatalf("failed to sign: %v", err)
+			}
+			token, err := jwt.Parse(signed, keys.Keyfunc)
+			if err != nil {
+				t.Fatalf("failed to verify: %v", err)
+			}
+			if token.Header["kid"] != "k1" || token.Method.Alg() != tt.alg {
+				t.Errorf("expected kid k1 with %s, got %v with %s", tt.alg, token.Header["kid"], token.Method.Alg())
+			}
+		})
+	}
+}
+
+func TestJWTKeySetRotation(t *testing.T) {
+	_, oldKey, _ := ed25519.GenerateKey(rand.Reader)
+	_, newKey, _ := ed25519.GenerateKey(rand.Reader)
+
+	before := jwtKeys(t, "", map[string]any{"2026-09": oldKey})
+	signedBefore, err := before.Sign(testClaims())
+	if err != nil {
+		t.Fatalf("failed to sign: %v", err)
+	}
+
+	// The newest kid becomes current, and tokens of the previous key still verify
+	after := jwtKeys(t, "", map[string]any{"2026-09": oldKey, "2026-10": newKey})
+	if after.CurrentKeyID() != "2026-10" {
+		t.Errorf("expected current key 2026-10, got %s", after.CurrentKeyID())
+	}
+	if _, err := jwt.Parse(signedBefore, after.Keyfunc); err != nil {
+		t.Errorf("expected a token of the previous key to verify, got %v", err)
+	}
+
+	// Once the previous key is retired its tokens are rejected
+	retired := jwtKeys(t, "", map[string]any{"2026-10": newKey})
+	if _, err := jwt.Parse(signedBefore, retired.Keyfunc); err == nil {
+		t.Error("expected a token of a retired key to be rejected")
+	}
+
+	// A pinned current key wins over the newest
+	pinned := jwtKeys(t, "2026-09", map[string]any{"2026-09": oldKey, "2026-10": newKey})
+	if pinned.CurrentKeyID() != "2026-09" {
+		t.Errorf("expected current key 2026-09, got %s", pinned.CurrentKeyID())
+	}
+	if _, err := utils.NewJWTKeySet("2026-11", map[string][]byte{"2026-10": pemKey(newKey)}); err == nil {
+		t.Error("expected an error for a current key that is not loaded")
+	}
+}
+
+func TestJWTKeySetRejectsForeignTokens(t *testing.T) {
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+	keys := jwtKeys(t, "", map[string]any{"k1": edKey})
+
+	// An HS256 token naming the key, signed with its public half as the secret
+	hmac := jwt.NewWithClaims(jwt.SigningMethodHS256, testClaims())
+	hmac.Header["kid"] = "k1"
+	signed, _ := hmac.SignedString([]byte(edKey.Public().(ed25519.PublicKey)))
+	if _, err := jwt.Parse(signed, keys.Keyfunc); err == nil {
+		t.Error("expected a token with another algorithm to be rejected")
+	}
+
+	// A token naming an unknown key
+	_, otherKey, _ := ed25519.GenerateKey(rand.Reader)
+	other := jwtKeys(t, "", map[string]any{"k2": otherKey})
+	signed, _ = other.Sign(testClaims())
+	if _, err := jwt.Parse(signed, keys.Keyfunc); err == nil {
+		t.Error("expected a token with an unknown kid to be rejected")
+	}
+}
+
+func TestJWKSEndpoint(t *testing.T) {
+	app := routesApp(t, &MockStore{})
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/.well-known/jwks.json", nil))
+	if err != nil {
+		t.Fatalf("failed to execute request: %v", err)
+	}
+	if resp.StatusCode != 200 {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+
+	var jwks types.JWKS
+	if err := json.NewDecoder(resp.Body).Decode(&jwks); err != nil {
+		t.Fatalf("failed to decode jwks: %v", err)
+	}
+	if len(jwks.Keys) != 1 || jwks.Keys[0].Kty != "OKP" || jwks.Keys[0].Kid != "test" {
+		t.Fatalf("expected the test Ed25519 key, got %+v", jwks.Keys)
+	}
+
+	// Another service verifies an access token with the published key alone
+	public, err := base64.RawURLEncoding.DecodeString(jwks.Keys[0].X)
+	if err != nil {
+		t.Fatalf("failed to decode public key: %v", err)
+	}
+	token, err := jwt.Parse(accessToken(t, types.RoleConsumer), func(token *jwt.Token) (interface{}, error) {
+		return ed25519.PublicKey(public), nil
+	}, jwt.WithValidMethods([]string{jwks.Keys[0].Alg}))
+	if err != nil || token.Header["kid"] != jwks.Keys[0].Kid {
+		t.Errorf("expected the access token to verify with the published key, got %v", err)
+	}
+}
+
+func TestJWKSPublishesRSAKeys(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate RSA key: %v", err)
+	}
+	keys := jwtKeys(t, "", map[string]any{"rsa": rsaKey})
+
+	jwk := keys.JWKS().Keys[0]
+	n, _ := base64.RawURLEncoding.DecodeString(jwk.N)
+	e, _ := base64.RawURLEncoding.DecodeString(jwk.E)
+	public := &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+	if jwk.Kty != "RSA" || jwk.Alg != "RS256" || !public.Equal(&rsaKey.PublicKey) {
+		t.Errorf("expected the RSA public key, got %+v", jwk)
+	}
+}
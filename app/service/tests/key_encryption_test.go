@@ -0,0 +1,152 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/rs/zerolog"
+	"github.com/wmbryce/agent-c/app/service"
+	"github.com/wmbryce/agent-c/app/types"
+	"github.com/wmbryce/agent-c/app/utils"
+)
+
+const (
+	oldMasterKey = "old:ICEiIyQlJicoKSorLC0uLzAxMjM0NTY3ODk6Ozw9Pj8="
+	newMasterKey = "new:QEFCQ0RFRkdISUpLTE1OT1BRUlNUVVZXWFlaW1xdXl8="
+)
+
+func TestKeyRingSealAndOpen(t *testing.T) {
+	ring, err := utils.NewKeyRing(newMasterKey)
+	if err != nil {
+		t.Fatalf("failed to create key ring: %v", err)
+	}
+
+	sealed, err := ring.Seal("sk-secret-key")
+	if err != nil {
+		t.Fatalf("failed to seal key: %v", err)
+	}
+	if bytes.Contains(sealed.Ciphertext, []byte("sk-secret-key")) {
+		t.Error("expected ciphertext not to contain the key")
+	}
+	if sealed.MasterKeyID != "new" {
+		t.Errorf("expected master key new, got %s", sealed.MasterKeyID)
+	}
+
+	opened, err := ring.Open(sealed)
+	if err != nil {
+		t.Fatalf("failed to open key: %v", err)
+	}
+	if opened != "sk-secret-key" {
+		t.Errorf("expected sk-secret-key, got %s", opened)
+	}
+
+	// A tampered ciphertext must not open
+	tampered := *sealed
+	tampered.Ciphertext = append([]byte{}, sealed.Ciphertext...)
+	tampered.Ciphertext[len(tampered.Ciphertext)-1] ^= 1
+	if _, err := ring.Open(&tampered); !errors.Is(err, utils.ErrKeyDecryption) {
+		t.Errorf("expected ErrKeyDecryption for a tampered key, got %v", err)
+	}
+}
+
+func TestKeyRingRewrap(t *testing.T) {
+	oldRing, _ := utils.NewKeyRing(oldMasterKey)
+	sealed, err := oldRing.Seal("sk-secret-key")
+	if err != nil {
+		t.Fatalf("failed to seal key: %v", err)
+	}
+
+	// After rotation the new key is current and the old one still opens existing keys
+	ring, err := utils.NewKeyRing(newMasterKey + "," + oldMasterKey)
+	if err != nil {
+		t.Fatalf("failed to create key ring: %v", err)
+	}
+	if opened, err := ring.Open(sealed); err != nil || opened != "sk-secret-key" {
+		t.Fatalf("expected the old key to still open, got %q, %v", opened, err)
+	}
+
+	ciphertext := sealed.Ciphertext
+	changed, err := ring.Rewrap(sealed)
+	if err != nil || !changed {
+		t.Fatalf("expected the key to be rewrapped, got %v, %v", changed, err)
+	}
+	if sealed.MasterKeyID != "new" || !bytes.Equal(sealed.Ciphertext, ciphertext) {
+		t.Errorf("expected only the data key to be rewrapped under new, got %s", sealed.MasterKeyID)
+	}
+	if changed, _ := ring.Rewrap(sealed); changed {
+		t.Error("expected a key under the current master key to be left alone")
+	}
+
+	// Once the old master key is retired the rewrapped key still opens
+	retired, _ := utils.NewKeyRing(newMasterKey)
+	if opened, err := retired.Open(sealed); err != nil || opened != "sk-secret-key" {
+		t.Errorf("expected the rewrapped key to open, got %q, %v", opened, err)
+	}
+}
+
+func TestKeyRingInvalidMasterKeys(t *testing.T) {
+	for _, spec := range []string{"", "no-separator", "short:AAEC", "bad:not-base64!"} {
+		if _, err := utils.NewKeyRing(spec); err == nil {
+			t.Errorf("expected an error for %q", spec)
+		}
+	}
+
+	var ring *utils.KeyRing
+	if _, err := ring.Open(&types.EncryptedKey{}); !errors.Is(err, utils.ErrKeyDecryption) {
+		t.Errorf("expected ErrKeyDecryption without a key ring, got %v", err)
+	}
+}
+
+func TestKeyFingerprint(t *testing.T) {
+	fingerprint := utils.KeyFingerprint("sk-secret-key")
+	if len(fingerprint) != 16 || strings.Contains(fingerprint, "secret") {
+		t.Errorf("unexpected fingerprint %s", fingerprint)
+	}
+	if utils.KeyFingerprint("sk-other-key") == fingerprint {
+		t.Error("expected different keys to have different fingerprints")
+	}
+	if last4 := utils.KeyLast4("sk-secret-key"); last4 != "-key" {
+		t.Errorf("expected -key, got %s", last4)
+	}
+}
+
+func TestConsumeModelUndecryptableKeyFailsOver(t *testing.T) {
+	logger := zerolog.Nop()
+	success := `{"choices": [{"message": {"content": "Hi"}}], "usage": {"prompt_tokens": 7, "completion_tokens": 5}}`
+
+	// key-1 was sealed under a master key the service does not have
+	oldRing, _ := utils.NewKeyRing(oldMasterKey)
+	key1, key2 := testKey("key-1", "sk-one"), testKey("key-2", "sk-two")
+	key1.SealedKey, _ = oldRing.Seal("sk-one")
+	store := &MockStore{Creds: &key1, ExtraCreds: []types.ModelCredentials{key2}}
+	httpClient := &MockHTTPClient{Responses: []*http.Response{providerResponse(200, nil, success)}}
+
+	app := fiber.New()
+	svc := service.New(&logger, store, nil, app, httpClient)
+	app.Post("/api/v1/ai/consume", svc.ConsumeModel)
+
+	body, _ := json.Marshal(types.ConsumeModelRequest{
+		ModelKey: "gpt-4",
+		Messages: []types.ChatMessage{{Role: "user", Content: "Hello"}},
+		MaxCost:  100,
+	})
+	req := httptest.NewRequest("POST", "/api/v1/ai/consume", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatalf("failed to execute request: %v", err)
+	}
+	if resp.StatusCode != 200 {
+		t.Errorf("expected 200, got %d", resp.StatusCode)
+	}
+	if len(httpClient.Requests) != 1 || httpClient.Requests[0].Header.Get("Authorization") != "Bearer sk-two" {
+		t.Errorf("expected only the second key to reach the provider")
+	}
+}
@@ -0,0 +1,150 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/rs/zerolog"
+	"github.com/wmbryce/agent-c/app/service"
+	"github.com/wmbryce/agent-c/app/types"
+)
+
+func testKey(id, apiKey string) types.ModelCredentials {
+	return types.ModelCredentials{
+		ModelKey:        "gpt-4",
+		RequestURL:      "https://api.openai.com/v1/chat/completions",
+		ApiKeyID:        id,
+		SealedKey:       sealKey(apiKey),
+		TokensAvailable: 10000,
+		Price:           testPrice,
+		ProviderConfig: &types.ProviderConfig{
+			// Fail over immediately so each response below maps to one key
+			RetryPolicy: &types.RetryPolicy{MaxAttempts: 1},
+			ResponseMapping: map[string]string{
+				"content":           "$.choices[0].message.content",
+				"prompt_tokens":     "$.usage.prompt_tokens",
+				"completion_tokens": "$.usage.completion_tokens",
+			},
+		},
+	}
+}
+
+func TestConsumeModelFailover(t *testing.T) {
+	logger := zerolog.Nop()
+
+	tests := []struct {
+		name            string
+		responses       []*http.Response
+		expectedStatus  int
+		expectedReserve []string
+		expectedSettled int
+	}{
+		{
+			name: "rate limited key fails over",
+			responses: []*http.Response{
+				{StatusCode: 429, Body: io.NopCloser(strings.NewReader(`{"error": "rate limited"}`))},
+				{StatusCode: 200, Body: io.NopCloser(strings.NewReader(`{"choices": [{"message": {"content": "Hi"}}], "usage": {"prompt_tokens": 7, "completion_tokens": 5}}`))},
+			},
+			expectedStatus:  200,
+			expectedReserve: []string{"key-1", "key-2"},
+			expectedSettled: 1,
+		},
+		{
+			name: "bad request does not fail over",
+			responses: []*http.Response{
+				{StatusCode: 400, Body: io.NopCloser(strings.NewReader(`{"error": "bad request"}`))},
+			},
+			expectedStatus:  400,
+			expectedReserve: []string{"key-1"},
+		},
+		{
+			name: "all keys failing returns last error",
+			responses: []*http.Response{
+				{StatusCode: 500, Body: io.NopCloser(strings.NewReader(`{"error": "down"}`))},
+				{StatusCode: 503, Body: io.NopCloser(strings.NewReader(`{"error": "down"}`))},
+			},
+			expectedStatus:  503,
+			expectedReserve: []string{"key-1", "key-2"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key1, key2 := testKey("key-1", "sk-one"), testKey("key-2", "sk-two")
+			store := &MockStore{Creds: &key1, ExtraCreds: []types.ModelCredentials{key2}}
+			httpClient := &MockHTTPClient{Responses: tt.responses}
+
+			app := fiber.New()
+			svc := service.New(&logger, store, nil, app, httpClient)
+			app.Post("/api/v1/ai/consume", svc.ConsumeModel)
+
+			body, _ := json.Marshal(types.ConsumeModelRequest{
+				ModelKey: "gpt-4",
+				Messages: []types.ChatMessage{{Role: "user", Content: "Hello"}},
+				MaxCost:  100,
+			})
+			req := httptest.NewRequest("POST", "/api/v1/ai/consume", bytes.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatalf("failed to execute request: %v", err)
+			}
+			if resp.StatusCode != tt.expectedStatus {
+				t.Errorf("expected status %d, got %d", tt.expectedStatus, resp.StatusCode)
+			}
+
+			if strings.Join(store.ReservedFor, ",") != strings.Join(tt.expectedReserve, ",") {
+				t.Errorf("expected reservations on %v, got %v", tt.expectedReserve, store.ReservedFor)
+			}
+			if len(store.Settled) != tt.expectedSettled {
+				t.Errorf("expected %d settled reservations, got %d", tt.expectedSettled, len(store.Settled))
+			}
+			if store.Released != len(tt.expectedReserve)-tt.expectedSettled {
+				t.Errorf("expected %d released reservations, got %d", len(tt.expectedReserve)-tt.expectedSettled, store.Released)
+			}
+			if len(store.Usage) != len(tt.expectedReserve) {
+				t.Errorf("expected a usage record per attempt, got %d", len(store.Usage))
+			}
+
+			for i, r := range httpClient.Requests {
+				expected := "Bearer " + []string{"sk-one", "sk-two"}[i]
+				if got := r.Header.Get("Authorization"); got != expected {
+					t.Errorf("request %d: expected Authorization %q, got %q", i, expected, got)
+				}
+			}
+		})
+	}
+}
+
+func TestRoundRobinKeySelector(t *testing.T) {
+	selector := service.NewKeySelector(service.KeySelectionRoundRobin)
+	keys := []types.ModelCredentials{testKey("key-1", "sk-one"), testKey("key-2", "sk-two"), testKey("key-3", "sk-three")}
+
+	for i, expected := range []string{"key-1", "key-2", "key-3", "key-1"} {
+		ordered := selector.Order("gpt-4", keys)
+		if len(ordered) != len(keys) {
+			t.Fatalf("expected %d keys, got %d", len(keys), len(ordered))
+		}
+		if ordered[0].ApiKeyID != expected {
+			t.Errorf("request %d: expected %s first, got %s", i, expected, ordered[0].ApiKeyID)
+		}
+	}
+}
+
+func TestLeastRecentlyUsedKeySelector(t *testing.T) {
+	selector := service.NewKeySelector(service.KeySelectionLeastRecentUsed)
+	keys := []types.ModelCredentials{testKey("key-1", "sk-one"), testKey("key-2", "sk-two")}
+
+	first := selector.Order("gpt-4", keys)[0].ApiKeyID
+	second := selector.Order("gpt-4", keys)[0].ApiKeyID
+	if first == second {
+		t.Errorf("expected the least recently used key to be chosen, got %s twice", first)
+	}
+}
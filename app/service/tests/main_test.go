@@ -0,0 +1,58 @@
+package tests
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/wmbryce/agent-c/app/types"
+	"github.com/wmbryce/agent-c/app/utils"
+)
+
+// testMasterKeys is the master key the service under test seals seller keys with
+const testMasterKeys = "test:AAECAwQFBgcICQoLDA0ODxAREhMUFRYXGBkaGxwdHh8="
+
+func TestMain(m *testing.M) {
+	os.Setenv("API_KEY_MASTER_KEYS", testMasterKeys)
+
+	// Sign access tokens with a throwaway Ed25519 key
+	keysDir, err := os.MkdirTemp("", "agent-c-jwt-keys")
+	if err != nil {
+		panic(err)
+	}
+	_, private, _ := ed25519.GenerateKey(rand.Reader)
+	if err := os.WriteFile(filepath.Join(keysDir, "test.pem"), pemKey(private), 0o600); err != nil {
+		panic(err)
+	}
+	os.Setenv("JWT_SIGNING_KEYS_DIR", keysDir)
+
+	code := m.Run()
+	os.RemoveAll(keysDir)
+	os.Exit(code)
+}
+
+// pemKey encodes a private key as PKCS#8 PEM
+func pemKey(private any) []byte {
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		panic(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+}
+
+// sealKey encrypts a seller key the way the admin API stores it
+func sealKey(apiKey string) *types.EncryptedKey {
+	ring, err := utils.NewKeyRing(testMasterKeys)
+	if err != nil {
+		panic(err)
+	}
+	sealed, err := ring.Seal(apiKey)
+	if err != nil {
+		panic(err)
+	}
+	return sealed
+}
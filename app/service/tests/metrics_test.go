@@ -0,0 +1,110 @@
+package tests
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/rs/zerolog"
+	"github.com/wmbryce/agent-c/app/middleware"
+	"github.com/wmbryce/agent-c/app/routes"
+	"github.com/wmbryce/agent-c/app/service"
+	"github.com/wmbryce/agent-c/app/types"
+)
+
+// testMetricsToken is the token the metrics tests scrape with
+const testMetricsToken = "metrics-token"
+
+// scrape returns the /metrics exposition
+func scrape(t *testing.T, app *fiber.App) string {
+	t.Helper()
+
+	req := httptest.NewRequest("GET", "/metrics", nil)
+	req.Header.Set("Authorization", "Bearer "+testMetricsToken)
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatalf("failed to execute request: %v", err)
+	}
+	if resp.StatusCode != 200 {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	return string(body)
+}
+
+func TestMetrics(t *testing.T) {
+	t.Setenv("METRICS_TOKEN", testMetricsToken)
+	success := `{"choices": [{"message": {"content": "Hi"}}], "usage": {"prompt_tokens": 7, "completion_tokens": 5}}`
+
+	creds := testKey("key-1", "sk-1")
+	creds.ProviderName = "metrics-provider"
+	store := &MockStore{Creds: &creds}
+
+	logger := zerolog.Nop()
+	app := fiber.New()
+	app.Use(middleware.Metrics())
+	svc := service.New(&logger, store, nil, app, &MockHTTPClient{Responses: []*http.Response{
+		providerResponse(200, nil, success),
+		providerResponse(500, nil, `{"error": "overloaded"}`),
+	}})
+	routes.New(svc, store, nil).Setup(app)
+	key := consumerKey(t, store, types.RateLimit{}, types.PermissionConsume)
+
+	limitedConsume(t, app, key)
+	limitedConsume(t, app, key)
+	app.Test(httptest.NewRequest("GET", "/not-a-route", nil))
+
+	metrics := scrape(t, app)
+	for _, want := range []string{
+		`agc_http_requests_total{method="POST",route="/api/v1/ai/consume",status="200"} 1`,
+		`agc_http_requests_total{method="POST",route="/api/v1/ai/consume",status="500"} 1`,
+		`agc_http_requests_total{method="GET",route="unmatched",status="404"} 1`,
+		`agc_http_request_duration_seconds_count{method="POST",route="/api/v1/ai/consume"} 2`,
+		`agc_provider_requests_total{model_key="gpt-4",provider="metrics-provider",status="200"} 1`,
+		`agc_provider_requests_total{model_key="gpt-4",provider="metrics-provider",status="500"} 1`,
+		`agc_provider_request_duration_seconds_count{model_key="gpt-4",provider="metrics-provider"} 2`,
+		`agc_tokens_total{model_key="gpt-4",provider="metrics-provider",type="prompt"} 7`,
+		`agc_tokens_total{model_key="gpt-4",provider="metrics-provider",type="completion"} 5`,
+		`agc_cost_total{currency="USD",model_key="gpt-4",provider="metrics-provider"} 0.00051`,
+	} {
+		if !strings.Contains(metrics, want) {
+			t.Errorf("expected metrics to contain %s", want)
+		}
+	}
+}
+
+func TestMetricsToken(t *testing.T) {
+	tests := []struct {
+		name   string
+		token  string
+		sent   string
+		status int
+	}{
+		{"no token configured", "", "", 503},
+		{"missing token", testMetricsToken, "", 401},
+		{"wrong token", testMetricsToken, "Bearer other-token", 401},
+		{"matching token", testMetricsToken, "Bearer " + testMetricsToken, 200},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("METRICS_TOKEN", tt.token)
+			app := routesApp(t, &MockStore{})
+
+			req := httptest.NewRequest("GET", "/metrics", nil)
+			if tt.sent != "" {
+				req.Header.Set("Authorization", tt.sent)
+			}
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatalf("failed to execute request: %v", err)
+			}
+			if resp.StatusCode != tt.status {
+				t.Errorf("expected %d, got %d", tt.status, resp.StatusCode)
+			}
+		})
+	}
+}
@@ -1,17 +1,62 @@
 package tests
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
 	"net/http"
+	"strings"
+	"time"
 
+	"github.com/google/uuid"
+	"github.com/wmbryce/agent-c/app/store"
 	"github.com/wmbryce/agent-c/app/types"
 )
 
+// testPrice is the model price used by test credentials (per million tokens)
+var testPrice = &types.ModelPrice{
+	Currency:    "USD",
+	InputPrice:  30,
+	OutputPrice: 60,
+}
+
 // MockStore implements store.SqlStore for testing
 type MockStore struct {
-	Creds     *types.ModelCredentials
-	CredsErr  error
-	Models    []types.Model
-	CreateErr error
+	Creds      *types.ModelCredentials
+	ExtraCreds []types.ModelCredentials
+	// ModelCreds, when set, serves credentials per model key instead of Creds
+	ModelCreds map[string][]types.ModelCredentials
+	CredsErr   error
+	// CredsLoads counts model credential lookups
+	CredsLoads int
+	Models     []types.Model
+	CreateErr  error
+	ReserveErr error
+	// Schemas serves model schemas by id
+	Schemas map[string]json.RawMessage
+	// SchemaLoads counts schema lookups
+	SchemaLoads int
+	// Admin registry rows by id
+	Providers map[string]types.Provider
+	Sellers   map[string]types.Seller
+	ApiKeys   map[string]types.ApiKey
+	Consumers map[string]types.Consumer
+	// ConsumerKeys holds issued consumer keys by id
+	ConsumerKeys map[string]types.ConsumerKey
+
+	// Recorded reservation activity
+	Reserved    []int
+	ReservedFor []string
+	Settled     []int
+	Released    int
+	Usage       []types.UsageRecord
+	// ReservedTTL is the expiry the last reservation was made with
+	ReservedTTL time.Duration
+	// Expired are the reservations the next sweep finds past their expiry, and Sweeps counts sweeps
+	Expired int
+	Sweeps  chan int
+	// UsageFilters records the filters usage was read with
+	UsageFilters []types.UsageFilter
 }
 
 func (m *MockStore) CreateModel(model *types.Model) (*types.Model, error) {
@@ -25,8 +70,407 @@ func (m *MockStore) GetModels() ([]types.Model, error) {
 	return m.Models, nil
 }
 
-func (m *MockStore) GetModelCredentials(modelKey string) (*types.ModelCredentials, error) {
-	return m.Creds, m.CredsErr
+func (m *MockStore) GetModelCredentials(ctx context.Context, modelKey string) ([]types.ModelCredentials, error) {
+	m.CredsLoads++
+	if m.ModelCreds != nil {
+		return m.ModelCreds[modelKey], m.CredsErr
+	}
+	if m.CredsErr != nil || m.Creds == nil {
+		return nil, m.CredsErr
+	}
+	return append([]types.ModelCredentials{*m.Creds}, m.ExtraCreds...), nil
+}
+
+func (m *MockStore) UpdateModel(model *types.Model) (*types.Model, error) {
+	for i := range m.Models {
+		if m.Models[i].ID == model.ID {
+			m.Models[i] = *model
+			return model, nil
+		}
+	}
+	return nil, fmt.Errorf("failed to update model: %w", store.ErrNotFound)
+}
+
+func (m *MockStore) DeleteModel(id string) error {
+	for i := range m.Models {
+		if m.Models[i].ID == id {
+			m.Models = append(m.Models[:i], m.Models[i+1:]...)
+			return nil
+		}
+	}
+	return fmt.Errorf("failed to delete model: %w", store.ErrNotFound)
+}
+
+func (m *MockStore) CreateProvider(provider *types.Provider) (*types.Provider, error) {
+	if m.Providers == nil {
+		m.Providers = map[string]types.Provider{}
+	}
+	provider.ID = uuid.New().String()
+	provider.CreatedAt = time.Now()
+	m.Providers[provider.ID] = *provider
+	return provider, nil
+}
+
+func (m *MockStore) GetProviders() ([]types.Provider, error) {
+	providers := []types.Provider{}
+	for _, provider := range m.Providers {
+		providers = append(providers, provider)
+	}
+	return providers, nil
+}
+
+func (m *MockStore) GetProvider(id string) (*types.Provider, error) {
+	provider, ok := m.Providers[id]
+	if !ok {
+		return nil, fmt.Errorf("failed to get provider: %w", store.ErrNotFound)
+	}
+	return &provider, nil
+}
+
+func (m *MockStore) UpdateProvider(provider *types.Provider) (*types.Provider, error) {
+	if _, ok := m.Providers[provider.ID]; !ok {
+		return nil, fmt.Errorf("failed to update provider: %w", store.ErrNotFound)
+	}
+	m.Providers[provider.ID] = *provider
+	return provider, nil
+}
+
+func (m *MockStore) DeleteProvider(id string) error {
+	if _, ok := m.Providers[id]; !ok {
+		return fmt.Errorf("failed to delete provider: %w", store.ErrNotFound)
+	}
+	delete(m.Providers, id)
+	return nil
+}
+
+func (m *MockStore) CreateModelSchema(schema *types.ModelSchema) (*types.ModelSchema, error) {
+	if m.Schemas == nil {
+		m.Schemas = map[string]json.RawMessage{}
+	}
+	schema.ID = uuid.New().String()
+	m.Schemas[schema.ID] = schema.Schema
+	return schema, nil
+}
+
+func (m *MockStore) GetModelSchemas() ([]types.ModelSchema, error) {
+	schemas := []types.ModelSchema{}
+	for id, schema := range m.Schemas {
+		schemas = append(schemas, types.ModelSchema{ID: id, Type: types.SchemaTypeOptions, Name: id, Schema: schema})
+	}
+	return schemas, nil
+}
+
+func (m *MockStore) GetModelSchema(id string) (*types.ModelSchema, error) {
+	m.SchemaLoads++
+	schema, ok := m.Schemas[id]
+	if !ok {
+		return nil, fmt.Errorf("failed to get model schema: %w", store.ErrNotFound)
+	}
+	return &types.ModelSchema{ID: id, Type: types.SchemaTypeOptions, Name: id, Schema: schema}, nil
+}
+
+func (m *MockStore) UpdateModelSchema(schema *types.ModelSchema) (*types.ModelSchema, error) {
+	if _, ok := m.Schemas[schema.ID]; !ok {
+		return nil, fmt.Errorf("failed to update model schema: %w", store.ErrNotFound)
+	}
+	m.Schemas[schema.ID] = schema.Schema
+	return schema, nil
+}
+
+func (m *MockStore) DeleteModelSchema(id string) error {
+	if _, ok := m.Schemas[id]; !ok {
+		return fmt.Errorf("failed to delete model schema: %w", store.ErrNotFound)
+	}
+	delete(m.Schemas, id)
+	return nil
+}
+
+func (m *MockStore) CreateSeller(seller *types.Seller) (*types.Seller, error) {
+	if m.Sellers == nil {
+		m.Sellers = map[string]types.Seller{}
+	}
+	for _, existing := range m.Sellers {
+		if existing.WalletAddress == seller.WalletAddress {
+			return nil, fmt.Errorf("failed to create seller: %w", store.ErrDuplicate)
+		}
+	}
+	seller.ID = uuid.New().String()
+	m.Sellers[seller.ID] = *seller
+	return seller, nil
+}
+
+func (m *MockStore) GetSellers() ([]types.Seller, error) {
+	sellers := []types.Seller{}
+	for _, seller := range m.Sellers {
+		sellers = append(sellers, seller)
+	}
+	return sellers, nil
+}
+
+func (m *MockStore) GetSeller(id string) (*types.Seller, error) {
+	seller, ok := m.Sellers[id]
+	if !ok {
+		return nil, fmt.Errorf("failed to get seller: %w", store.ErrNotFound)
+	}
+	return &seller, nil
+}
+
+func (m *MockStore) UpdateSeller(seller *types.Seller) (*types.Seller, error) {
+	if _, ok := m.Sellers[seller.ID]; !ok {
+		return nil, fmt.Errorf("failed to update seller: %w", store.ErrNotFound)
+	}
+	m.Sellers[seller.ID] = *seller
+	return seller, nil
+}
+
+func (m *MockStore) DeleteSeller(id string) error {
+	if _, ok := m.Sellers[id]; !ok {
+		return fmt.Errorf("failed to delete seller: %w", store.ErrNotFound)
+	}
+	delete(m.Sellers, id)
+	return nil
+}
+
+func (m *MockStore) CreateConsumer(consumer *types.Consumer) (*types.Consumer, error) {
+	if m.Consumers == nil {
+		m.Consumers = map[string]types.Consumer{}
+	}
+	for _, existing := range m.Consumers {
+		if existing.WalletAddress == consumer.WalletAddress {
+			return nil, fmt.Errorf("failed to create consumer: %w", store.ErrDuplicate)
+		}
+	}
+	consumer.ID = uuid.New().String()
+	m.Consumers[consumer.ID] = *consumer
+	return consumer, nil
+}
+
+func (m *MockStore) GetConsumers() ([]types.Consumer, error) {
+	consumers := []types.Consumer{}
+	for _, consumer := range m.Consumers {
+		consumers = append(consumers, consumer)
+	}
+	return consumers, nil
+}
+
+func (m *MockStore) GetConsumer(id string) (*types.Consumer, error) {
+	consumer, ok := m.Consumers[id]
+	if !ok {
+		return nil, fmt.Errorf("failed to get consumer: %w", store.ErrNotFound)
+	}
+	return &consumer, nil
+}
+
+func (m *MockStore) UpdateConsumer(consumer *types.Consumer) (*types.Consumer, error) {
+	if _, ok := m.Consumers[consumer.ID]; !ok {
+		return nil, fmt.Errorf("failed to update consumer: %w", store.ErrNotFound)
+	}
+	m.Consumers[consumer.ID] = *consumer
+	return consumer, nil
+}
+
+func (m *MockStore) DeleteConsumer(id string) error {
+	if _, ok := m.Consumers[id]; !ok {
+		return fmt.Errorf("failed to delete consumer: %w", store.ErrNotFound)
+	}
+	delete(m.Consumers, id)
+	return nil
+}
+
+func (m *MockStore) CreateConsumerKey(key *types.ConsumerKey) (*types.ConsumerKey, error) {
+	if m.ConsumerKeys == nil {
+		m.ConsumerKeys = map[string]types.ConsumerKey{}
+	}
+	if _, ok := m.Consumers[key.ConsumerID]; !ok {
+		return nil, fmt.Errorf("failed to create consumer key: %w", store.ErrInvalidReference)
+	}
+	key.ID = uuid.New().String()
+	key.CreatedAt = time.Now()
+	m.ConsumerKeys[key.ID] = *key
+	return key, nil
+}
+
+func (m *MockStore) GetConsumerKeys(consumerID string) ([]types.ConsumerKey, error) {
+	keys := []types.ConsumerKey{}
+	for _, key := range m.ConsumerKeys {
+		if key.ConsumerID == consumerID {
+			keys = append(keys, key)
+		}
+	}
+	return keys, nil
+}
+
+func (m *MockStore) GetConsumerKeyByPrefix(ctx context.Context, prefix string) (*types.ConsumerKey, *types.Consumer, error) {
+	for _, key := range m.ConsumerKeys {
+		if key.Prefix == prefix && key.RevokedAt == nil {
+			consumer := m.Consumers[key.ConsumerID]
+			return &key, &consumer, nil
+		}
+	}
+	return nil, nil, fmt.Errorf("failed to get consumer key: %w", store.ErrNotFound)
+}
+
+func (m *MockStore) RevokeConsumerKey(consumerID, id string) (*types.ConsumerKey, error) {
+	key, ok := m.ConsumerKeys[id]
+	if !ok || key.ConsumerID != consumerID {
+		return nil, fmt.Errorf("failed to revoke consumer key: %w", store.ErrNotFound)
+	}
+	if key.RevokedAt == nil {
+		now := time.Now()
+		key.RevokedAt = &now
+	}
+	m.ConsumerKeys[id] = key
+	return &key, nil
+}
+
+func (m *MockStore) GetSellerByWallet(wallet string) (*types.Seller, error) {
+	for _, seller := range m.Sellers {
+		if strings.EqualFold(seller.WalletAddress, wallet) {
+			return &seller, nil
+		}
+	}
+	return nil, fmt.Errorf("failed to get seller: %w", store.ErrNotFound)
+}
+
+func (m *MockStore) GetConsumerByWallet(wallet string) (*types.Consumer, error) {
+	for _, consumer := range m.Consumers {
+		if strings.EqualFold(consumer.WalletAddress, wallet) {
+			return &consumer, nil
+		}
+	}
+	return nil, fmt.Errorf("failed to get consumer: %w", store.ErrNotFound)
+}
+
+func (m *MockStore) CreateApiKey(key *types.ApiKey) (*types.ApiKey, error) {
+	if m.ApiKeys == nil {
+		m.ApiKeys = map[string]types.ApiKey{}
+	}
+	if _, ok := m.Providers[key.ProviderID]; !ok {
+		return nil, fmt.Errorf("failed to create api key: %w", store.ErrInvalidReference)
+	}
+	key.ID = uuid.New().String()
+	m.ApiKeys[key.ID] = *key
+	return apiKeyView(*key), nil
+}
+
+func (m *MockStore) GetApiKeys() ([]types.ApiKey, error) {
+	keys := []types.ApiKey{}
+	for _, key := range m.ApiKeys {
+		keys = append(keys, *apiKeyView(key))
+	}
+	return keys, nil
+}
+
+func (m *MockStore) GetApiKey(id string) (*types.ApiKey, error) {
+	key, ok := m.ApiKeys[id]
+	if !ok {
+		return nil, fmt.Errorf("failed to get api key: %w", store.ErrNotFound)
+	}
+	return apiKeyView(key), nil
+}
+
+func (m *MockStore) UpdateApiKey(key *types.ApiKey) (*types.ApiKey, error) {
+	existing, ok := m.ApiKeys[key.ID]
+	if !ok {
+		return nil, fmt.Errorf("failed to update api key: %w", store.ErrNotFound)
+	}
+	if key.Sealed == nil {
+		key.Sealed, key.Fingerprint, key.Last4 = existing.Sealed, existing.Fingerprint, existing.Last4
+	}
+	m.ApiKeys[key.ID] = *key
+	return apiKeyView(*key), nil
+}
+
+// apiKeyView returns an api key as the store reads it back, without the key itself
+func apiKeyView(key types.ApiKey) *types.ApiKey {
+	key.ApiKey = ""
+	key.Sealed = nil
+	return &key
+}
+
+func (m *MockStore) ReencryptApiKeys(reseal func(key *types.StoredApiKey) (bool, error)) (int, error) {
+	saved := 0
+	for id, key := range m.ApiKeys {
+		stored := &types.StoredApiKey{ID: id, Sealed: key.Sealed, Fingerprint: key.Fingerprint, Last4: key.Last4}
+		changed, err := reseal(stored)
+		if err != nil {
+			return 0, err
+		}
+		if changed {
+			key.Sealed, key.Fingerprint, key.Last4 = stored.Sealed, stored.Fingerprint, stored.Last4
+			m.ApiKeys[id] = key
+			saved++
+		}
+	}
+	return saved, nil
+}
+
+func (m *MockStore) DeleteApiKey(id string) error {
+	if _, ok := m.ApiKeys[id]; !ok {
+		return fmt.Errorf("failed to delete api key: %w", store.ErrNotFound)
+	}
+	delete(m.ApiKeys, id)
+	return nil
+}
+
+func (m *MockStore) CreateModelPrice(modelID string, price *types.ModelPrice) (*types.ModelPrice, error) {
+	if m.CreateErr != nil {
+		return nil, m.CreateErr
+	}
+	return price, nil
+}
+
+func (m *MockStore) ReserveTokens(ctx context.Context, apiKeyID string, modelKey string, amount int, ttl time.Duration) (*types.TokenReservation, error) {
+	if m.ReserveErr != nil {
+		return nil, m.ReserveErr
+	}
+	m.Reserved = append(m.Reserved, amount)
+	m.ReservedFor = append(m.ReservedFor, apiKeyID)
+	m.ReservedTTL = ttl
+	return &types.TokenReservation{
+		ID:             "reservation-id",
+		ApiKeyID:       apiKeyID,
+		ModelKey:       modelKey,
+		ReservedTokens: amount,
+		Status:         types.ReservationReserved,
+		CreatedAt:      time.Now(),
+		ExpiresAt:      time.Now().Add(ttl),
+	}, nil
+}
+
+func (m *MockStore) SettleTokens(ctx context.Context, reservationID string, actual int) (*types.TokenReservation, error) {
+	m.Settled = append(m.Settled, actual)
+	return &types.TokenReservation{ID: reservationID, Status: types.ReservationSettled}, nil
+}
+
+func (m *MockStore) ReleaseTokens(ctx context.Context, reservationID string) error {
+	m.Released++
+	return nil
+}
+
+func (m *MockStore) ReleaseExpiredReservations(ctx context.Context) (int, error) {
+	released := m.Expired
+	m.Expired = 0
+	m.Released += released
+	if m.Sweeps != nil {
+		m.Sweeps <- released
+	}
+	return released, nil
+}
+
+func (m *MockStore) RecordUsage(ctx context.Context, record *types.UsageRecord) error {
+	m.Usage = append(m.Usage, *record)
+	return nil
+}
+
+func (m *MockStore) GetUsage(filter *types.UsageFilter) ([]types.UsageRecord, error) {
+	m.UsageFilters = append(m.UsageFilters, *filter)
+	return m.Usage, nil
+}
+
+func (m *MockStore) GetDailyUsage(filter *types.UsageFilter) ([]types.DailyUsage, error) {
+	m.UsageFilters = append(m.UsageFilters, *filter)
+	return []types.DailyUsage{}, nil
 }
 
 func (m *MockStore) Close() {}
@@ -35,8 +479,17 @@ func (m *MockStore) Close() {}
 type MockHTTPClient struct {
 	Response *http.Response
 	Err      error
+	// Responses, when set, are returned in order, one per request
+	Responses []*http.Response
+	Requests  []*http.Request
 }
 
 func (m *MockHTTPClient) Do(req *http.Request) (*http.Response, error) {
+	m.Requests = append(m.Requests, req)
+	if len(m.Responses) > 0 {
+		resp := m.Responses[0]
+		m.Responses = m.Responses[1:]
+		return resp, nil
+	}
 	return m.Response, m.Err
 }
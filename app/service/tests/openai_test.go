@@ -0,0 +1,256 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/rs/zerolog"
+	"github.com/wmbryce/agent-c/app/service"
+	"github.com/wmbryce/agent-c/app/types"
+	"github.com/wmbryce/agent-c/app/utils"
+)
+
+// anthropicCreds serves a model through an Anthropic-style provider
+func anthropicCreds() *types.ModelCredentials {
+	return &types.ModelCredentials{
+		ModelKey:        "claude-3",
+		RequestURL:      "https://api.anthropic.com/v1/messages",
+		ApiKeyID:        "api-key-id",
+		SealedKey:       sealKey("sk-ant-key"),
+		TokensAvailable: 10000,
+		Price:           testPrice,
+		ProviderConfig: &types.ProviderConfig{
+			RetryPolicy: &types.RetryPolicy{MaxAttempts: 1},
+			ResponseMapping: map[string]string{
+				"id":                "$.id",
+				"content":           "$.content[0].text",
+				"finish_reason":     "$.stop_reason",
+				"prompt_tokens":     "$.usage.input_tokens",
+				"completion_tokens": "$.usage.output_tokens",
+			},
+			StreamMapping: map[string]string{
+				"id":                "$.message.id",
+				"content":           "$.delta.text",
+				"finish_reason":     "$.delta.stop_reason",
+				"prompt_tokens":     "$.message.usage.input_tokens",
+				"completion_tokens": "$.usage.output_tokens",
+			},
+		},
+	}
+}
+
+const anthropicStream = "event: message_start\ndata: {\"type\":\"message_start\",\"message\":{\"id\":\"msg_1\",\"role\":\"assistant\",\"usage\":{\"input_tokens\":12,\"output_tokens\":1}}}\n\n" +
+	"event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"index\":0,\"delta\":{\"type\":\"text_delta\",\"text\":\"Hello\"}}\n\n" +
+	"event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"index\":0,\"delta\":{\"type\":\"text_delta\",\"text\":\" world\"}}\n\n" +
+	"event: message_delta\ndata: {\"type\":\"message_delta\",\"delta\":{\"stop_reason\":\"end_turn\"},\"usage\":{\"output_tokens\":5}}\n\n" +
+	"event: message_stop\ndata: {\"type\":\"message_stop\"}\n\n"
+
+func TestChatCompletions(t *testing.T) {
+	logger := zerolog.Nop()
+
+	store := &MockStore{Creds: anthropicCreds()}
+	httpClient := &MockHTTPClient{Response: &http.Response{
+		StatusCode: 200,
+		Body: io.NopCloser(strings.NewReader(
+			`{"id": "msg_1", "content": [{"type": "text", "text": "Hi"}], "stop_reason": "end_turn", "usage": {"input_tokens": 7, "output_tokens": 5}}`,
+		)),
+	}}
+
+	app := fiber.New()
+	svc := service.New(&logger, store, nil, app, httpClient)
+	app.Post("/v1/chat/completions", svc.ChatCompletions)
+
+	req := httptest.NewRequest("POST", "/v1/chat/completions", strings.NewReader(
+		`{"model": "claude-3", "messages": [{"role": "user", "content": "Hello"}], "temperature": 0.5, "max_tokens": 100}`,
+	))
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatalf("failed to execute request: %v", err)
+	}
+	if resp.StatusCode != 200 {
+		t.Fatalf("expected status 200, got %d", resp.StatusCode)
+	}
+
+	var completion types.ChatCompletionResponse
+	if err := json.NewDecoder(resp.Body).Decode(&completion); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if completion.Object != "chat.completion" || completion.Model != "claude-3" || len(completion.Choices) != 1 {
+		t.Fatalf("unexpected completion: %+v", completion)
+	}
+	choice := completion.Choices[0]
+	if choice.Message.Role != "assistant" || choice.Message.Content != "Hi" || choice.FinishReason != "stop" {
+		t.Errorf("unexpected choice: %+v", choice)
+	}
+	if completion.Usage != (types.Usage{PromptTokens: 7, CompletionTokens: 5, TotalTokens: 12}) {
+		t.Errorf("unexpected usage: %+v", completion.Usage)
+	}
+
+	// Options are forwarded and the request is billed like ConsumeModel
+	var sent map[string]any
+	payload, _ := io.ReadAll(httpClient.Requests[0].Body)
+	json.Unmarshal(payload, &sent)
+	if sent["max_tokens"] != float64(100) || sent["temperature"] != 0.5 {
+		t.Errorf("expected options forwarded, got %s", payload)
+	}
+	if len(store.Reserved) != 1 || store.Reserved[0] != 110 || len(store.Settled) != 1 || store.Settled[0] != 12 {
+		t.Errorf("expected 110 tokens reserved and 12 settled, got %v and %v", store.Reserved, store.Settled)
+	}
+}
+
+func TestChatCompletionsStream(t *testing.T) {
+	logger := zerolog.Nop()
+
+	store := &MockStore{Creds: anthropicCreds()}
+	httpClient := &MockHTTPClient{Response: &http.Response{
+		StatusCode: 200,
+		Body:       io.NopCloser(strings.NewReader(anthropicStream)),
+	}}
+
+	app := fiber.New()
+	svc := service.New(&logger, store, nil, app, httpClient)
+	app.Post("/v1/chat/completions", svc.ChatCompletions)
+
+	req := httptest.NewRequest("POST", "/v1/chat/completions", strings.NewReader(
+		`{"model": "claude-3", "messages": [{"role": "user", "content": "Hello"}], "stream": true, "stream_options": {"include_usage": true}}`,
+	))
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatalf("failed to execute request: %v", err)
+	}
+	if resp.StatusCode != 200 {
+		t.Fatalf("expected status 200, got %d", resp.StatusCode)
+	}
+
+	raw, _ := io.ReadAll(resp.Body)
+	if !bytes.HasSuffix(raw, []byte("data: [DONE]\n\n")) {
+		t.Errorf("expected the stream to end with [DONE], got %q", raw)
+	}
+
+	var content, role, finish string
+	var usage *types.Usage
+	err = utils.ReadSSE(bytes.NewReader(raw), func(event utils.SSEEvent) error {
+		if event.Event != "" {
+			t.Errorf("expected data-only events, got %q", event.Event)
+		}
+		var chunk types.ChatCompletionChunk
+		if err := json.Unmarshal([]byte(event.Data), &chunk); err != nil {
+			return err
+		}
+		if chunk.Object != "chat.completion.chunk" || chunk.Model != "claude-3" {
+			t.Errorf("unexpected chunk: %s", event.Data)
+		}
+		for _, choice := range chunk.Choices {
+			content += choice.Delta.Content
+			if choice.Delta.Role != "" {
+				role = choice.Delta.Role
+			}
+			if choice.FinishReason != nil {
+				finish = *choice.FinishReason
+			}
+		}
+		if chunk.Usage != nil {
+			usage = chunk.Usage
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("failed to read stream: %v", err)
+	}
+
+	if content != "Hello world" || role != "assistant" || finish != "stop" {
+		t.Errorf("expected assistant %q finishing with stop, got %s %q finishing with %q", "Hello world", role, content, finish)
+	}
+	if usage == nil || *usage != (types.Usage{PromptTokens: 12, CompletionTokens: 5, TotalTokens: 17}) {
+		t.Errorf("unexpected usage chunk: %+v", usage)
+	}
+}
+
+func TestChatCompletionsError(t *testing.T) {
+	logger := zerolog.Nop()
+
+	tests := []struct {
+		name           string
+		body           string
+		defaultMaxCost string
+		expectedStatus int
+		expectedType   string
+	}{
+		{
+			name:           "missing messages",
+			body:           `{"model": "claude-3"}`,
+			expectedStatus: 400,
+			expectedType:   "invalid_request_error",
+		},
+		{
+			name:           "unknown model",
+			body:           `{"model": "unknown", "messages": [{"role": "user", "content": "Hello"}]}`,
+			expectedStatus: 404,
+			expectedType:   "not_found_error",
+		},
+		{
+			name:           "max_cost exceeded",
+			body:           `{"model": "claude-3", "messages": [{"role": "user", "content": "Hello"}], "max_cost": 0.0001}`,
+			expectedStatus: 402,
+			expectedType:   "insufficient_quota",
+		},
+		{
+			name:           "default max_cost exceeded",
+			body:           `{"model": "claude-3", "messages": [{"role": "user", "content": "Hello"}], "max_tokens": 100000}`,
+			expectedStatus: 402,
+			expectedType:   "insufficient_quota",
+		},
+		{
+			name:           "configured default max_cost exceeded",
+			body:           `{"model": "claude-3", "messages": [{"role": "user", "content": "Hello"}], "max_tokens": 100}`,
+			defaultMaxCost: "0.001",
+			expectedStatus: 402,
+			expectedType:   "insufficient_quota",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("DEFAULT_MAX_COST", tt.defaultMaxCost)
+			store := &MockStore{ModelCreds: map[string][]types.ModelCredentials{"claude-3": {*anthropicCreds()}}}
+
+			app := fiber.New()
+			svc := service.New(&logger, store, nil, app, &MockHTTPClient{})
+			app.Post("/v1/chat/completions", svc.ChatCompletions)
+
+			req := httptest.NewRequest("POST", "/v1/chat/completions", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatalf("failed to execute request: %v", err)
+			}
+			if resp.StatusCode != tt.expectedStatus {
+				t.Errorf("expected status %d, got %d", tt.expectedStatus, resp.StatusCode)
+			}
+
+			var body struct {
+				Error struct {
+					Message string `json:"message"`
+					Type    string `json:"type"`
+				} `json:"error"`
+			}
+			if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+				t.Fatalf("failed to decode error: %v", err)
+			}
+			if body.Error.Type != tt.expectedType || body.Error.Message == "" {
+				t.Errorf("expected %s error, got %+v", tt.expectedType, body.Error)
+			}
+		})
+	}
+}
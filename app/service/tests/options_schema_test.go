@@ -0,0 +1,181 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/rs/zerolog"
+	"github.com/wmbryce/agent-c/app/service"
+	"github.com/wmbryce/agent-c/app/types"
+	"github.com/wmbryce/agent-c/app/utils"
+)
+
+// testOptionsSchema mirrors the seeded Anthropic options schema
+const testOptionsSchema = `{
+	"type": "object",
+	"properties": {
+		"max_tokens": {"type": "integer", "minimum": 1, "default": 1024},
+		"temperature": {"type": "number", "minimum": 0, "maximum": 1},
+		"top_k": {"type": "integer", "minimum": 0},
+		"stop": {"type": "array", "items": {"type": "string"}}
+	}
+}`
+
+func TestConsumeModelOptionsSchema(t *testing.T) {
+	logger := zerolog.Nop()
+	success := `{"choices": [{"message": {"content": "Hi"}}], "usage": {"prompt_tokens": 7, "completion_tokens": 5}}`
+
+	tests := []struct {
+		name               string
+		options            map[string]interface{}
+		expectedStatus     int
+		expectedViolations []string
+		expectedPayload    map[string]any
+	}{
+		{
+			name:            "defaults applied to valid options",
+			options:         map[string]interface{}{"temperature": 0.5},
+			expectedStatus:  200,
+			expectedPayload: map[string]any{"temperature": 0.5, "max_tokens": float64(1024)},
+		},
+		{
+			name:            "request value wins over default",
+			options:         map[string]interface{}{"max_tokens": 100, "stop": []string{"END"}},
+			expectedStatus:  200,
+			expectedPayload: map[string]any{"max_tokens": float64(100)},
+		},
+		{
+			name:               "each violation listed by path",
+			options:            map[string]interface{}{"temperature": 1.5, "top_k": "many", "stop": []any{"END", 3}},
+			expectedStatus:     400,
+			expectedViolations: []string{"/stop/1", "/temperature", "/top_k"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			creds := testKey("key-1", "sk-1")
+			creds.OptionsSchemaID = "anthropic-options"
+			store := &MockStore{
+				Creds:   &creds,
+				Schemas: map[string]json.RawMessage{"anthropic-options": json.RawMessage(testOptionsSchema)},
+			}
+			httpClient := &MockHTTPClient{Responses: []*http.Response{providerResponse(200, nil, success)}}
+
+			app := fiber.New()
+			svc := service.New(&logger, store, nil, app, httpClient)
+			app.Post("/api/v1/ai/consume", svc.ConsumeModel)
+
+			body, _ := json.Marshal(types.ConsumeModelRequest{
+				ModelKey: "gpt-4",
+				Messages: []types.ChatMessage{{Role: "user", Content: "Hello"}},
+				Options:  tt.options,
+				MaxCost:  1,
+			})
+			req := httptest.NewRequest("POST", "/api/v1/ai/consume", bytes.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatalf("failed to execute request: %v", err)
+			}
+			if resp.StatusCode != tt.expectedStatus {
+				t.Fatalf("expected status %d, got %d", tt.expectedStatus, resp.StatusCode)
+			}
+
+			if tt.expectedViolations != nil {
+				var result struct {
+					Violations []utils.SchemaViolation `json:"violations"`
+				}
+				json.NewDecoder(resp.Body).Decode(&result)
+				if len(result.Violations) != len(tt.expectedViolations) {
+					t.Fatalf("expected violations at %v, got %+v", tt.expectedViolations, result.Violations)
+				}
+				for i, v := range result.Violations {
+					if v.Path != tt.expectedViolations[i] || v.Message == "" {
+						t.Errorf("violation %d: expected path %s, got %+v", i, tt.expectedViolations[i], v)
+					}
+				}
+
+				// Nothing is reserved or sent for invalid options
+				if len(httpClient.Requests) != 0 || len(store.Reserved) != 0 {
+					t.Errorf("expected no upstream spend, got %d requests and %d reservations",
+						len(httpClient.Requests), len(store.Reserved))
+				}
+				return
+			}
+
+			payload, _ := io.ReadAll(httpClient.Requests[0].Body)
+			var sent map[string]any
+			json.Unmarshal(payload, &sent)
+			for k, v := range tt.expectedPayload {
+				if sent[k] != v {
+					t.Errorf("expected %s=%v upstream, got %v", k, v, sent[k])
+				}
+			}
+		})
+	}
+}
+
+func TestConsumeModelOptionsSchemaFallback(t *testing.T) {
+	logger := zerolog.Nop()
+	success := `{"choices": [{"message": {"content": "Hi"}}], "usage": {"prompt_tokens": 7, "completion_tokens": 5}}`
+
+	modelCreds := func(modelKey, schemaID string) []types.ModelCredentials {
+		creds := testKey(modelKey+"-key", "sk-"+modelKey)
+		creds.ModelKey = modelKey
+		creds.ProviderName = modelKey + "-provider"
+		creds.OptionsSchemaID = schemaID
+		return []types.ModelCredentials{creds}
+	}
+	store := &MockStore{
+		ModelCreds: map[string][]types.ModelCredentials{
+			"gpt-4":       modelCreds("gpt-4", ""),
+			"claude":      modelCreds("claude", "anthropic-options"),
+			"gpt-4o-mini": modelCreds("gpt-4o-mini", ""),
+		},
+		Schemas: map[string]json.RawMessage{"anthropic-options": json.RawMessage(testOptionsSchema)},
+	}
+	httpClient := &MockHTTPClient{Responses: []*http.Response{
+		providerResponse(503, nil, `{}`),
+		providerResponse(200, nil, success),
+		providerResponse(503, nil, `{}`),
+		providerResponse(200, nil, success),
+	}}
+
+	app := fiber.New()
+	svc := service.New(&logger, store, nil, app, httpClient)
+	app.Post("/api/v1/ai/consume", svc.ConsumeModel)
+
+	// temperature 1.5 is valid for gpt-4 but not for claude, which is skipped
+	for i := 0; i < 2; i++ {
+		body, _ := json.Marshal(types.ConsumeModelRequest{
+			ModelKey:       "gpt-4",
+			Messages:       []types.ChatMessage{{Role: "user", Content: "Hello"}},
+			Options:        map[string]interface{}{"temperature": 1.5},
+			MaxCost:        1,
+			FallbackModels: []string{"claude", "gpt-4o-mini"},
+		})
+		req := httptest.NewRequest("POST", "/api/v1/ai/consume", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatalf("failed to execute request: %v", err)
+		}
+		if resp.StatusCode != 200 || resp.Header.Get("X-Model-Key") != "gpt-4o-mini" {
+			t.Fatalf("expected gpt-4o-mini to serve the request, got status %d from %q",
+				resp.StatusCode, resp.Header.Get("X-Model-Key"))
+		}
+	}
+
+	// The compiled schema is reused across requests
+	if store.SchemaLoads != 1 {
+		t.Errorf("expected the schema to be loaded once, got %d loads", store.SchemaLoads)
+	}
+}
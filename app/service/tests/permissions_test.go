@@ -0,0 +1,115 @@
+package tests
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/rs/zerolog"
+	"github.com/wmbryce/agent-c/app/routes"
+	"github.com/wmbryce/agent-c/app/service"
+	"github.com/wmbryce/agent-c/app/types"
+	"github.com/wmbryce/agent-c/app/utils"
+)
+
+// routesApp serves the full route table
+func routesApp(t *testing.T, store *MockStore) *fiber.App {
+	t.Setenv("JWT_SECRET_KEY_EXPIRE_MINUTES_COUNT", "15")
+
+	logger := zerolog.Nop()
+	app := fiber.New()
+	svc := service.New(&logger, store, nil, app, &MockHTTPClient{})
+	routes.New(svc, store, nil).Setup(app)
+	return app
+}
+
+// accessToken issues an access token carrying the permissions of roles
+func accessToken(t *testing.T, roles ...string) string {
+	t.Helper()
+
+	keys, err := utils.JWTKeySetFromEnv()
+	if err != nil {
+		t.Fatalf("failed to load jwt keys: %v", err)
+	}
+	token, err := utils.GenerateNewAccessToken(keys, &utils.TokenSubject{
+		Wallet:      "0x71C7656EC7ab88b098defB751B7401B5f6d8976F",
+		Roles:       roles,
+		Permissions: types.PermissionsForRoles(roles),
+	})
+	if err != nil {
+		t.Fatalf("failed to generate access token: %v", err)
+	}
+	return token
+}
+
+// consumerKey stores a consumer with rate limits and a key granting scopes, and returns the key
+func consumerKey(t *testing.T, store *MockStore, limit types.RateLimit, scopes ...string) string {
+	t.Helper()
+
+	consumer, err := store.CreateConsumer(&types.Consumer{
+		WalletAddress: "0x71C7656EC7ab88b098defB751B7401B5f6d8976F",
+		RateLimit:     limit,
+	})
+	if err != nil {
+		t.Fatalf("failed to create consumer: %v", err)
+	}
+	key, prefix, secret, err := utils.GenerateConsumerKey()
+	if err != nil {
+		t.Fatalf("failed to generate consumer key: %v", err)
+	}
+	if _, err := store.CreateConsumerKey(&types.ConsumerKey{
+		ConsumerID: consumer.ID,
+		Prefix:     prefix,
+		KeyHash:    utils.GeneratePassword(secret),
+		Scopes:     scopes,
+	}); err != nil {
+		t.Fatalf("failed to create consumer key: %v", err)
+	}
+	return key
+}
+
+func TestPermissions(t *testing.T) {
+	store := &MockStore{}
+	app := routesApp(t, store)
+
+	admin := accessToken(t, types.RoleAdmin)
+	consumer := accessToken(t, types.RoleConsumer)
+	usageOnly := consumerKey(t, store, types.RateLimit{}, types.PermissionUsageRead)
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		token  string
+		status int
+	}{
+		{"create model without a token", "POST", "/api/v1/ai/models", "", 401},
+		{"create model as a consumer", "POST", "/api/v1/ai/models", consumer, 403},
+		{"create model price as a consumer", "POST", "/api/v1/ai/models/1/prices", consumer, 403},
+		{"list providers as a consumer", "GET", "/api/v1/admin/providers", consumer, 403},
+		{"list providers as an admin", "GET", "/api/v1/admin/providers", admin, 200},
+		{"list consumers as an admin", "GET", "/api/v1/admin/consumers", admin, 200},
+		{"consume with a usage key", "POST", "/api/v1/ai/consume", usageOnly, 403},
+		{"chat completions with a usage key", "POST", "/v1/chat/completions", usageOnly, 403},
+		{"list models publicly", "GET", "/api/v1/ai/models", "", 200},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader("{}"))
+			req.Header.Set("Content-Type", "application/json")
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatalf("failed to execute request: %v", err)
+			}
+			if resp.StatusCode != tt.status {
+				t.Errorf("expected %d, got %d", tt.status, resp.StatusCode)
+			}
+		})
+	}
+}
@@ -0,0 +1,89 @@
+package tests
+
+import (
+	"math"
+	"testing"
+
+	"github.com/wmbryce/agent-c/app/types"
+	"github.com/wmbryce/agent-c/app/utils"
+)
+
+func TestCachedInputPricing(t *testing.T) {
+	cachedPrice := func(input, output, cached float64) *types.ModelPrice {
+		return &types.ModelPrice{Currency: "USD", InputPrice: input, OutputPrice: output, CachedInputPrice: &cached}
+	}
+
+	tests := []struct {
+		name           string
+		mapping        map[string]string
+		body           string
+		price          *types.ModelPrice
+		expectedPrompt int
+		expectedCached int
+		expectedTotal  int
+		expectedCost   float64
+	}{
+		{
+			name: "openai includes cached tokens in prompt tokens",
+			mapping: map[string]string{
+				"prompt_tokens":     "$.usage.prompt_tokens",
+				"completion_tokens": "$.usage.completion_tokens",
+				"cached_tokens":     "$.usage.prompt_tokens_details.cached_tokens",
+			},
+			body:           `{"usage": {"prompt_tokens": 1300, "completion_tokens": 50, "prompt_tokens_details": {"cached_tokens": 1000}}}`,
+			price:          cachedPrice(2.5, 10, 1.25),
+			expectedPrompt: 1300,
+			expectedCached: 1000,
+			expectedTotal:  1350,
+			// 300 uncached at 2.50, 1000 cached at 1.25 and 50 output at 10 per million
+			expectedCost: 0.0025,
+		},
+		{
+			name: "anthropic reports cache reads and writes apart from input tokens",
+			mapping: map[string]string{
+				"prompt_tokens":      "$.usage.input_tokens",
+				"completion_tokens":  "$.usage.output_tokens",
+				"cache_read_tokens":  "$.usage.cache_read_input_tokens",
+				"cache_write_tokens": "$.usage.cache_creation_input_tokens",
+			},
+			body:           `{"usage": {"input_tokens": 100, "cache_read_input_tokens": 1000, "cache_creation_input_tokens": 200, "output_tokens": 50}}`,
+			price:          cachedPrice(3, 15, 0.3),
+			expectedPrompt: 1300,
+			expectedCached: 1000,
+			expectedTotal:  1350,
+			// 300 uncached at 3, 1000 cached at 0.30 and 50 output at 15 per million
+			expectedCost: 0.00195,
+		},
+		{
+			name: "anthropic without prompt caching",
+			mapping: map[string]string{
+				"prompt_tokens":      "$.usage.input_tokens",
+				"completion_tokens":  "$.usage.output_tokens",
+				"cache_read_tokens":  "$.usage.cache_read_input_tokens",
+				"cache_write_tokens": "$.usage.cache_creation_input_tokens",
+			},
+			body:           `{"usage": {"input_tokens": 100, "output_tokens": 50}}`,
+			price:          cachedPrice(3, 15, 0.3),
+			expectedPrompt: 100,
+			expectedTotal:  150,
+			expectedCost:   0.00105,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response, err := utils.TransformResponse([]byte(tt.body), tt.mapping)
+			if err != nil {
+				t.Fatalf("failed to transform response: %v", err)
+			}
+			if response.PromptTokens != tt.expectedPrompt || response.CachedTokens != tt.expectedCached || response.TotalTokens != tt.expectedTotal {
+				t.Errorf("expected %d prompt, %d cached and %d total tokens, got %d, %d and %d",
+					tt.expectedPrompt, tt.expectedCached, tt.expectedTotal,
+					response.PromptTokens, response.CachedTokens, response.TotalTokens)
+			}
+			if cost := utils.CalculateCost(tt.price, response); math.Abs(cost-tt.expectedCost) > 1e-12 {
+				t.Errorf("expected cost %v, got %v", tt.expectedCost, cost)
+			}
+		})
+	}
+}
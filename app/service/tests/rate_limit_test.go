@@ -0,0 +1,159 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gofiber/fiber/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/rs/zerolog"
+	"github.com/wmbryce/agent-c/app/middleware"
+	"github.com/wmbryce/agent-c/app/service"
+	"github.com/wmbryce/agent-c/app/store/cache"
+	"github.com/wmbryce/agent-c/app/types"
+)
+
+// rateLimitApp serves ConsumeModel behind consumer key authentication and the consumer's
+// rate limits, counted in an in-process Redis
+func rateLimitApp(t *testing.T, store *MockStore, httpClient *MockHTTPClient) *fiber.App {
+	redisServer := miniredis.RunT(t)
+	cacheStore := cache.New(redis.NewClient(&redis.Options{Addr: redisServer.Addr()}))
+
+	logger := zerolog.Nop()
+	app := fiber.New()
+	svc := service.New(&logger, store, cacheStore, app, httpClient)
+	app.Post("/api/v1/ai/consume", middleware.ConsumerKeyProtected(store), middleware.ConsumerRateLimited(cacheStore), svc.ConsumeModel)
+	return app
+}
+
+// limitedConsume sends a consume request with a consumer key
+func limitedConsume(t *testing.T, app *fiber.App, key string) *http.Response {
+	t.Helper()
+
+	body, _ := json.Marshal(types.ConsumeModelRequest{
+		ModelKey: "gpt-4",
+		Messages: []types.ChatMessage{{Role: "user", Content: "Hello"}},
+		MaxCost:  1.0,
+	})
+	req := httptest.NewRequest("POST", "/api/v1/ai/consume", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+key)
+
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatalf("failed to execute request: %v", err)
+	}
+	return resp
+}
+
+func perMinute(n int) *int {
+	return &n
+}
+
+func TestConsumerRateLimit(t *testing.T) {
+	success := `{"choices": [{"message": {"content": "Hi"}}], "usage": {"prompt_tokens": 7, "completion_tokens": 5}}`
+
+	t.Run("requests per minute", func(t *testing.T) {
+		creds := testKey("key-1", "sk-1")
+		store := &MockStore{Creds: &creds}
+		httpClient := &MockHTTPClient{Responses: providerResponses(3, success)}
+		app := rateLimitApp(t, store, httpClient)
+		key := consumerKey(t, store, types.RateLimit{RequestsPerMinute: perMinute(2)}, types.PermissionConsume)
+
+		resp := limitedConsume(t, app, key)
+		if resp.StatusCode != 200 || resp.Header.Get("RateLimit-Limit") != "2" || resp.Header.Get("RateLimit-Remaining") != "1" {
+			t.Errorf("expected 200 with 1 of 2 remaining, got %d with %s of %s", resp.StatusCode,
+				resp.Header.Get("RateLimit-Remaining"), resp.Header.Get("RateLimit-Limit"))
+		}
+		limitedConsume(t, app, key)
+
+		resp = limitedConsume(t, app, key)
+		if resp.StatusCode != 429 {
+			t.Fatalf("expected 429, got %d", resp.StatusCode)
+		}
+		if resp.Header.Get("RateLimit-Remaining") != "0" || resp.Header.Get("Retry-After") == "" ||
+			resp.Header.Get("Retry-After") != resp.Header.Get("RateLimit-Reset") {
+			t.Errorf("expected RateLimit-* and Retry-After headers, got %v", resp.Header)
+		}
+		if len(httpClient.Requests) != 2 {
+			t.Errorf("expected the limited request not to reach the provider, got %d calls", len(httpClient.Requests))
+		}
+	})
+
+	t.Run("tokens per minute", func(t *testing.T) {
+		creds := testKey("key-1", "sk-1")
+		store := &MockStore{Creds: &creds}
+		httpClient := &MockHTTPClient{Responses: providerResponses(2, success)}
+		app := rateLimitApp(t, store, httpClient)
+		key := consumerKey(t, store, types.RateLimit{TokensPerMinute: perMinute(10)}, types.PermissionConsume)
+
+		// The first call is admitted under the limit and uses 12 tokens
+		if resp := limitedConsume(t, app, key); resp.StatusCode != 200 {
+			t.Fatalf("expected 200, got %d", resp.StatusCode)
+		}
+		resp := limitedConsume(t, app, key)
+		if resp.StatusCode != 429 || resp.Header.Get("RateLimit-Limit") != "10" {
+			t.Errorf("expected 429 against the token limit, got %d with limit %s", resp.StatusCode, resp.Header.Get("RateLimit-Limit"))
+		}
+	})
+}
+
+func TestSellerKeyRateLimit(t *testing.T) {
+	success := `{"choices": [{"message": {"content": "Hi"}}], "usage": {"prompt_tokens": 7, "completion_tokens": 5}}`
+
+	first := testKey("key-1", "sk-1")
+	first.KeyRateLimit.RequestsPerMinute = perMinute(1)
+	second := testKey("key-2", "sk-2")
+	second.KeyRateLimit.RequestsPerMinute = perMinute(1)
+	store := &MockStore{Creds: &first, ExtraCreds: []types.ModelCredentials{second}}
+	httpClient := &MockHTTPClient{Responses: providerResponses(3, success)}
+	app := rateLimitApp(t, store, httpClient)
+	key := consumerKey(t, store, types.RateLimit{}, types.PermissionConsume)
+
+	// A key over its limit fails over to the next one
+	for i := 0; i < 2; i++ {
+		if resp := limitedConsume(t, app, key); resp.StatusCode != 200 {
+			t.Fatalf("expected 200, got %d", resp.StatusCode)
+		}
+	}
+	if len(store.ReservedFor) != 2 || store.ReservedFor[0] == store.ReservedFor[1] {
+		t.Errorf("expected each key to serve one request, got %v", store.ReservedFor)
+	}
+
+	resp := limitedConsume(t, app, key)
+	if resp.StatusCode != 429 || resp.Header.Get("Retry-After") == "" {
+		t.Errorf("expected 429 with Retry-After once every key is limited, got %d", resp.StatusCode)
+	}
+	if len(httpClient.Requests) != 2 {
+		t.Errorf("expected limited keys not to reach the provider, got %d calls", len(httpClient.Requests))
+	}
+}
+
+func TestModelRateLimit(t *testing.T) {
+	success := `{"choices": [{"message": {"content": "Hi"}}], "usage": {"prompt_tokens": 7, "completion_tokens": 5}}`
+
+	limited := testKey("key-1", "sk-1")
+	limited.ModelRateLimit.TokensPerMinute = perMinute(10)
+	limited.FallbackModels = []string{"gpt-4o-mini"}
+	fallback := testKey("key-2", "sk-2")
+	fallback.ModelKey = "gpt-4o-mini"
+	store := &MockStore{ModelCreds: map[string][]types.ModelCredentials{
+		"gpt-4":       {limited},
+		"gpt-4o-mini": {fallback},
+	}}
+	httpClient := &MockHTTPClient{Responses: providerResponses(2, success)}
+	app := rateLimitApp(t, store, httpClient)
+	key := consumerKey(t, store, types.RateLimit{}, types.PermissionConsume)
+
+	limitedConsume(t, app, key)
+
+	// Once the model is over its token limit the request falls back to the next model
+	resp := limitedConsume(t, app, key)
+	if resp.StatusCode != 200 || resp.Header.Get("X-Model-Key") != "gpt-4o-mini" {
+		t.Errorf("expected the fallback model to serve the request, got %d from %s", resp.StatusCode, resp.Header.Get("X-Model-Key"))
+	}
+}
@@ -0,0 +1,183 @@
+package tests
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/wmbryce/agent-c/app/types"
+	"github.com/wmbryce/agent-c/app/utils"
+)
+
+func TestTransformRequest(t *testing.T) {
+	conversation := []types.ChatMessage{
+		{Role: "system", Content: "Be brief."},
+		{Role: "user", Content: "Hello"},
+		{Role: "assistant", Content: "Hi!"},
+		{Role: "user", Content: "How are you?"},
+	}
+	options := map[string]interface{}{
+		"temperature": 0.5,
+		"max_tokens":  100,
+		"stop":        []string{"END"},
+	}
+
+	tests := []struct {
+		name     string
+		template string
+		request  types.ConsumeModelRequest
+		expected string
+	}{
+		{
+			name: "openai",
+			template: `{
+				"model": "{{model}}",
+				"messages": "{{messages}}",
+				"stream": "{{stream}}",
+				"*": "{{options}}"
+			}`,
+			request: types.ConsumeModelRequest{Messages: conversation, Options: options, Stream: true},
+			expected: `{
+				"model": "test-model",
+				"messages": [
+					{"role": "system", "content": "Be brief."},
+					{"role": "user", "content": "Hello"},
+					{"role": "assistant", "content": "Hi!"},
+					{"role": "user", "content": "How are you?"}
+				],
+				"stream": true,
+				"temperature": 0.5,
+				"max_tokens": 100,
+				"stop": ["END"]
+			}`,
+		},
+		{
+			name: "anthropic hoists the system prompt",
+			template: `{
+				"model": "{{model}}",
+				"system": "{{system}}",
+				"messages": "{{chat}}",
+				"stop_sequences": "{{options.stop}}",
+				"stream": "{{stream}}",
+				"*": "{{options}}"
+			}`,
+			request: types.ConsumeModelRequest{Messages: conversation, Options: options},
+			expected: `{
+				"model": "test-model",
+				"system": "Be brief.",
+				"messages": [
+					{"role": "user", "content": "Hello"},
+					{"role": "assistant", "content": "Hi!"},
+					{"role": "user", "content": "How are you?"}
+				],
+				"stop_sequences": ["END"],
+				"temperature": 0.5,
+				"max_tokens": 100
+			}`,
+		},
+		{
+			name: "anthropic without a system prompt",
+			template: `{
+				"model": "{{model}}",
+				"system": "{{system}}",
+				"messages": "{{chat}}"
+			}`,
+			request: types.ConsumeModelRequest{Messages: conversation[1:2]},
+			expected: `{
+				"model": "test-model",
+				"messages": [{"role": "user", "content": "Hello"}]
+			}`,
+		},
+		{
+			name: "gemini contents and parts",
+			template: `{
+				"systemInstruction": {"parts": [{"text": "{{system}}"}]},
+				"contents": "{{contents}}",
+				"generationConfig": {
+					"temperature": "{{options.temperature}}",
+					"maxOutputTokens": "{{options.max_tokens}}",
+					"stopSequences": "{{options.stop}}"
+				}
+			}`,
+			request: types.ConsumeModelRequest{Messages: conversation, Options: options},
+			expected: `{
+				"systemInstruction": {"parts": [{"text": "Be brief."}]},
+				"contents": [
+					{"role": "user", "parts": [{"text": "Hello"}]},
+					{"role": "model", "parts": [{"text": "Hi!"}]},
+					{"role": "user", "parts": [{"text": "How are you?"}]}
+				],
+				"generationConfig": {"temperature": 0.5, "maxOutputTokens": 100, "stopSequences": ["END"]}
+			}`,
+		},
+		{
+			name: "gemini drops empty sections",
+			template: `{
+				"systemInstruction": {"parts": [{"text": "{{system}}"}]},
+				"contents": "{{contents}}",
+				"generationConfig": {"temperature": "{{options.temperature}}"},
+				"safetySettings": []
+			}`,
+			request: types.ConsumeModelRequest{Messages: conversation[1:2]},
+			expected: `{
+				"contents": [{"role": "user", "parts": [{"text": "Hello"}]}],
+				"safetySettings": []
+			}`,
+		},
+		{
+			name: "plain completion prompt",
+			template: `{
+				"model": "{{model}}",
+				"prompt": "{{prompt}}",
+				"max_tokens": "{{options.max_tokens}}",
+				"echo": false
+			}`,
+			request: types.ConsumeModelRequest{Messages: conversation[:2], Options: options},
+			expected: `{
+				"model": "test-model",
+				"prompt": "System: Be brief.\n\nUser: Hello\n\nAssistant:",
+				"max_tokens": 100,
+				"echo": false
+			}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var template map[string]any
+			if err := json.Unmarshal([]byte(tt.template), &template); err != nil {
+				t.Fatalf("invalid template: %v", err)
+			}
+
+			rendered, err := utils.TransformRequest(template, &tt.request, "test-model")
+			if err != nil {
+				t.Fatalf("failed to transform request: %v", err)
+			}
+
+			// Compare through JSON so Go and decoded types line up
+			got, _ := json.Marshal(rendered)
+			var gotValue, expectedValue any
+			json.Unmarshal(got, &gotValue)
+			if err := json.Unmarshal([]byte(tt.expected), &expectedValue); err != nil {
+				t.Fatalf("invalid expected JSON: %v", err)
+			}
+			expected, _ := json.Marshal(expectedValue)
+			normalized, _ := json.Marshal(gotValue)
+			if string(normalized) != string(expected) {
+				t.Errorf("expected %s, got %s", expected, normalized)
+			}
+		})
+	}
+}
+
+func TestTransformRequestInvalidTemplate(t *testing.T) {
+	request := &types.ConsumeModelRequest{Messages: []types.ChatMessage{{Role: "user", Content: "Hello"}}}
+
+	for _, template := range []map[string]any{
+		{"model": "{{unknown}}"},
+		{"*": "{{model}}"},
+	} {
+		if _, err := utils.TransformRequest(template, request, "test-model"); err == nil {
+			t.Errorf("expected an error for template %v", template)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package tests
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/rs/zerolog"
+	"github.com/wmbryce/agent-c/app/service"
+)
+
+func TestReservationExpiry(t *testing.T) {
+	success := `{"choices": [{"message": {"content": "Hi"}}], "usage": {"prompt_tokens": 7, "completion_tokens": 5}}`
+
+	tests := []struct {
+		name        string
+		ttlSeconds  string
+		expectedTTL time.Duration
+	}{
+		{"default ttl", "", time.Hour},
+		{"configured ttl", "90", 90 * time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("RESERVATION_TTL_SECONDS", tt.ttlSeconds)
+
+			creds := testKey("key-1", "sk-1")
+			store := &MockStore{Creds: &creds}
+			logger := zerolog.Nop()
+			app := fiber.New()
+			svc := service.New(&logger, store, nil, app, &MockHTTPClient{Responses: providerResponses(1, success)})
+			app.Post("/api/v1/ai/consume", svc.ConsumeModel)
+
+			if resp := limitedConsume(t, app, ""); resp.StatusCode != 200 {
+				t.Fatalf("expected 200, got %d", resp.StatusCode)
+			}
+			if store.ReservedTTL != tt.expectedTTL {
+				t.Errorf("expected reservations to expire after %v, got %v", tt.expectedTTL, store.ReservedTTL)
+			}
+		})
+	}
+}
+
+func TestSweepReservations(t *testing.T) {
+	store := &MockStore{Expired: 2, Sweeps: make(chan int)}
+	logger := zerolog.Nop()
+	svc := service.New(&logger, store, nil, fiber.New(), &MockHTTPClient{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		svc.SweepReservations(ctx)
+		close(done)
+	}()
+
+	// Abandoned reservations are released as soon as the sweeper starts
+	select {
+	case released := <-store.Sweeps:
+		if released != 2 || store.Released != 2 {
+			t.Errorf("expected 2 expired reservations released, got %d (%d in total)", released, store.Released)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected the sweeper to release expired reservations right away")
+	}
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("expected the sweeper to stop once its context is done")
+	}
+}
@@ -0,0 +1,210 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gofiber/fiber/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/rs/zerolog"
+	"github.com/wmbryce/agent-c/app/service"
+	"github.com/wmbryce/agent-c/app/store/cache"
+)
+
+// responseCacheApp serves ConsumeModel and the compatible APIs with a response cache backed by
+// an in-process Redis
+func responseCacheApp(t *testing.T, store *MockStore, httpClient *MockHTTPClient) (*fiber.App, *miniredis.Miniredis) {
+	redisServer := miniredis.RunT(t)
+	cacheStore := cache.New(redis.NewClient(&redis.Options{Addr: redisServer.Addr()}))
+
+	logger := zerolog.Nop()
+	app := fiber.New()
+	svc := service.New(&logger, store, cacheStore, app, httpClient)
+	app.Post("/api/v1/ai/consume", svc.ConsumeModel)
+	app.Post("/v1/chat/completions", svc.ChatCompletions)
+	app.Post("/v1/messages", svc.Messages)
+	return app, redisServer
+}
+
+// cachedConsume sends a consume request and returns its status, X-Cache header and body
+func cachedConsume(t *testing.T, app *fiber.App, request map[string]any, cacheControl string) (int, string, map[string]any) {
+	t.Helper()
+
+	body, _ := json.Marshal(request)
+	req := httptest.NewRequest("POST", "/api/v1/ai/consume", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if cacheControl != "" {
+		req.Header.Set("Cache-Control", cacheControl)
+	}
+
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatalf("failed to execute request: %v", err)
+	}
+	var result map[string]any
+	json.NewDecoder(resp.Body).Decode(&result)
+	return resp.StatusCode, resp.Header.Get("X-Cache"), result
+}
+
+func consumeRequest(content string, options map[string]any) map[string]any {
+	return map[string]any{
+		"model_key": "gpt-4",
+		"messages":  []map[string]string{{"role": "user", "content": content}},
+		"options":   options,
+		"max_cost":  1.0,
+	}
+}
+
+func TestResponseCache(t *testing.T) {
+	success := `{"choices": [{"message": {"content": "Hi"}}], "usage": {"prompt_tokens": 7, "completion_tokens": 5}}`
+	deterministic := map[string]any{"temperature": 0}
+
+	t.Run("serves repeated deterministic requests from the cache", func(t *testing.T) {
+		creds := testKey("key-1", "sk-1")
+		store := &MockStore{Creds: &creds}
+		httpClient := &MockHTTPClient{Responses: providerResponses(3, success)}
+		app, _ := responseCacheApp(t, store, httpClient)
+
+		status, xCache, result := cachedConsume(t, app, consumeRequest("Hello", deterministic), "")
+		if status != 200 || xCache != "MISS" || result["cached"] != false {
+			t.Fatalf("expected an uncached 200 MISS, got %d %q: %v", status, xCache, result)
+		}
+
+		status, xCache, result = cachedConsume(t, app, consumeRequest("Hello", deterministic), "")
+		if status != 200 || xCache != "HIT" || result["cached"] != true {
+			t.Fatalf("expected a cached 200 HIT, got %d %q: %v", status, xCache, result)
+		}
+		response := result["response"].(map[string]any)
+		if response["content"] != "Hi" {
+			t.Errorf("expected the cached content, got %v", response["content"])
+		}
+		if _, charged := response["cost"]; charged {
+			t.Errorf("expected a cache hit to cost nothing, got %v", response["cost"])
+		}
+		if len(httpClient.Requests) != 1 || len(store.Reserved) != 1 || len(store.Usage) != 1 {
+			t.Errorf("expected one provider call, reservation and usage record, got %d, %d and %d",
+				len(httpClient.Requests), len(store.Reserved), len(store.Usage))
+		}
+
+		// A different conversation misses
+		cachedConsume(t, app, consumeRequest("Goodbye", deterministic), "")
+		if len(httpClient.Requests) != 2 {
+			t.Errorf("expected a different request to reach the provider, got %d calls", len(httpClient.Requests))
+		}
+	})
+
+	t.Run("only caches deterministic or opted in requests", func(t *testing.T) {
+		creds := testKey("key-1", "sk-1")
+		store := &MockStore{Creds: &creds}
+		httpClient := &MockHTTPClient{Responses: providerResponses(3, success)}
+		app, _ := responseCacheApp(t, store, httpClient)
+
+		sampled := map[string]any{"temperature": 0.7}
+		cachedConsume(t, app, consumeRequest("Hello", sampled), "")
+		_, xCache, _ := cachedConsume(t, app, consumeRequest("Hello", sampled), "")
+		if xCache != "" || len(httpClient.Requests) != 2 {
+			t.Errorf("expected sampled requests to bypass the cache, got %q after %d calls", xCache, len(httpClient.Requests))
+		}
+
+		optedIn := consumeRequest("Hello", sampled)
+		optedIn["cache"] = true
+		cachedConsume(t, app, optedIn, "")
+		_, xCache, _ = cachedConsume(t, app, optedIn, "")
+		if xCache != "HIT" || len(httpClient.Requests) != 3 {
+			t.Errorf("expected an opted in request to be cached, got %q after %d calls", xCache, len(httpClient.Requests))
+		}
+	})
+
+	t.Run("Cache-Control bypasses the cache", func(t *testing.T) {
+		creds := testKey("key-1", "sk-1")
+		store := &MockStore{Creds: &creds}
+		httpClient := &MockHTTPClient{Responses: providerResponses(3, success)}
+		app, _ := responseCacheApp(t, store, httpClient)
+
+		cachedConsume(t, app, consumeRequest("Hello", deterministic), "no-store")
+		_, xCache, _ := cachedConsume(t, app, consumeRequest("Hello", deterministic), "")
+		if xCache != "MISS" {
+			t.Errorf("expected a no-store response not to be cached, got %q", xCache)
+		}
+
+		_, xCache, _ = cachedConsume(t, app, consumeRequest("Hello", deterministic), "no-cache")
+		if xCache != "MISS" || len(httpClient.Requests) != 3 {
+			t.Errorf("expected no-cache to reach the provider, got %q after %d calls", xCache, len(httpClient.Requests))
+		}
+	})
+
+	// The facades decode temperature as float32 rather than float64
+	for _, facade := range []struct {
+		path    string
+		request string
+	}{
+		{"/v1/chat/completions", `{"model": "gpt-4", "messages": [{"role": "user", "content": "Hello"}], "temperature": 0}`},
+		{"/v1/messages", `{"model": "gpt-4", "max_tokens": 100, "messages": [{"role": "user", "content": "Hello"}], "temperature": 0}`},
+	} {
+		t.Run("serves deterministic "+facade.path+" requests from the cache", func(t *testing.T) {
+			creds := testKey("key-1", "sk-1")
+			store := &MockStore{Creds: &creds}
+			httpClient := &MockHTTPClient{Responses: providerResponses(2, success)}
+			app, _ := responseCacheApp(t, store, httpClient)
+
+			var xCache []string
+			for range 2 {
+				req := httptest.NewRequest("POST", facade.path, strings.NewReader(facade.request))
+				req.Header.Set("Content-Type", "application/json")
+				resp, err := app.Test(req)
+				if err != nil {
+					t.Fatalf("failed to execute request: %v", err)
+				}
+				if resp.StatusCode != 200 {
+					t.Fatalf("expected 200, got %d", resp.StatusCode)
+				}
+				xCache = append(xCache, resp.Header.Get("X-Cache"))
+			}
+			if xCache[0] != "MISS" || xCache[1] != "HIT" || len(httpClient.Requests) != 1 {
+				t.Errorf("expected a MISS then a HIT, got %v after %d provider calls", xCache, len(httpClient.Requests))
+			}
+		})
+	}
+
+	t.Run("uses the model's ttl", func(t *testing.T) {
+		// The model is changed in the store directly rather than through the admin API
+		t.Setenv("CREDENTIALS_CACHE_SIZE", "0")
+
+		ttl := 60
+		creds := testKey("key-1", "sk-1")
+		creds.ResponseCacheTTL = &ttl
+		store := &MockStore{Creds: &creds}
+		httpClient := &MockHTTPClient{Responses: providerResponses(3, success)}
+		app, redisServer := responseCacheApp(t, store, httpClient)
+
+		cachedConsume(t, app, consumeRequest("Hello", deterministic), "")
+		redisServer.FastForward(61 * time.Second)
+		_, xCache, _ := cachedConsume(t, app, consumeRequest("Hello", deterministic), "")
+		if xCache != "MISS" || len(httpClient.Requests) != 2 {
+			t.Errorf("expected the cached response to expire, got %q after %d calls", xCache, len(httpClient.Requests))
+		}
+
+		disabled := 0
+		creds.ResponseCacheTTL = &disabled
+		store.Creds = &creds
+		_, xCache, _ = cachedConsume(t, app, consumeRequest("Hello", deterministic), "")
+		if xCache != "" || len(httpClient.Requests) != 3 {
+			t.Errorf("expected a ttl of 0 to disable the cache, got %q after %d calls", xCache, len(httpClient.Requests))
+		}
+	})
+}
+
+// providerResponses returns n fresh provider responses, one per expected provider call
+func providerResponses(n int, body string) []*http.Response {
+	responses := make([]*http.Response, n)
+	for i := range responses {
+		responses[i] = providerResponse(200, nil, body)
+	}
+	return responses
+}
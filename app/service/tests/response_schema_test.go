@@ -0,0 +1,153 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/rs/zerolog"
+	"github.com/wmbryce/agent-c/app/service"
+	"github.com/wmbryce/agent-c/app/types"
+)
+
+// testResponseSchema requires the fields the test response mapping reads
+const testResponseSchema = `{
+	"type": "object",
+	"properties": {
+		"choices": {
+			"type": "array",
+			"minItems": 1,
+			"items": {
+				"type": "object",
+				"properties": {"message": {"type": "object", "required": ["content"]}},
+				"required": ["message"]
+			}
+		},
+		"usage": {
+			"type": "object",
+			"properties": {"prompt_tokens": {"type": "integer"}, "completion_tokens": {"type": "integer"}},
+			"required": ["prompt_tokens", "completion_tokens"]
+		}
+	},
+	"required": ["choices", "usage"]
+}`
+
+func TestConsumeModelResponseSchema(t *testing.T) {
+	logger := zerolog.Nop()
+	success := `{"choices": [{"message": {"content": "Hi"}}], "usage": {"prompt_tokens": 7, "completion_tokens": 5}}`
+	// The provider renamed its usage fields
+	drifted := `{"choices": [{"message": {"content": "Hi"}}], "usage": {"input_tokens": 7, "output_tokens": 5}}`
+
+	tests := []struct {
+		name           string
+		failClosed     string
+		body           string
+		expectedStatus int
+		expectedDrift  int
+		// expectedSettled is the tokens a rejected response is charged, or -1 for the whole reservation
+		expectedSettled int
+	}{
+		{
+			name:           "matching response",
+			body:           success,
+			expectedStatus: 200,
+			expectedDrift:  0,
+		},
+		{
+			name:           "drift is logged and counted",
+			body:           drifted,
+			expectedStatus: 200,
+			expectedDrift:  1,
+		},
+		{
+			name:            "drift fails closed",
+			failClosed:      "true",
+			body:            drifted,
+			expectedStatus:  502,
+			expectedDrift:   1,
+			expectedSettled: -1,
+		},
+		{
+			name:            "drift with readable usage is charged for it",
+			failClosed:      "true",
+			body:            `{"choices": [], "usage": {"prompt_tokens": 7, "completion_tokens": 5}}`,
+			expectedStatus:  502,
+			expectedDrift:   1,
+			expectedSettled: 12,
+		},
+		{
+			name:            "invalid json is drift",
+			failClosed:      "true",
+			body:            `not json`,
+			expectedStatus:  502,
+			expectedDrift:   1,
+			expectedSettled: -1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("RESPONSE_SCHEMA_FAIL_CLOSED", tt.failClosed)
+
+			creds := testKey("key-1", "sk-1")
+			creds.ProviderName = "OpenAI"
+			creds.ResponseSchemaID = "openai-response"
+			store := &MockStore{
+				Creds:   &creds,
+				Schemas: map[string]json.RawMessage{"openai-response": json.RawMessage(testResponseSchema)},
+			}
+			httpClient := &MockHTTPClient{Responses: []*http.Response{providerResponse(200, nil, tt.body)}}
+
+			app := fiber.New()
+			svc := service.New(&logger, store, nil, app, httpClient)
+			app.Post("/api/v1/ai/consume", svc.ConsumeModel)
+			app.Get("/api/v1/ai/providers/health", svc.GetProvidersHealth)
+
+			body, _ := json.Marshal(types.ConsumeModelRequest{
+				ModelKey: "gpt-4",
+				Messages: []types.ChatMessage{{Role: "user", Content: "Hello"}},
+				MaxCost:  1,
+			})
+			req := httptest.NewRequest("POST", "/api/v1/ai/consume", bytes.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatalf("failed to execute request: %v", err)
+			}
+			if resp.StatusCode != tt.expectedStatus {
+				t.Errorf("expected status %d, got %d", tt.expectedStatus, resp.StatusCode)
+			}
+
+			// A rejected response was still served, so it is charged rather than retried
+			if tt.expectedSettled != 0 {
+				expected := tt.expectedSettled
+				if expected == -1 {
+					expected = store.Reserved[0]
+				}
+				if len(store.Reserved) != 1 || store.Released != 0 || len(store.Settled) != 1 || store.Settled[0] != expected {
+					t.Errorf("expected one reservation settled at %d, got reserved %v, settled %v, released %d",
+						expected, store.Reserved, store.Settled, store.Released)
+				}
+				if len(store.Usage) != 1 {
+					t.Errorf("expected the call's usage to be recorded, got %d records", len(store.Usage))
+				}
+			}
+
+			resp, err = app.Test(httptest.NewRequest("GET", "/api/v1/ai/providers/health", nil))
+			if err != nil {
+				t.Fatalf("failed to execute request: %v", err)
+			}
+			var health struct {
+				Providers []types.ProviderHealth `json:"providers"`
+			}
+			json.NewDecoder(resp.Body).Decode(&health)
+			if len(health.Providers) != 1 || health.Providers[0].SchemaDrift != tt.expectedDrift {
+				t.Errorf("expected schema drift %d, got %+v", tt.expectedDrift, health.Providers)
+			}
+		})
+	}
+}
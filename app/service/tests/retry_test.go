@@ -0,0 +1,150 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/rs/zerolog"
+	"github.com/wmbryce/agent-c/app/service"
+	"github.com/wmbryce/agent-c/app/types"
+)
+
+func providerResponse(status int, header http.Header, body string) *http.Response {
+	if header == nil {
+		header = http.Header{}
+	}
+	return &http.Response{StatusCode: status, Header: header, Body: io.NopCloser(strings.NewReader(body))}
+}
+
+func TestConsumeModelRetry(t *testing.T) {
+	logger := zerolog.Nop()
+	success := `{"choices": [{"message": {"content": "Hi"}}], "usage": {"prompt_tokens": 7, "completion_tokens": 5}}`
+
+	tests := []struct {
+		name             string
+		responses        []*http.Response
+		expectedStatus   int
+		expectedRequests int
+	}{
+		{
+			name: "retries unavailable provider",
+			responses: []*http.Response{
+				providerResponse(503, nil, `{"error": "unavailable"}`),
+				providerResponse(200, nil, success),
+			},
+			expectedStatus:   200,
+			expectedRequests: 2,
+		},
+		{
+			name: "honors retry-after-ms",
+			responses: []*http.Response{
+				providerResponse(429, http.Header{"Retry-After-Ms": {"5"}}, `{"error": "rate limited"}`),
+				providerResponse(200, nil, success),
+			},
+			expectedStatus:   200,
+			expectedRequests: 2,
+		},
+		{
+			name: "honors exhausted openai rate limit reset",
+			responses: []*http.Response{
+				providerResponse(429, http.Header{
+					"X-Ratelimit-Remaining-Tokens": {"0"},
+					"X-Ratelimit-Reset-Tokens":     {"10ms"},
+				}, `{"error": "rate limited"}`),
+				providerResponse(200, nil, success),
+			},
+			expectedStatus:   200,
+			expectedRequests: 2,
+		},
+		{
+			name: "retry-after beyond max delay is not retried",
+			responses: []*http.Response{
+				providerResponse(429, http.Header{"Retry-After": {"60"}}, `{"error": "rate limited"}`),
+			},
+			expectedStatus:   429,
+			expectedRequests: 1,
+		},
+		{
+			name: "gives up after max attempts",
+			responses: []*http.Response{
+				providerResponse(503, nil, `{"error": "unavailable"}`),
+				providerResponse(503, nil, `{"error": "unavailable"}`),
+				providerResponse(503, nil, `{"error": "unavailable"}`),
+			},
+			expectedStatus:   503,
+			expectedRequests: 3,
+		},
+		{
+			name: "does not retry server errors outside the policy",
+			responses: []*http.Response{
+				providerResponse(500, nil, `{"error": "internal"}`),
+			},
+			expectedStatus:   500,
+			expectedRequests: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			creds := testKey("key-1", "sk-one")
+			creds.ProviderConfig.RetryPolicy = &types.RetryPolicy{
+				MaxAttempts:   3,
+				BaseDelayMs:   1,
+				MaxDelayMs:    50,
+				RetryStatuses: []int{429, 503},
+			}
+			store := &MockStore{Creds: &creds}
+			httpClient := &MockHTTPClient{Responses: tt.responses}
+
+			app := fiber.New()
+			svc := service.New(&logger, store, nil, app, httpClient)
+			app.Post("/api/v1/ai/consume", svc.ConsumeModel)
+
+			body, _ := json.Marshal(types.ConsumeModelRequest{
+				ModelKey: "gpt-4",
+				Messages: []types.ChatMessage{{Role: "user", Content: "Hello"}},
+				MaxCost:  100,
+			})
+			req := httptest.NewRequest("POST", "/api/v1/ai/consume", bytes.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatalf("failed to execute request: %v", err)
+			}
+			if resp.StatusCode != tt.expectedStatus {
+				t.Errorf("expected status %d, got %d", tt.expectedStatus, resp.StatusCode)
+			}
+			if len(httpClient.Requests) != tt.expectedRequests {
+				t.Fatalf("expected %d provider requests, got %d", tt.expectedRequests, len(httpClient.Requests))
+			}
+
+			// Every attempt replays the same body under one idempotency key and one reservation
+			key := httpClient.Requests[0].Header.Get("Idempotency-Key")
+			if key == "" {
+				t.Error("expected an Idempotency-Key header")
+			}
+			for i, r := range httpClient.Requests {
+				if got := r.Header.Get("Idempotency-Key"); got != key {
+					t.Errorf("request %d: expected Idempotency-Key %q, got %q", i, key, got)
+				}
+				replayed, _ := io.ReadAll(r.Body)
+				if !bytes.Contains(replayed, []byte(`"model":"gpt-4"`)) {
+					t.Errorf("request %d: expected the payload to be replayed, got %q", i, replayed)
+				}
+			}
+			if len(store.Reserved) != 1 {
+				t.Errorf("expected a single reservation, got %d", len(store.Reserved))
+			}
+			if len(store.Usage) != 1 {
+				t.Errorf("expected a single usage record, got %d", len(store.Usage))
+			}
+		})
+	}
+}
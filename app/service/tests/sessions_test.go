@@ -0,0 +1,148 @@
+package tests
+
+import (
+	"crypto/ecdsa"
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/gofiber/fiber/v2"
+	"github.com/wmbryce/agent-c/app/types"
+	"github.com/wmbryce/agent-c/app/utils"
+)
+
+// signIn logs a wallet in and returns its tokens
+func signIn(t *testing.T, app *fiber.App, key *ecdsa.PrivateKey) types.AuthTokens {
+	t.Helper()
+	message := siweMessage("agent-c.test", key, issueNonce(t, app), time.Now().Add(5*time.Minute))
+	status, result := adminRequest(t, app, "POST", "/api/v1/auth/login", types.SiweLoginRequest{
+		Message:   message,
+		Signature: signMessage(t, key, message),
+	})
+	if status != 200 {
+		t.Fatalf("expected 200 signing in, got %d: %v", status, result)
+	}
+	return decodeTokens(t, result)
+}
+
+func decodeTokens(t *testing.T, result map[string]any) types.AuthTokens {
+	t.Helper()
+	raw, _ := json.Marshal(result["tokens"])
+	var tokens types.AuthTokens
+	if err := json.Unmarshal(raw, &tokens); err != nil {
+		t.Fatalf("failed to decode tokens: %v", err)
+	}
+	return tokens
+}
+
+func refresh(t *testing.T, app *fiber.App, token string) (int, map[string]any) {
+	return adminRequest(t, app, "POST", "/api/v1/auth/refresh", types.RefreshRequest{RefreshToken: token})
+}
+
+// getSessions lists sessions with an access token
+func getSessions(t *testing.T, app *fiber.App, access string) []any {
+	t.Helper()
+	req := httptest.NewRequest("GET", "/api/v1/auth/sessions", nil)
+	req.Header.Set("Authorization", "Bearer "+access)
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatalf("failed to execute request: %v", err)
+	}
+	var result map[string]any
+	json.NewDecoder(resp.Body).Decode(&result)
+	if resp.StatusCode != 200 {
+		t.Fatalf("expected 200 listing sessions, got %d: %v", resp.StatusCode, result)
+	}
+	return result["sessions"].([]any)
+}
+
+func TestRefreshTokenRotation(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	wallet := crypto.PubkeyToAddress(key.PublicKey).Hex()
+	store := &MockStore{Consumers: map[string]types.Consumer{"consumer-1": {ID: "consumer-1", WalletAddress: wallet}}}
+	app, _ := siweApp(t, store)
+
+	login := signIn(t, app, key)
+
+	// Refreshing rotates the token within the same session
+	status, result := refresh(t, app, login.Refresh)
+	if status != 200 {
+		t.Fatalf("expected 200, got %d: %v", status, result)
+	}
+	rotated := decodeTokens(t, result)
+	if rotated.Refresh == login.Refresh || rotated.SessionID != login.SessionID {
+		t.Errorf("expected a new refresh token in session %s, got %+v", login.SessionID, rotated)
+	}
+	if len(rotated.Roles) != 1 || rotated.Roles[0] != types.RoleConsumer {
+		t.Errorf("expected the consumer role, got %v", rotated.Roles)
+	}
+
+	// Reusing the old token revokes the session, so the rotated token stops working too
+	if status, _ := refresh(t, app, login.Refresh); status != 401 {
+		t.Errorf("expected 401 reusing a refresh token, got %d", status)
+	}
+	if status, _ := refresh(t, app, rotated.Refresh); status != 401 {
+		t.Errorf("expected 401 for a token of a revoked session, got %d", status)
+	}
+
+	for _, token := range []string{"", "no-dot", "abc.notanumber", "abc.1"} {
+		if status, _ := refresh(t, app, token); status != 400 && status != 401 {
+			t.Errorf("expected %q to be rejected, got %d", token, status)
+		}
+	}
+}
+
+func TestSessionsAndLogout(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	wallet := crypto.PubkeyToAddress(key.PublicKey).Hex()
+	store := &MockStore{Sellers: map[string]types.Seller{"seller-1": {ID: "seller-1", WalletAddress: wallet}}}
+	app, _ := siweApp(t, store)
+
+	laptop := signIn(t, app, key)
+	phone := signIn(t, app, key)
+	tablet := signIn(t, app, key)
+
+	if sessions := getSessions(t, app, laptop.Access); len(sessions) != 3 {
+		t.Fatalf("expected 3 sessions, got %d", len(sessions))
+	}
+
+	// Logging out ends only that session
+	status, _ := adminRequest(t, app, "POST", "/api/v1/auth/logout", types.RefreshRequest{RefreshToken: phone.Refresh})
+	if status != 200 {
+		t.Errorf("expected 200 logging out, got %d", status)
+	}
+	if status, _ := refresh(t, app, phone.Refresh); status != 401 {
+		t.Errorf("expected 401 refreshing a logged out session, got %d", status)
+	}
+
+	// A session can be revoked by id from another one
+	req := httptest.NewRequest("DELETE", "/api/v1/auth/sessions/"+tablet.SessionID, nil)
+	req.Header.Set("Authorization", "Bearer "+laptop.Access)
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatalf("failed to execute request: %v", err)
+	}
+	if resp.StatusCode != 200 {
+		t.Errorf("expected 200 revoking a session, got %d", resp.StatusCode)
+	}
+
+	sessions := getSessions(t, app, laptop.Access)
+	if len(sessions) != 1 || sessions[0].(map[string]any)["id"] != laptop.SessionID {
+		t.Errorf("expected only the laptop session, got %v", sessions)
+	}
+	if status, _ := refresh(t, app, laptop.Refresh); status != 200 {
+		t.Errorf("expected the remaining session to refresh, got %d", status)
+	}
+}
+
+func TestParseRefreshToken(t *testing.T) {
+	if _, err := utils.ParseRefreshToken("no-dot"); err == nil {
+		t.Error("expected an error for a token without an expiry")
+	}
+	expires, err := utils.ParseRefreshToken("abc.1700000000")
+	if err != nil || expires != 1700000000 {
+		t.Errorf("expected 1700000000, got %d, %v", expires, err)
+	}
+}
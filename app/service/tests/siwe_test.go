@@ -0,0 +1,254 @@
+package tests
+
+import (
+	"crypto/ecdsa"
+	"fmt"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/gofiber/fiber/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/rs/zerolog"
+	"github.com/wmbryce/agent-c/app/middleware"
+	"github.com/wmbryce/agent-c/app/service"
+	"github.com/wmbryce/agent-c/app/store/cache"
+	"github.com/wmbryce/agent-c/app/types"
+)
+
+// siweApp serves the sign-in and session handlers, an admin-only route and a nonce store backed by miniredis
+func siweApp(t *testing.T, store *MockStore) (*fiber.App, *miniredis.Miniredis) {
+	t.Setenv("JWT_SECRET_KEY_EXPIRE_MINUTES_COUNT", "15")
+	t.Setenv("JWT_REFRESH_KEY_EXPIRE_HOURS_COUNT", "720")
+	t.Setenv("SIWE_DOMAIN", "agent-c.test")
+
+	redisServer := miniredis.RunT(t)
+	cacheStore := cache.New(redis.NewClient(&redis.Options{Addr: redisServer.Addr()}))
+
+	logger := zerolog.Nop()
+	app := fiber.New()
+	svc := service.New(&logger, store, cacheStore, app, nil)
+	app.Post("/api/v1/auth/nonce", svc.CreateNonce)
+	app.Post("/api/v1/auth/login", svc.Login)
+	app.Post("/api/v1/auth/refresh", svc.Refresh)
+	app.Post("/api/v1/auth/logout", svc.Logout)
+	app.Get("/api/v1/auth/sessions", middleware.JWTProtected(svc.JWTKeys()), svc.GetSessions)
+	app.Delete("/api/v1/auth/sessions/:id", middleware.JWTProtected(svc.JWTKeys()), svc.RevokeSession)
+	app.Get("/api/v1/admin", middleware.JWTProtected(svc.JWTKeys()), middleware.PermissionRequired(types.PermissionProvidersAdmin), func(c *fiber.Ctx) error {
+		return c.SendStatus(fiber.StatusOK)
+	})
+	return app, redisServer
+}
+
+// siweMessage builds an EIP-4361 message for the wallet of key
+func siweMessage(domain string, key *ecdsa.PrivateKey, nonce string, expires time.Time) string {
+	return fmt.Sprintf(`%s wants you to sign in with your Ethereum account:
+%s
+
+Sign in to agent-c.
+
+URI: https://%s/login
+Version: 1
+Chain ID: 1
+Nonce: %s
+Issued At: %s
+Expiration Time: %s`,
+		domain, crypto.PubkeyToAddress(key.PublicKey).Hex(), domain, nonce,
+		time.Now().UTC().Format(time.RFC3339), expires.UTC().Format(time.RFC3339))
+}
+
+// signMessage signs a message the way wallets do for personal_sign
+func signMessage(t *testing.T, key *ecdsa.PrivateKey, message string) string {
+	t.Helper()
+	sig, err := crypto.Sign(accounts.TextHash([]byte(message)), key)
+	if err != nil {
+		t.Fatalf("failed to sign message: %v", err)
+	}
+	sig[crypto.RecoveryIDOffset] += 27
+	return hexutil.Encode(sig)
+}
+
+func issueNonce(t *testing.T, app *fiber.App) string {
+	t.Helper()
+	status, result := adminRequest(t, app, "POST", "/api/v1/auth/nonce", nil)
+	if status != 200 {
+		t.Fatalf("expected 200 issuing a nonce, got %d: %v", status, result)
+	}
+	return result["nonce"].(map[string]any)["nonce"].(string)
+}
+
+func TestSiweLogin(t *testing.T) {
+	sellerKey, _ := crypto.GenerateKey()
+	adminKey, _ := crypto.GenerateKey()
+	strangerKey, _ := crypto.GenerateKey()
+	sellerWallet := crypto.PubkeyToAddress(sellerKey.PublicKey).Hex()
+
+	store := &MockStore{Sellers: map[string]types.Seller{"seller-1": {ID: "seller-1", WalletAddress: sellerWallet}}}
+	t.Setenv("ADMIN_WALLETS", crypto.PubkeyToAddress(adminKey.PublicKey).Hex())
+	app, redisServer := siweApp(t, store)
+
+	expires := time.Now().Add(5 * time.Minute)
+	login := func(message, signature string) (int, map[string]any) {
+		return adminRequest(t, app, "POST", "/api/v1/auth/login", types.SiweLoginRequest{
+			Message:   message,
+			Signature: signature,
+		})
+	}
+
+	// A seller signs in and gets the seller role
+	message := siweMessage("agent-c.test", sellerKey, issueNonce(t, app), expires)
+	status, result := login(message, signMessage(t, sellerKey, message))
+	if status != 200 {
+		t.Fatalf("expected 200, got %d: %v", status, result)
+	}
+	tokens := result["tokens"].(map[string]any)
+	if roles := tokens["roles"].([]any); len(roles) != 1 || roles[0] != types.RoleSeller {
+		t.Errorf("expected the seller role, got %v", roles)
+	}
+	sellerToken := tokens["access"].(string)
+
+	// The same signed message cannot be replayed
+	if status, _ := login(message, signMessage(t, sellerKey, message)); status != 401 {
+		t.Errorf("expected 401 for a replayed nonce, got %d", status)
+	}
+
+	tests := []struct {
+		name           string
+		message        func() string
+		signer         *ecdsa.PrivateKey
+		expectedStatus int
+	}{
+		{"unknown nonce", func() string {
+			return siweMessage("agent-c.test", sellerKey, "0123456789abcdef", expires)
+		}, sellerKey, 401},
+		{"other domain", func() string {
+			return siweMessage("evil.test", sellerKey, issueNonce(t, app), expires)
+		}, sellerKey, 401},
+		{"expired message", func() string {
+			return siweMessage("agent-c.test", sellerKey, issueNonce(t, app), time.Now().Add(-time.Minute))
+		}, sellerKey, 401},
+		{"signed by another wallet", func() string {
+			return siweMessage("agent-c.test", sellerKey, issueNonce(t, app), expires)
+		}, strangerKey, 401},
+		{"expired nonce", func() string {
+			nonce := issueNonce(t, app)
+			redisServer.FastForward(11 * time.Minute)
+			return siweMessage("agent-c.test", sellerKey, nonce, expires)
+		}, sellerKey, 401},
+		{"unregistered wallet", func() string {
+			return siweMessage("agent-c.test", strangerKey, issueNonce(t, app), expires)
+		}, strangerKey, 403},
+		{"not a sign-in message", func() string { return "hello" }, sellerKey, 400},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			message := tt.message()
+			if status, result := login(message, signMessage(t, tt.signer, message)); status != tt.expectedStatus {
+				t.Errorf("expected %d, got %d: %v", tt.expectedStatus, status, result)
+			}
+		})
+	}
+
+	// Admin wallets get the admin role, which admin routes require
+	message = siweMessage("agent-c.test", adminKey, issueNonce(t, app), expires)
+	status, result = login(message, signMessage(t, adminKey, message))
+	if status != 200 {
+		t.Fatalf("expected 200, got %d: %v", status, result)
+	}
+	tokens = result["tokens"].(map[string]any)
+	if roles := tokens["roles"].([]any); !slices.Contains(roles, any(types.RoleAdmin)) {
+		t.Errorf("expected the admin role, got %v", roles)
+	}
+
+	for token, expected := range map[string]int{tokens["access"].(string): 200, sellerToken: 403} {
+		req := httptest.NewRequest("GET", "/api/v1/admin", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatalf("failed to execute request: %v", err)
+		}
+		if resp.StatusCode != expected {
+			t.Errorf("expected %d on the admin route, got %d", expected, resp.StatusCode)
+		}
+	}
+}
+
+func TestSiweLoginRequiresDomain(t *testing.T) {
+	t.Setenv("SIWE_DOMAIN", "")
+
+	sellerKey, _ := crypto.GenerateKey()
+	store := &MockStore{Sellers: map[string]types.Seller{
+		"seller-1": {ID: "seller-1", WalletAddress: crypto.PubkeyToAddress(sellerKey.PublicKey).Hex()},
+	}}
+	redisServer := miniredis.RunT(t)
+	logger := zerolog.Nop()
+	app := fiber.New()
+	svc := service.New(&logger, store, cache.New(redis.NewClient(&redis.Options{Addr: redisServer.Addr()})), app, nil)
+	app.Post("/api/v1/auth/nonce", svc.CreateNonce)
+	app.Post("/api/v1/auth/login", svc.Login)
+
+	if status, _ := adminRequest(t, app, "POST", "/api/v1/auth/nonce", nil); status != 503 {
+		t.Errorf("expected 503 issuing a nonce without SIWE_DOMAIN, got %d", status)
+	}
+
+	// A message issued for the Host the client sent is not accepted in place of a configured domain
+	message := siweMessage("example.com", sellerKey, "0123456789abcdef", time.Now().Add(5*time.Minute))
+	status, _ := adminRequest(t, app, "POST", "/api/v1/auth/login", types.SiweLoginRequest{
+		Message:   message,
+		Signature: signMessage(t, sellerKey, message),
+	})
+	if status != 503 {
+		t.Errorf("expected 503 signing in without SIWE_DOMAIN, got %d", status)
+	}
+}
+
+func TestSiweLoginRequiresSigningKeys(t *testing.T) {
+	t.Setenv("JWT_SIGNING_KEYS_DIR", "")
+
+	sellerKey, _ := crypto.GenerateKey()
+	store := &MockStore{Sellers: map[string]types.Seller{
+		"seller-1": {ID: "seller-1", WalletAddress: crypto.PubkeyToAddress(sellerKey.PublicKey).Hex()},
+	}}
+	app, _ := siweApp(t, store)
+
+	if status, _ := adminRequest(t, app, "POST", "/api/v1/auth/nonce", nil); status != 503 {
+		t.Errorf("expected 503 issuing a nonce without signing keys, got %d", status)
+	}
+
+	message := siweMessage("agent-c.test", sellerKey, "0123456789abcdef", time.Now().Add(5*time.Minute))
+	status, _ := adminRequest(t, app, "POST", "/api/v1/auth/login", types.SiweLoginRequest{
+		Message:   message,
+		Signature: signMessage(t, sellerKey, message),
+	})
+	if status != 503 {
+		t.Errorf("expected 503 signing in without signing keys, got %d", status)
+	}
+}
+
+func TestSiweLoginKeepsNonceOnRejection(t *testing.T) {
+	sellerKey, _ := crypto.GenerateKey()
+	sellerWallet := crypto.PubkeyToAddress(sellerKey.PublicKey).Hex()
+	store := &MockStore{}
+	app, _ := siweApp(t, store)
+
+	message := siweMessage("agent-c.test", sellerKey, issueNonce(t, app), time.Now().Add(5*time.Minute))
+	login := types.SiweLoginRequest{Message: message, Signature: signMessage(t, sellerKey, message)}
+
+	if status, result := adminRequest(t, app, "POST", "/api/v1/auth/login", login); status != 403 {
+		t.Fatalf("expected 403 for an unregistered wallet, got %d: %v", status, result)
+	}
+
+	// Once the wallet is registered the same signed message still signs in
+	store.Sellers = map[string]types.Seller{"seller-1": {ID: "seller-1", WalletAddress: sellerWallet}}
+	if status, result := adminRequest(t, app, "POST", "/api/v1/auth/login", login); status != 200 {
+		t.Fatalf("expected 200 retrying with the unused nonce, got %d: %v", status, result)
+	}
+	if status, _ := adminRequest(t, app, "POST", "/api/v1/auth/login", login); status != 401 {
+		t.Errorf("expected 401 replaying the used nonce, got %d", status)
+	}
+}
@@ -0,0 +1,278 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/rs/zerolog"
+	"github.com/wmbryce/agent-c/app/service"
+	"github.com/wmbryce/agent-c/app/types"
+	"github.com/wmbryce/agent-c/app/utils"
+)
+
+func TestConsumeModelStream(t *testing.T) {
+	logger := zerolog.Nop()
+
+	tests := []struct {
+		name            string
+		config          *types.ProviderConfig
+		providerStream  string
+		expectedContent string
+		expectedFinish  string
+		expectedUsage   types.GeneralChatResponse
+	}{
+		{
+			name: "openai deltas",
+			config: &types.ProviderConfig{
+				StreamMapping: map[string]string{
+					"id":                "$.id",
+					"model":             "$.model",
+					"content":           "$.choices[0].delta.content",
+					"role":              "$.choices[0].delta.role",
+					"finish_reason":     "$.choices[0].finish_reason",
+					"prompt_tokens":     "$.usage.prompt_tokens",
+					"completion_tokens": "$.usage.completion_tokens",
+					"total_tokens":      "$.usage.total_tokens",
+				},
+			},
+			providerStream: "data: {\"id\":\"chatcmpl-1\",\"model\":\"gpt-4\",\"choices\":[{\"delta\":{\"role\":\"assistant\",\"content\":\"\"}}]}\n\n" +
+				"data: {\"id\":\"chatcmpl-1\",\"model\":\"gpt-4\",\"choices\":[{\"delta\":{\"content\":\"Hi \"}}]}\n\n" +
+				"data: {\"id\":\"chatcmpl-1\",\"model\":\"gpt-4\",\"choices\":[{\"delta\":{\"content\":\"there!\"}}]}\n\n" +
+				"data: {\"id\":\"chatcmpl-1\",\"model\":\"gpt-4\",\"choices\":[{\"delta\":{},\"finish_reason\":\"stop\"}]}\n\n" +
+				"data: {\"id\":\"chatcmpl-1\",\"model\":\"gpt-4\",\"choices\":[],\"usage\":{\"prompt_tokens\":10,\"completion_tokens\":3,\"total_tokens\":13}}\n\n" +
+				"data: [DONE]\n\n",
+			expectedContent: "Hi there!",
+			expectedFinish:  "stop",
+			expectedUsage: types.GeneralChatResponse{
+				ID:               "chatcmpl-1",
+				Model:            "gpt-4",
+				Role:             "assistant",
+				Content:          "Hi there!",
+				FinishReason:     "stop",
+				PromptTokens:     10,
+				CompletionTokens: 3,
+				TotalTokens:      13,
+				Cost:             0.00048,
+			},
+		},
+		{
+			name: "anthropic events",
+			config: &types.ProviderConfig{
+				StreamMapping: map[string]string{
+					"id":                "$.message.id",
+					"model":             "$.message.model",
+					"content":           "$.delta.text",
+					"role":              "$.message.role",
+					"finish_reason":     "$.delta.stop_reason",
+					"prompt_tokens":     "$.message.usage.input_tokens",
+					"completion_tokens": "$.usage.output_tokens",
+				},
+			},
+			providerStream: "event: message_start\ndata: {\"type\":\"message_start\",\"message\":{\"id\":\"msg_1\",\"model\":\"claude-3\",\"role\":\"assistant\",\"usage\":{\"input_tokens\":12,\"output_tokens\":1}}}\n\n" +
+				"event: ping\ndata: {\"type\":\"ping\"}\n\n" +
+				"event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"index\":0,\"delta\":{\"type\":\"text_delta\",\"text\":\"Hello\"}}\n\n" +
+				"event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"index\":0,\"delta\":{\"type\":\"text_delta\",\"text\":\" world\"}}\n\n" +
+				"event: message_delta\ndata: {\"type\":\"message_delta\",\"delta\":{\"stop_reason\":\"end_turn\"},\"usage\":{\"output_tokens\":5}}\n\n" +
+				"event: message_stop\ndata: {\"type\":\"message_stop\"}\n\n",
+			expectedContent: "Hello world",
+			expectedFinish:  "end_turn",
+			expectedUsage: types.GeneralChatResponse{
+				ID:               "msg_1",
+				Model:            "claude-3",
+				Role:             "assistant",
+				Content:          "Hello world",
+				FinishReason:     "end_turn",
+				PromptTokens:     12,
+				CompletionTokens: 5,
+				TotalTokens:      17,
+				Cost:             0.00066,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &MockStore{
+				Creds: &types.ModelCredentials{
+					ModelKey:        "gpt-4",
+					RequestURL:      "https://api.example.com/v1/chat",
+					SealedKey:       sealKey("sk-test-key"),
+					TokensAvailable: 10000,
+					Price:           testPrice,
+					ProviderConfig:  tt.config,
+				},
+			}
+
+			httpClient := &MockHTTPClient{
+				Response: &http.Response{
+					StatusCode: 200,
+					Body:       io.NopCloser(strings.NewReader(tt.providerStream)),
+				},
+			}
+
+			app := fiber.New()
+			svc := service.New(&logger, store, nil, app, httpClient)
+			app.Post("/api/v1/ai/consume", svc.ConsumeModel)
+
+			body, err := json.Marshal(types.ConsumeModelRequest{
+				ModelKey: "gpt-4",
+				Messages: []types.ChatMessage{
+					{Role: "user", Content: "Hello"},
+				},
+				MaxCost: 100,
+				Stream:  true,
+			})
+			if err != nil {
+				t.Fatalf("failed to marshal request body: %v", err)
+			}
+
+			req := httptest.NewRequest("POST", "/api/v1/ai/consume", bytes.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatalf("failed to execute request: %v", err)
+			}
+
+			if resp.StatusCode != 200 {
+				t.Fatalf("expected status 200, got %d", resp.StatusCode)
+			}
+			if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+				t.Errorf("expected text/event-stream content type, got %q", ct)
+			}
+
+			var content, finish string
+			var usage *types.GeneralChatResponse
+			err = utils.ReadSSE(resp.Body, func(event utils.SSEEvent) error {
+				var chunk types.GeneralChatResponse
+				if err := json.Unmarshal([]byte(event.Data), &chunk); err != nil {
+					return err
+				}
+				switch event.Event {
+				case "delta":
+					content += chunk.Content
+					if chunk.FinishReason != "" {
+						finish = chunk.FinishReason
+					}
+				case "usage":
+					usage = &chunk
+				default:
+					t.Errorf("unexpected event %q: %s", event.Event, event.Data)
+				}
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("failed to read stream: %v", err)
+			}
+
+			if content != tt.expectedContent {
+				t.Errorf("expected content %q, got %q", tt.expectedContent, content)
+			}
+			if finish != tt.expectedFinish {
+				t.Errorf("expected finish_reason %q, got %q", tt.expectedFinish, finish)
+			}
+			if usage == nil {
+				t.Fatal("expected a usage event")
+			}
+			if *usage != tt.expectedUsage {
+				t.Errorf("expected usage %+v, got %+v", tt.expectedUsage, *usage)
+			}
+			if len(store.Settled) != 1 || store.Settled[0] != tt.expectedUsage.TotalTokens {
+				t.Errorf("expected reservation settled at %d tokens, got %v", tt.expectedUsage.TotalTokens, store.Settled)
+			}
+		})
+	}
+}
+
+// brokenStream serves body, then fails as a dropped connection would
+type brokenStream struct {
+	body io.Reader
+}
+
+func (b *brokenStream) Read(p []byte) (int, error) {
+	n, err := b.body.Read(p)
+	if err == io.EOF {
+		return n, io.ErrUnexpectedEOF
+	}
+	return n, err
+}
+
+func TestConsumeModelStreamCutOff(t *testing.T) {
+	logger := zerolog.Nop()
+
+	tests := []struct {
+		name           string
+		mapping        map[string]string
+		providerStream string
+		// expectedSettled is the estimated prompt, or the reported one, plus the relayed content
+		expectedSettled int
+	}{
+		{
+			name: "openai before the usage chunk",
+			mapping: map[string]string{
+				"content":           "$.choices[0].delta.content",
+				"prompt_tokens":     "$.usage.prompt_tokens",
+				"completion_tokens": "$.usage.completion_tokens",
+			},
+			providerStream: "data: {\"choices\":[{\"delta\":{\"content\":\"Hi \"}}]}\n\n" +
+				"data: {\"choices\":[{\"delta\":{\"content\":\"there!\"}}]}\n\n",
+			expectedSettled: 10 + 3,
+		},
+		{
+			name: "anthropic before message_delta",
+			mapping: map[string]string{
+				"content":           "$.delta.text",
+				"prompt_tokens":     "$.message.usage.input_tokens",
+				"completion_tokens": "$.usage.output_tokens",
+			},
+			providerStream: "event: message_start\ndata: {\"type\":\"message_start\",\"message\":{\"usage\":{\"input_tokens\":12,\"output_tokens\":1}}}\n\n" +
+				"event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"delta\":{\"text\":\"Hi there!\"}}\n\n",
+			expectedSettled: 12 + 3,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			creds := testKey("key-1", "sk-1")
+			creds.ProviderConfig = &types.ProviderConfig{StreamMapping: tt.mapping}
+			store := &MockStore{Creds: &creds}
+			httpClient := &MockHTTPClient{Response: &http.Response{
+				StatusCode: 200,
+				Body:       io.NopCloser(&brokenStream{body: strings.NewReader(tt.providerStream)}),
+			}}
+
+			app := fiber.New()
+			svc := service.New(&logger, store, nil, app, httpClient)
+			app.Post("/api/v1/ai/consume", svc.ConsumeModel)
+
+			body, _ := json.Marshal(types.ConsumeModelRequest{
+				ModelKey: "gpt-4",
+				Messages: []types.ChatMessage{{Role: "user", Content: "Hello"}},
+				MaxCost:  100,
+				Stream:   true,
+			})
+			req := httptest.NewRequest("POST", "/api/v1/ai/consume", bytes.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatalf("failed to execute request: %v", err)
+			}
+			io.ReadAll(resp.Body)
+
+			if store.Released != 0 || len(store.Settled) != 1 || store.Settled[0] != tt.expectedSettled {
+				t.Errorf("expected the reservation settled at %d tokens, got settled %v, released %d",
+					tt.expectedSettled, store.Settled, store.Released)
+			}
+			if len(store.Usage) != 1 || store.Usage[0].Cost == 0 {
+				t.Errorf("expected the relayed completion to be charged, got %+v", store.Usage)
+			}
+		})
+	}
+}
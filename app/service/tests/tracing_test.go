@@ -0,0 +1,141 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/rs/zerolog"
+	"github.com/wmbryce/agent-c/app/middleware"
+	"github.com/wmbryce/agent-c/app/routes"
+	"github.com/wmbryce/agent-c/app/service"
+	"github.com/wmbryce/agent-c/app/types"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// callerTraceparent is the trace context a caller sends with its request
+const callerTraceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+// recordSpans installs a tracer provider exporting to memory for the rest of the test
+func recordSpans(t *testing.T) *tracetest.InMemoryExporter {
+	exporter := tracetest.NewInMemoryExporter()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)))
+	t.Cleanup(func() { otel.SetTracerProvider(noop.NewTracerProvider()) })
+	return exporter
+}
+
+// tracedSpan returns the recorded span with the given name
+func tracedSpan(t *testing.T, exporter *tracetest.InMemoryExporter, name string) tracetest.SpanStub {
+	t.Helper()
+
+	var names []string
+	for _, span := range exporter.GetSpans() {
+		if span.Name == name {
+			return span
+		}
+		names = append(names, span.Name)
+	}
+	t.Fatalf("expected a %q span, got %v", name, names)
+	return tracetest.SpanStub{}
+}
+
+func TestTracing(t *testing.T) {
+	success := `{"choices": [{"message": {"content": "Hi"}}], "usage": {"prompt_tokens": 7, "completion_tokens": 5}}`
+
+	tests := []struct {
+		name           string
+		response       *http.Response
+		status         int
+		providerStatus codes.Code
+	}{
+		{"success", providerResponse(200, nil, success), 200, codes.Unset},
+		{"provider error", providerResponse(500, nil, `{"error": "overloaded"}`), 500, codes.Error},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exporter := recordSpans(t)
+
+			creds := testKey("key-1", "sk-1")
+			creds.ProviderName = "openai"
+			store := &MockStore{Creds: &creds}
+			httpClient := &MockHTTPClient{Responses: []*http.Response{tt.response}}
+
+			logger := zerolog.Nop()
+			app := fiber.New()
+			app.Use(middleware.Tracing())
+			svc := service.New(&logger, store, nil, app, httpClient)
+			routes.New(svc, store, nil).Setup(app)
+			key := consumerKey(t, store, types.RateLimit{}, types.PermissionConsume)
+
+			body, _ := json.Marshal(types.ConsumeModelRequest{
+				ModelKey: "gpt-4",
+				Messages: []types.ChatMessage{{Role: "user", Content: "Hello"}},
+				MaxCost:  1.0,
+			})
+			req := httptest.NewRequest("POST", "/api/v1/ai/consume", bytes.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("Authorization", "Bearer "+key)
+			req.Header.Set("traceparent", callerTraceparent)
+
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatalf("failed to execute request: %v", err)
+			}
+			if resp.StatusCode != tt.status {
+				t.Fatalf("expected %d, got %d", tt.status, resp.StatusCode)
+			}
+
+			// The handler span continues the caller's trace
+			server := tracedSpan(t, exporter, "POST /api/v1/ai/consume")
+			if server.SpanContext.TraceID().String() != "4bf92f3577b34da6a3ce929d0e0e4736" ||
+				server.Parent.SpanID().String() != "00f067aa0ba902b7" {
+				t.Errorf("expected the handler span to continue the caller's trace, got trace %s parent %s",
+					server.SpanContext.TraceID(), server.Parent.SpanID())
+			}
+
+			// The provider call is traced beneath it and the provider receives its context
+			provider := tracedSpan(t, exporter, "provider openai")
+			if provider.Parent.SpanID() != server.SpanContext.SpanID() {
+				t.Errorf("expected the provider span to be a child of the handler span")
+			}
+			if provider.Status.Code != tt.providerStatus {
+				t.Errorf("expected provider span status %v, got %v", tt.providerStatus, provider.Status.Code)
+			}
+			traceparent := httpClient.Requests[0].Header.Get("traceparent")
+			if !strings.Contains(traceparent, provider.SpanContext.TraceID().String()+"-"+provider.SpanContext.SpanID().String()) {
+				t.Errorf("expected the provider request to carry the provider span's traceparent, got %q", traceparent)
+			}
+		})
+	}
+
+	t.Run("traces the response transformation", func(t *testing.T) {
+		exporter := recordSpans(t)
+
+		creds := testKey("key-1", "sk-1")
+		store := &MockStore{Creds: &creds}
+		app := fiber.New()
+		app.Use(middleware.Tracing())
+		logger := zerolog.Nop()
+		svc := service.New(&logger, store, nil, app, &MockHTTPClient{Responses: providerResponses(1, success)})
+		app.Post("/api/v1/ai/consume", svc.ConsumeModel)
+
+		if resp := limitedConsume(t, app, ""); resp.StatusCode != 200 {
+			t.Fatalf("expected 200, got %d", resp.StatusCode)
+		}
+
+		server := tracedSpan(t, exporter, "POST /api/v1/ai/consume")
+		transform := tracedSpan(t, exporter, "transform response")
+		if transform.Parent.SpanID() != server.SpanContext.SpanID() {
+			t.Errorf("expected the transformation span to be a child of the handler span")
+		}
+	})
+}
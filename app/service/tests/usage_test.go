@@ -0,0 +1,221 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/rs/zerolog"
+	"github.com/wmbryce/agent-c/app/service"
+	"github.com/wmbryce/agent-c/app/types"
+)
+
+func TestConsumeModelRecordsUsage(t *testing.T) {
+	logger := zerolog.Nop()
+
+	store := &MockStore{
+		Creds: &types.ModelCredentials{
+			ModelKey:        "gpt-4",
+			RequestURL:      "https://api.openai.com/v1/chat/completions",
+			ApiKeyID:        "api-key-id",
+			SealedKey:       sealKey("sk-test-key"),
+			TokensAvailable: 10000,
+			Price:           testPrice,
+			ProviderConfig: &types.ProviderConfig{
+				ResponseMapping: map[string]string{
+					"content":           "$.choices[0].message.content",
+					"prompt_tokens":     "$.usage.prompt_tokens",
+					"completion_tokens": "$.usage.completion_tokens",
+				},
+			},
+		},
+	}
+	httpClient := &MockHTTPClient{
+		Response: &http.Response{
+			StatusCode: 200,
+			Body: io.NopCloser(strings.NewReader(
+				`{"choices": [{"message": {"content": "Hi"}}], "usage": {"prompt_tokens": 7, "completion_tokens": 5}}`,
+			)),
+		},
+	}
+
+	app := fiber.New()
+	svc := service.New(&logger, store, nil, app, httpClient)
+	app.Post("/api/v1/ai/consume", func(c *fiber.Ctx) error {
+		c.Locals("consumer_id", "consumer-id")
+		return c.Next()
+	}, svc.ConsumeModel)
+
+	body, _ := json.Marshal(types.ConsumeModelRequest{
+		ModelKey: "gpt-4",
+		Messages: []types.ChatMessage{{Role: "user", Content: "Hello"}},
+		MaxCost:  100,
+	})
+	req := httptest.NewRequest("POST", "/api/v1/ai/consume", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatalf("failed to execute request: %v", err)
+	}
+	if resp.StatusCode != 200 {
+		t.Fatalf("expected status 200, got %d", resp.StatusCode)
+	}
+
+	if len(store.Usage) != 1 {
+		t.Fatalf("expected 1 usage record, got %d", len(store.Usage))
+	}
+	record := store.Usage[0]
+	if record.ConsumerID == nil || *record.ConsumerID != "consumer-id" {
+		t.Errorf("expected usage attributed to consumer-id, got %v", record.ConsumerID)
+	}
+	if record.ApiKeyID == nil || *record.ApiKeyID != "api-key-id" {
+		t.Errorf("expected usage attributed to api-key-id, got %v", record.ApiKeyID)
+	}
+	if record.ModelKey != "gpt-4" || record.ProviderStatus != 200 {
+		t.Errorf("unexpected usage record: %+v", record)
+	}
+	if record.PromptTokens != 7 || record.CompletionTokens != 5 || record.TotalTokens != 12 {
+		t.Errorf("expected 7/5/12 tokens, got %d/%d/%d", record.PromptTokens, record.CompletionTokens, record.TotalTokens)
+	}
+}
+
+func TestGetUsage(t *testing.T) {
+	logger := zerolog.Nop()
+
+	tests := []struct {
+		name           string
+		query          string
+		expectedStatus int
+	}{
+		{
+			name:           "no filters",
+			query:          "",
+			expectedStatus: 200,
+		},
+		{
+			name:           "all filters",
+			query:          "?from=2026-01-01&to=2026-02-01T00:00:00Z&model_key=gpt-4&consumer_id=7b0d3f5e-2f6a-4c1e-9a53-0d7d6f1f2a10&limit=10&offset=20",
+			expectedStatus: 200,
+		},
+		{
+			name:           "invalid consumer id",
+			query:          "?consumer_id=not-a-uuid",
+			expectedStatus: 400,
+		},
+		{
+			name:           "invalid time range",
+			query:          "?from=yesterday",
+			expectedStatus: 400,
+		},
+		{
+			name:           "limit too large",
+			query:          "?limit=5000",
+			expectedStatus: 400,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := fiber.New()
+			svc := service.New(&logger, &MockStore{}, nil, app, nil)
+			app.Get("/api/v1/usage", svc.GetUsage)
+
+			resp, err := app.Test(httptest.NewRequest("GET", "/api/v1/usage"+tt.query, nil))
+			if err != nil {
+				t.Fatalf("failed to execute request: %v", err)
+			}
+
+			if resp.StatusCode != tt.expectedStatus {
+				t.Errorf("expected status %d, got %d", tt.expectedStatus, resp.StatusCode)
+			}
+		})
+	}
+}
+
+func TestAdminUsage(t *testing.T) {
+	const consumerID = "7b0d3f5e-2f6a-4c1e-9a53-0d7d6f1f2a10"
+	const apiKeyID = "3c9e6a1d-8b2f-4d7e-a5c4-1f0e9d8c7b6a"
+	filters := "?model_key=gpt-4&consumer_id=" + consumerID + "&api_key_id=" + apiKeyID
+
+	tests := []struct {
+		name   string
+		path   string
+		token  func(t *testing.T, store *MockStore) string
+		status int
+		// consumerID is the consumer the store was asked for, or empty for the key's own consumer
+		consumerID string
+	}{
+		{
+			name:       "admin reads any consumer's usage",
+			path:       "/api/v1/admin/usage" + filters,
+			token:      func(t *testing.T, _ *MockStore) string { return accessToken(t, types.RoleAdmin) },
+			status:     200,
+			consumerID: consumerID,
+		},
+		{
+			name:       "admin reads daily usage",
+			path:       "/api/v1/admin/usage/daily" + filters,
+			token:      func(t *testing.T, _ *MockStore) string { return accessToken(t, types.RoleAdmin) },
+			status:     200,
+			consumerID: consumerID,
+		},
+		{
+			name:   "consumer tokens cannot read everyone's usage",
+			path:   "/api/v1/admin/usage" + filters,
+			token:  func(t *testing.T, _ *MockStore) string { return accessToken(t, types.RoleConsumer) },
+			status: 403,
+		},
+		{
+			name:   "invalid api key id",
+			path:   "/api/v1/admin/usage?api_key_id=not-a-uuid",
+			token:  func(t *testing.T, _ *MockStore) string { return accessToken(t, types.RoleAdmin) },
+			status: 400,
+		},
+		{
+			name: "consumer keys only read their own usage",
+			path: "/api/v1/usage" + filters,
+			token: func(t *testing.T, store *MockStore) string {
+				return consumerKey(t, store, types.RateLimit{}, types.PermissionUsageRead)
+			},
+			status: 200,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &MockStore{}
+			app := routesApp(t, store)
+
+			req := httptest.NewRequest("GET", tt.path, nil)
+			req.Header.Set("Authorization", "Bearer "+tt.token(t, store))
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatalf("failed to execute request: %v", err)
+			}
+			if resp.StatusCode != tt.status {
+				t.Fatalf("expected status %d, got %d", tt.status, resp.StatusCode)
+			}
+			if tt.status != 200 {
+				return
+			}
+
+			if len(store.UsageFilters) != 1 {
+				t.Fatalf("expected usage to be read once, got %d", len(store.UsageFilters))
+			}
+			expected := tt.consumerID
+			for id := range store.Consumers {
+				expected = id
+			}
+			filter := store.UsageFilters[0]
+			if filter.ConsumerID != expected || filter.ApiKeyID != apiKeyID || filter.ModelKey != "gpt-4" {
+				t.Errorf("unexpected usage filter: %+v", filter)
+			}
+		})
+	}
+}
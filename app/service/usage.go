@@ -0,0 +1,197 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/wmbryce/agent-c/app/middleware"
+	"github.com/wmbryce/agent-c/app/types"
+	"github.com/wmbryce/agent-c/app/utils"
+)
+
+// defaultUsageLimit caps the number of usage records returned when no limit is given.
+const defaultUsageLimit = 100
+
+// GetUsage func returns individual usage records.
+// @Description List usage records, filtered by time range, model, consumer and seller api key.
+// @Description Consumer keys only see their own usage; admins see every consumer's.
+// @Summary list usage records
+// @Tags Usage
+// @Produce json
+// @Param from query string false "Start of range (RFC3339 or YYYY-MM-DD, inclusive)"
+// @Param to query string false "End of range (RFC3339 or YYYY-MM-DD, exclusive)"
+// @Param model_key query string false "Model key"
+// @Param consumer_id query string false "Consumer ID (overridden by the authenticated consumer)"
+// @Param api_key_id query string false "Seller api key ID"
+// @Param limit query int false "Maximum number of records (default 100, max 1000)"
+// @Param offset query int false "Number of records to skip"
+// @Success 200 {array} types.UsageRecord
+// @Security ApiKeyAuth
+// @Router /v1/usage [get]
+// @Router /v1/admin/usage [get]
+func (s *Service) GetUsage(c *fiber.Ctx) error {
+	query := &types.UsageQuery{}
+	if err := c.QueryParser(query); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": true,
+			"msg":   err.Error(),
+		})
+	}
+
+	validate := utils.NewValidator()
+	if err := validate.Struct(query); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": true,
+			"msg":   utils.ValidatorErrors(err),
+		})
+	}
+
+	filter, err := newUsageFilter(query, consumerID(c))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": true,
+			"msg":   err.Error(),
+		})
+	}
+
+	records, err := s.store.GetUsage(filter)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": true,
+			"msg":   err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"error": false,
+		"msg":   nil,
+		"usage": records,
+	})
+}
+
+// GetDailyUsage func returns usage aggregated per day, model and consumer.
+// @Description Aggregate usage per day, model and consumer, filtered by time range, model, consumer and seller api key.
+// @Description Consumer keys only see their own usage; admins see every consumer's.
+// @Summary daily usage aggregates
+// @Tags Usage
+// @Produce json
+// @Param from query string false "Start of range (RFC3339 or YYYY-MM-DD, inclusive)"
+// @Param to query string false "End of range (RFC3339 or YYYY-MM-DD, exclusive)"
+// @Param model_key query string false "Model key"
+// @Param consumer_id query string false "Consumer ID (overridden by the authenticated consumer)"
+// @Param api_key_id query string false "Seller api key ID"
+// @Success 200 {array} types.DailyUsage
+// @Security ApiKeyAuth
+// @Router /v1/usage/daily [get]
+// @Router /v1/admin/usage/daily [get]
+func (s *Service) GetDailyUsage(c *fiber.Ctx) error {
+	query := &types.UsageQuery{}
+	if err := c.QueryParser(query); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": true,
+			"msg":   err.Error(),
+		})
+	}
+
+	validate := utils.NewValidator()
+	if err := validate.Struct(query); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": true,
+			"msg":   utils.ValidatorErrors(err),
+		})
+	}
+
+	filter, err := newUsageFilter(query, consumerID(c))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": true,
+			"msg":   err.Error(),
+		})
+	}
+
+	days, err := s.store.GetDailyUsage(filter)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": true,
+			"msg":   err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"error": false,
+		"msg":   nil,
+		"daily": days,
+	})
+}
+
+// newUsageFilter converts a validated usage query into store filters. A consumer
+// authenticated with a consumer key only ever sees their own usage.
+func newUsageFilter(query *types.UsageQuery, consumer *string) (*types.UsageFilter, error) {
+	filter := &types.UsageFilter{
+		ModelKey:   query.ModelKey,
+		ConsumerID: query.ConsumerID,
+		ApiKeyID:   query.ApiKeyID,
+		Limit:      query.Limit,
+		Offset:     query.Offset,
+	}
+	if filter.Limit == 0 {
+		filter.Limit = defaultUsageLimit
+	}
+	if consumer != nil {
+		filter.ConsumerID = *consumer
+	}
+
+	var err error
+	if filter.From, err = parseUsageTime(query.From); err != nil {
+		return nil, fmt.Errorf("invalid from: %w", err)
+	}
+	if filter.To, err = parseUsageTime(query.To); err != nil {
+		return nil, fmt.Errorf("invalid to: %w", err)
+	}
+
+	return filter, nil
+}
+
+// parseUsageTime accepts either an RFC3339 timestamp or a plain YYYY-MM-DD date
+func parseUsageTime(value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return &t, nil
+	}
+	t, err := time.Parse(time.DateOnly, value)
+	if err != nil {
+		return nil, fmt.Errorf("expected RFC3339 timestamp or YYYY-MM-DD date")
+	}
+	return &t, nil
+}
+
+// consumerID returns the authenticated consumer for the request, if any
+func consumerID(c *fiber.Ctx) *string {
+	if id, ok := c.Locals(middleware.ConsumerIDLocalsKey).(string); ok && id != "" {
+		return &id
+	}
+	return nil
+}
+
+// recordUsage stores the usage record for a consume call. Failures are logged
+// rather than surfaced, since the provider has already served the request.
+func (s *Service) recordUsage(ctx context.Context, record *types.UsageRecord, started time.Time, response *types.GeneralChatResponse) {
+	record.LatencyMs = time.Since(started).Milliseconds()
+	if response != nil {
+		record.PromptTokens = response.PromptTokens
+		record.CompletionTokens = response.CompletionTokens
+		record.TotalTokens = response.TotalTokens
+		record.Cost = response.Cost
+	}
+
+	if err := s.store.RecordUsage(ctx, record); err != nil {
+		s.logger.Error().
+			Err(err).
+			Str("model_key", record.ModelKey).
+			Msg("failed to record usage")
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"crypto/ecdsa"
 	"fmt"
 	"math/big"
+	"net/http"
 	"os"
 
 	"github.com/ethereum/go-ethereum"
@@ -13,6 +14,7 @@ import (
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
 )
 
 // EthereumClient represents a connection to an Ethereum node
@@ -31,11 +33,14 @@ func NewEthereumClient() (*EthereumClient, error) {
 		return nil, fmt.Errorf("ETHEREUM_RPC_URL environment variable is not set")
 	}
 
-	// Connect to Ethereum node
-	client, err := ethclient.Dial(rpcURL)
+	// Connect to Ethereum node, timing and tracing each call made over HTTP
+	rpcClient, err := rpc.DialOptions(context.Background(), rpcURL, rpc.WithHTTPClient(&http.Client{
+		Transport: &rpcTransport{next: http.DefaultTransport},
+	}))
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to Ethereum node: %v", err)
 	}
+	client := ethclient.NewClient(rpcClient)
 
 	// Get chain ID
 	chainID, err := client.ChainID(context.Background())
@@ -0,0 +1,88 @@
+package blockchain
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/wmbryce/agent-c/app/utils"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var rpcDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+	Name:    "agc_ethereum_rpc_duration_seconds",
+	Help:    "Ethereum JSON-RPC call latency, by method and outcome (ok or error).",
+	Buckets: []float64{0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10},
+}, []string{"method", "outcome"})
+
+// rpcTransport times and traces JSON-RPC calls made over HTTP by the method they call,
+// including the calls contract bindings make through the client. Batches are labelled batch.
+type rpcTransport struct {
+	next http.RoundTripper
+}
+
+// RoundTrip sends a clone of req carrying the span's traceparent. Like any RoundTripper it
+// only consumes and closes the body of req, which the clone is given a copy of.
+func (t *rpcTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	method := "unknown"
+	if req.Body != nil && req.Body != http.NoBody {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		method = rpcMethod(body)
+	}
+
+	ctx, span := utils.Tracer().Start(req.Context(), "ethereum "+method, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(
+		semconv.RPCSystemKey.String("jsonrpc"),
+		semconv.RPCMethod(method),
+	))
+	defer span.End()
+
+	traced := req.Clone(ctx)
+	if body != nil {
+		traced.Body = io.NopCloser(bytes.NewReader(body))
+	}
+	utils.TraceContext.Inject(ctx, propagation.HeaderCarrier(traced.Header))
+
+	started := time.Now()
+	resp, err := t.next.RoundTrip(traced)
+
+	outcome := "ok"
+	if err != nil || resp.StatusCode != http.StatusOK {
+		outcome = "error"
+		span.SetStatus(codes.Error, "")
+	}
+	if err != nil {
+		span.RecordError(err)
+	}
+	rpcDuration.WithLabelValues(method, outcome).Observe(time.Since(started).Seconds())
+
+	return resp, err
+}
+
+// rpcMethod reads the method of a JSON-RPC request body
+func rpcMethod(body []byte) string {
+	body = bytes.TrimSpace(body)
+	if len(body) > 0 && body[0] == '[' {
+		return "batch"
+	}
+
+	var call struct {
+		Method string `json:"method"`
+	}
+	if err := json.Unmarshal(body, &call); err != nil || call.Method == "" {
+		return "unknown"
+	}
+	return call.Method
+}
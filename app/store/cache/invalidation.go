@@ -0,0 +1,54 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// credentialsChannel carries invalidations of the model credentials every instance caches
+const credentialsChannel = "agc:credentials:invalidate"
+
+// PublishCredentialsInvalidation tells every instance to drop its cached model credentials.
+func (s *Store) PublishCredentialsInvalidation() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := s.client.Publish(ctx, credentialsChannel, "").Err(); err != nil {
+		return fmt.Errorf("failed to publish credentials invalidation: %w", err)
+	}
+	return nil
+}
+
+// SubscribeCredentialsInvalidation calls invalidate for every credentials invalidation
+// published by any instance until ctx is done. It returns once the subscription is active.
+// Invalidations published while Redis is unreachable are lost, so cached credentials must
+// also expire on their own.
+func (s *Store) SubscribeCredentialsInvalidation(ctx context.Context, invalidate func()) error {
+	pubsub := s.client.Subscribe(ctx, credentialsChannel)
+
+	receiveCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	if _, err := pubsub.Receive(receiveCtx); err != nil {
+		pubsub.Close()
+		return fmt.Errorf("failed to subscribe to credentials invalidations: %w", err)
+	}
+
+	go func() {
+		defer pubsub.Close()
+
+		messages := pubsub.Channel()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case _, ok := <-messages:
+				if !ok {
+					return
+				}
+				invalidate()
+			}
+		}
+	}()
+	return nil
+}
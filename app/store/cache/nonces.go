@@ -0,0 +1,34 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// nonceKeyPrefix namespaces sign-in nonces
+const nonceKeyPrefix = "agc:siwe:nonce:"
+
+// SaveNonce stores a sign-in nonce until it is used or expires.
+func (s *Store) SaveNonce(nonce string, ttl time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := s.client.Set(ctx, nonceKeyPrefix+nonce, 1, ttl).Err(); err != nil {
+		return fmt.Errorf("failed to save nonce: %w", err)
+	}
+	return nil
+}
+
+// ConsumeNonce deletes a sign-in nonce and reports whether it was still valid,
+// so each nonce signs in at most once.
+func (s *Store) ConsumeNonce(nonce string) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	deleted, err := s.client.Del(ctx, nonceKeyPrefix+nonce).Result()
+	if err != nil {
+		return false, fmt.Errorf("failed to consume nonce: %w", err)
+	}
+	return deleted == 1, nil
+}
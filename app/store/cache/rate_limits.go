@@ -0,0 +1,90 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/wmbryce/agent-c/app/types"
+)
+
+// rateLimitKeyPrefix namespaces rate limit counters, one per scope, id, unit and window
+const rateLimitKeyPrefix = "agc:ratelimit:"
+
+// rateLimitWindow is the window rate limits are counted over
+const rateLimitWindow = time.Minute
+
+// allowRateScript approximates a sliding window from the counts of the current and previous
+// fixed windows, weighting the previous one by how much of it still overlaps the sliding window.
+// It counts add units when need more units fit within the limit, and returns whether they did
+// along with the units used.
+var allowRateScript = redis.NewScript(`
+local current = tonumber(redis.call('GET', KEYS[1]) or '0')
+local previous = tonumber(redis.call('GET', KEYS[2]) or '0')
+local limit = tonumber(ARGV[1])
+local need = tonumber(ARGV[2])
+local add = tonumber(ARGV[3])
+local used = math.ceil(previous * tonumber(ARGV[4])) + current
+
+if used + need > limit then
+	return {0, used}
+end
+
+if add > 0 then
+	redis.call('INCRBY', KEYS[1], add)
+	redis.call('PEXPIRE', KEYS[1], ARGV[5])
+end
+return {1, used + add}
+`)
+
+// AllowRate checks a per-minute limit of a consumer, model or seller key. It is allowed when
+// need more units fit within limit, and add units are then counted against it.
+func (s *Store) AllowRate(scope, id, unit string, limit, need, add int) (*types.RateLimitStatus, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	current, previous, elapsed := rateLimitKeys(scope, id, unit, time.Now())
+	weight := 1 - float64(elapsed)/float64(rateLimitWindow)
+
+	result, err := allowRateScript.Run(ctx, s.client, []string{current, previous},
+		limit, need, add, strconv.FormatFloat(weight, 'f', 6, 64), (2 * rateLimitWindow).Milliseconds(),
+	).Int64Slice()
+	if err != nil {
+		return nil, fmt.Errorf("failed to check rate limit: %w", err)
+	}
+
+	return &types.RateLimitStatus{
+		Allowed:   result[0] == 1,
+		Limit:     limit,
+		Remaining: max(limit-int(result[1]), 0),
+		Reset:     rateLimitWindow - elapsed,
+	}, nil
+}
+
+// AddRateUsage counts units against a per-minute limit after the fact, such as the tokens
+// a call used once it completes.
+func (s *Store) AddRateUsage(scope, id, unit string, amount int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	current, _, _ := rateLimitKeys(scope, id, unit, time.Now())
+	pipe := s.client.TxPipeline()
+	pipe.IncrBy(ctx, current, int64(amount))
+	pipe.PExpire(ctx, current, 2*rateLimitWindow)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to count rate limit usage: %w", err)
+	}
+	return nil
+}
+
+// rateLimitKeys returns the counters of the current and previous windows, and how far into
+// the current window now is
+func rateLimitKeys(scope, id, unit string, now time.Time) (string, string, time.Duration) {
+	window := now.UnixMilli() / rateLimitWindow.Milliseconds()
+	elapsed := time.Duration(now.UnixMilli()%rateLimitWindow.Milliseconds()) * time.Millisecond
+
+	prefix := rateLimitKeyPrefix + scope + ":" + id + ":" + unit + ":"
+	return prefix + strconv.FormatInt(window, 10), prefix + strconv.FormatInt(window-1, 10), elapsed
+}
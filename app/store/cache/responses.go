@@ -0,0 +1,50 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/wmbryce/agent-c/app/types"
+)
+
+// responseKeyPrefix namespaces cached model responses
+const responseKeyPrefix = "agc:response:"
+
+// GetResponse returns the cached response for a request hash, or nil when there is none.
+func (s *Store) GetResponse(key string) (*types.GeneralChatResponse, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	data, err := s.client.Get(ctx, responseKeyPrefix+key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get cached response: %w", err)
+	}
+
+	response := &types.GeneralChatResponse{}
+	if err := json.Unmarshal(data, response); err != nil {
+		return nil, fmt.Errorf("failed to decode cached response: %w", err)
+	}
+	return response, nil
+}
+
+// SaveResponse caches a response under a request hash until the ttl expires.
+func (s *Store) SaveResponse(key string, response *types.GeneralChatResponse, ttl time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	data, err := json.Marshal(response)
+	if err != nil {
+		return fmt.Errorf("failed to encode response: %w", err)
+	}
+	if err := s.client.Set(ctx, responseKeyPrefix+key, data, ttl).Err(); err != nil {
+		return fmt.Errorf("failed to cache response: %w", err)
+	}
+	return nil
+}
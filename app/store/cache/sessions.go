@@ -0,0 +1,237 @@
+package cache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"github.com/wmbryce/agent-c/app/types"
+)
+
+// Key prefixes for refresh token sessions. Refresh tokens are only ever stored hashed.
+const (
+	sessionKeyPrefix     = "agc:session:"
+	walletSessionsPrefix = "agc:sessions:"
+	refreshKeyPrefix     = "agc:refresh:"
+	usedRefreshKeyPrefix = "agc:refresh:used:"
+)
+
+var (
+	// ErrRefreshTokenInvalid is returned for unknown, expired or revoked refresh tokens
+	ErrRefreshTokenInvalid = errors.New("invalid refresh token")
+	// ErrRefreshTokenReused is returned when a rotated refresh token is presented again.
+	// The session it belonged to has been revoked.
+	ErrRefreshTokenReused = errors.New("refresh token reuse detected")
+	// ErrSessionNotFound is returned for sessions that do not exist or belong to another wallet
+	ErrSessionNotFound = errors.New("session not found")
+)
+
+// sessionRecord is a session as stored, along with the hash of its current refresh token
+type sessionRecord struct {
+	types.Session
+	TokenHash string `json:"token_hash"`
+}
+
+func hashRefreshToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func walletSessionsKey(wallet string) string {
+	return walletSessionsPrefix + strings.ToLower(wallet)
+}
+
+// CreateSession starts a session for a newly issued refresh token.
+func (s *Store) CreateSession(session *types.Session, refreshToken string, ttl time.Duration) (*types.Session, error) {
+	if ttl <= 0 {
+		return nil, errors.New("failed to create session: refresh token has already expired")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	now := time.Now()
+	record := sessionRecord{Session: *session, TokenHash: hashRefreshToken(refreshToken)}
+	record.ID = uuid.New().String()
+	record.CreatedAt = now
+	record.LastUsedAt = now
+	record.ExpiresAt = now.Add(ttl)
+
+	if err := s.saveSession(ctx, &record, ttl); err != nil {
+		return nil, fmt.Errorf("failed to create session: %w", err)
+	}
+	return &record.Session, nil
+}
+
+// RotateRefreshToken exchanges a refresh token for a new one in the same session. Each token
+// works once: presenting a rotated token again revokes the whole session.
+func (s *Store) RotateRefreshToken(refreshToken, newRefreshToken string, ttl time.Duration) (*types.Session, error) {
+	if ttl <= 0 {
+		return nil, errors.New("failed to rotate refresh token: new token has already expired")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	tokenHash := hashRefreshToken(refreshToken)
+
+	// Taking the token deletes it, so concurrent refreshes cannot both succeed
+	sessionID, err := s.client.GetDel(ctx, refreshKeyPrefix+tokenHash).Result()
+	if errors.Is(err, redis.Nil) {
+		reusedID, err := s.client.Get(ctx, usedRefreshKeyPrefix+tokenHash).Result()
+		if errors.Is(err, redis.Nil) {
+			return nil, ErrRefreshTokenInvalid
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to rotate refresh token: %w", err)
+		}
+		if err := s.revokeSession(ctx, reusedID, ""); err != nil && !errors.Is(err, ErrSessionNotFound) {
+			return nil, err
+		}
+		return nil, ErrRefreshTokenReused
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to rotate refresh token: %w", err)
+	}
+
+	record, err := s.getSession(ctx, sessionID)
+	if errors.Is(err, ErrSessionNotFound) {
+		return nil, ErrRefreshTokenInvalid
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Remember the rotated token until it would have expired, to detect its reuse
+	if err := s.client.Set(ctx, usedRefreshKeyPrefix+tokenHash, sessionID, time.Until(record.ExpiresAt)).Err(); err != nil {
+		return nil, fmt.Errorf("failed to rotate refresh token: %w", err)
+	}
+
+	now := time.Now()
+	record.TokenHash = hashRefreshToken(newRefreshToken)
+	record.LastUsedAt = now
+	record.ExpiresAt = now.Add(ttl)
+	if err := s.saveSession(ctx, record, ttl); err != nil {
+		return nil, fmt.Errorf("failed to rotate refresh token: %w", err)
+	}
+
+	return &record.Session, nil
+}
+
+// RevokeSessionByToken ends the session a refresh token belongs to.
+func (s *Store) RevokeSessionByToken(refreshToken string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	sessionID, err := s.client.Get(ctx, refreshKeyPrefix+hashRefreshToken(refreshToken)).Result()
+	if errors.Is(err, redis.Nil) {
+		return ErrRefreshTokenInvalid
+	}
+	if err != nil {
+		return fmt.Errorf("failed to revoke session: %w", err)
+	}
+
+	return s.revokeSession(ctx, sessionID, "")
+}
+
+// RevokeSession ends one of a wallet's sessions.
+func (s *Store) RevokeSession(wallet, id string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	return s.revokeSession(ctx, id, wallet)
+}
+
+// GetSessions returns a wallet's active sessions, most recently used first.
+func (s *Store) GetSessions(wallet string) ([]types.Session, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	ids, err := s.client.SMembers(ctx, walletSessionsKey(wallet)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get sessions: %w", err)
+	}
+
+	sessions := []types.Session{}
+	for _, id := range ids {
+		record, err := s.getSession(ctx, id)
+		if errors.Is(err, ErrSessionNotFound) {
+			// The session expired, drop it from the index
+			s.client.SRem(ctx, walletSessionsKey(wallet), id)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, record.Session)
+	}
+
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].LastUsedAt.After(sessions[j].LastUsedAt)
+	})
+	return sessions, nil
+}
+
+func (s *Store) getSession(ctx context.Context, id string) (*sessionRecord, error) {
+	raw, err := s.client.Get(ctx, sessionKeyPrefix+id).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrSessionNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get session: %w", err)
+	}
+
+	var record sessionRecord
+	if err := json.Unmarshal(raw, &record); err != nil {
+		return nil, fmt.Errorf("failed to decode session: %w", err)
+	}
+	return &record, nil
+}
+
+// saveSession stores the session and its current refresh token, both expiring with the token
+func (s *Store) saveSession(ctx context.Context, record *sessionRecord, ttl time.Duration) error {
+	raw, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	walletKey := walletSessionsKey(record.Wallet)
+	_, err = s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, sessionKeyPrefix+record.ID, raw, ttl)
+		pipe.Set(ctx, refreshKeyPrefix+record.TokenHash, record.ID, ttl)
+		pipe.SAdd(ctx, walletKey, record.ID)
+		pipe.Expire(ctx, walletKey, ttl)
+		return nil
+	})
+	return err
+}
+
+// revokeSession deletes a session and its current refresh token. When wallet is set the
+// session must belong to it.
+func (s *Store) revokeSession(ctx context.Context, id, wallet string) error {
+	record, err := s.getSession(ctx, id)
+	if err != nil {
+		return err
+	}
+	if wallet != "" && !strings.EqualFold(record.Wallet, wallet) {
+		return ErrSessionNotFound
+	}
+
+	_, err = s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(ctx, sessionKeyPrefix+id, refreshKeyPrefix+record.TokenHash)
+		pipe.SRem(ctx, walletSessionsKey(record.Wallet), id)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to revoke session: %w", err)
+	}
+	return nil
+}
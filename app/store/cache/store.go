@@ -0,0 +1,20 @@
+package cache
+
+import (
+	"github.com/redis/go-redis/v9"
+)
+
+// Store keeps short-lived state, such as sign-in nonces, sessions and cached responses, in Redis.
+type Store struct {
+	client *redis.Client
+}
+
+// New wraps a Redis client.
+func New(client *redis.Client) *Store {
+	return &Store{client: client}
+}
+
+// Close closes the Redis client.
+func (s *Store) Close() error {
+	return s.client.Close()
+}
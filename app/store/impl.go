@@ -2,18 +2,100 @@ package store
 
 import (
 	"context"
+	"time"
 
+	"github.com/wmbryce/agent-c/app/store/cache"
 	"github.com/wmbryce/agent-c/app/store/postgres"
 	"github.com/wmbryce/agent-c/app/types"
 )
 
+var (
+	ErrInsufficientTokens = postgres.ErrInsufficientTokens
+	ErrReservationClosed  = postgres.ErrReservationClosed
+	ErrNotFound           = postgres.ErrNotFound
+	ErrDuplicate          = postgres.ErrDuplicate
+	ErrInvalidReference   = postgres.ErrInvalidReference
+	ErrInUse              = postgres.ErrInUse
+
+	ErrRefreshTokenInvalid = cache.ErrRefreshTokenInvalid
+	ErrRefreshTokenReused  = cache.ErrRefreshTokenReused
+	ErrSessionNotFound     = cache.ErrSessionNotFound
+)
+
 type SqlStore interface {
 	CreateModel(model *types.Model) (*types.Model, error)
 	GetModels() ([]types.Model, error)
-	GetModelCredentials(modelKey string) (*types.ModelCredentials, error)
+	UpdateModel(model *types.Model) (*types.Model, error)
+	DeleteModel(id string) error
+	GetModelCredentials(ctx context.Context, modelKey string) ([]types.ModelCredentials, error)
+	CreateProvider(provider *types.Provider) (*types.Provider, error)
+	GetProviders() ([]types.Provider, error)
+	GetProvider(id string) (*types.Provider, error)
+	UpdateProvider(provider *types.Provider) (*types.Provider, error)
+	DeleteProvider(id string) error
+	CreateModelSchema(schema *types.ModelSchema) (*types.ModelSchema, error)
+	GetModelSchemas() ([]types.ModelSchema, error)
+	GetModelSchema(id string) (*types.ModelSchema, error)
+	UpdateModelSchema(schema *types.ModelSchema) (*types.ModelSchema, error)
+	DeleteModelSchema(id string) error
+	CreateSeller(seller *types.Seller) (*types.Seller, error)
+	GetSellers() ([]types.Seller, error)
+	GetSeller(id string) (*types.Seller, error)
+	UpdateSeller(seller *types.Seller) (*types.Seller, error)
+	DeleteSeller(id string) error
+	CreateApiKey(key *types.ApiKey) (*types.ApiKey, error)
+	GetApiKeys() ([]types.ApiKey, error)
+	GetApiKey(id string) (*types.ApiKey, error)
+	UpdateApiKey(key *types.ApiKey) (*types.ApiKey, error)
+	DeleteApiKey(id string) error
+	ReencryptApiKeys(reseal func(key *types.StoredApiKey) (bool, error)) (int, error)
+	CreateConsumer(consumer *types.Consumer) (*types.Consumer, error)
+	GetConsumers() ([]types.Consumer, error)
+	GetConsumer(id string) (*types.Consumer, error)
+	UpdateConsumer(consumer *types.Consumer) (*types.Consumer, error)
+	DeleteConsumer(id string) error
+	GetSellerByWallet(wallet string) (*types.Seller, error)
+	GetConsumerByWallet(wallet string) (*types.Consumer, error)
+	CreateConsumerKey(key *types.ConsumerKey) (*types.ConsumerKey, error)
+	GetConsumerKeys(consumerID string) ([]types.ConsumerKey, error)
+	GetConsumerKeyByPrefix(ctx context.Context, prefix string) (*types.ConsumerKey, *types.Consumer, error)
+	RevokeConsumerKey(consumerID, id string) (*types.ConsumerKey, error)
+	CreateModelPrice(modelID string, price *types.ModelPrice) (*types.ModelPrice, error)
+	ReserveTokens(ctx context.Context, apiKeyID string, modelKey string, amount int, ttl time.Duration) (*types.TokenReservation, error)
+	SettleTokens(ctx context.Context, reservationID string, actual int) (*types.TokenReservation, error)
+	ReleaseTokens(ctx context.Context, reservationID string) error
+	ReleaseExpiredReservations(ctx context.Context) (int, error)
+	RecordUsage(ctx context.Context, record *types.UsageRecord) error
+	GetUsage(filter *types.UsageFilter) ([]types.UsageRecord, error)
+	GetDailyUsage(filter *types.UsageFilter) ([]types.DailyUsage, error)
 	Close()
 }
 
 func NewSqlStore(ctx context.Context) SqlStore {
 	return postgres.New(ctx)
 }
+
+type CacheStore interface {
+	SaveNonce(nonce string, ttl time.Duration) error
+	ConsumeNonce(nonce string) (bool, error)
+	CreateSession(session *types.Session, refreshToken string, ttl time.Duration) (*types.Session, error)
+	RotateRefreshToken(refreshToken, newRefreshToken string, ttl time.Duration) (*types.Session, error)
+	RevokeSessionByToken(refreshToken string) error
+	RevokeSession(wallet, id string) error
+	GetSessions(wallet string) ([]types.Session, error)
+	GetResponse(key string) (*types.GeneralChatResponse, error)
+	SaveResponse(key string, response *types.GeneralChatResponse, ttl time.Duration) error
+	AllowRate(scope, id, unit string, limit, need, add int) (*types.RateLimitStatus, error)
+	AddRateUsage(scope, id, unit string, amount int) error
+	PublishCredentialsInvalidation() error
+	SubscribeCredentialsInvalidation(ctx context.Context, invalidate func()) error
+	Close() error
+}
+
+func NewCacheStore() (CacheStore, error) {
+	client, err := cache.RedisConnection()
+	if err != nil {
+		return nil, err
+	}
+	return cache.New(client), nil
+}
@@ -0,0 +1,262 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/wmbryce/agent-c/app/types"
+)
+
+// apiKeyColumns lists the columns scanned by scanApiKey. The key itself is never read back,
+// only its fingerprint and last four characters.
+const apiKeyColumns = `id, COALESCE(key_fingerprint, ''), COALESCE(key_last4, ''), tokens_available,
+	provider_id, seller_id, requests_per_minute, tokens_per_minute, created_at, updated_at`
+
+func scanApiKey(row pgx.Row) (*types.ApiKey, error) {
+	var key types.ApiKey
+	err := row.Scan(
+		&key.ID,
+		&key.Fingerprint,
+		&key.Last4,
+		&key.TokensAvailable,
+		&key.ProviderID,
+		&key.SellerID,
+		&key.RequestsPerMinute,
+		&key.TokensPerMinute,
+		&key.CreatedAt,
+		&key.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+// sealedKeyArgs returns the stored columns of an encrypted key, all nil when there is none
+func sealedKeyArgs(sealed *types.EncryptedKey) []any {
+	if sealed == nil {
+		return []any{nil, nil, nil}
+	}
+	return []any{sealed.Ciphertext, sealed.WrappedKey, sealed.MasterKeyID}
+}
+
+// CreateApiKey adds a seller's encrypted provider api key. Its starting balance is recorded in the ledger.
+func (s *Store) CreateApiKey(key *types.ApiKey) (*types.ApiKey, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	created, err := scanApiKey(tx.QueryRow(ctx, `
+		INSERT INTO agc.api_keys (
+			key_ciphertext, key_wrapped, key_master_id, key_fingerprint, key_last4,
+			tokens_available, provider_id, seller_id, requests_per_minute, tokens_per_minute
+		)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		RETURNING `+apiKeyColumns,
+		append(sealedKeyArgs(key.Sealed), key.Fingerprint, key.Last4, key.TokensAvailable, key.ProviderID, key.SellerID,
+			key.RequestsPerMinute, key.TokensPerMinute)...,
+	))
+	if err != nil {
+		return nil, writeError("create api key", err)
+	}
+
+	if created.TokensAvailable > 0 {
+		err := insertLedgerEntry(ctx, tx, created.ID, nil, "adjust", created.TokensAvailable, created.TokensAvailable)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit api key: %w", err)
+	}
+
+	return created, nil
+}
+
+// GetApiKeys returns every api key, newest first.
+func (s *Store) GetApiKeys() ([]types.ApiKey, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	rows, err := s.db.Query(ctx, `SELECT `+apiKeyColumns+` FROM agc.api_keys ORDER BY created_at DESC`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query api keys: %w", err)
+	}
+	defer rows.Close()
+
+	keys := []types.ApiKey{}
+	for rows.Next() {
+		key, err := scanApiKey(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan api key: %w", err)
+		}
+		keys = append(keys, *key)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating api keys: %w", err)
+	}
+
+	return keys, nil
+}
+
+// GetApiKey returns an api key by id.
+func (s *Store) GetApiKey(id string) (*types.ApiKey, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	key, err := scanApiKey(s.db.QueryRow(ctx, `SELECT `+apiKeyColumns+` FROM agc.api_keys WHERE id = $1`, id))
+	if err != nil {
+		return nil, writeError("get api key", err)
+	}
+
+	return key, nil
+}
+
+// UpdateApiKey replaces an api key's fields, keeping the stored key when no new sealed key is given.
+// A changed balance is recorded in the ledger as an adjustment.
+func (s *Store) UpdateApiKey(key *types.ApiKey) (*types.ApiKey, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	// Lock the key so in-flight reservations cannot race the adjustment
+	var previous int
+	err = tx.QueryRow(ctx, `SELECT tokens_available FROM agc.api_keys WHERE id = $1 FOR UPDATE`, key.ID).Scan(&previous)
+	if err != nil {
+		return nil, writeError("update api key", err)
+	}
+
+	updated, err := scanApiKey(tx.QueryRow(ctx, `
+		UPDATE agc.api_keys SET
+			key_ciphertext = COALESCE($2, key_ciphertext), key_wrapped = COALESCE($3, key_wrapped),
+			key_master_id = COALESCE($4, key_master_id), key_fingerprint = COALESCE(NULLIF($5, ''), key_fingerprint),
+			key_last4 = COALESCE(NULLIF($6, ''), key_last4),
+			api_key = CASE WHEN $2::bytea IS NULL THEN api_key END,
+			tokens_available = $7, provider_id = $8, seller_id = $9, requests_per_minute = $10,
+			tokens_per_minute = $11, updated_at = NOW()
+		WHERE id = $1
+		RETURNING `+apiKeyColumns,
+		append(append([]any{key.ID}, sealedKeyArgs(key.Sealed)...),
+			key.Fingerprint, key.Last4, key.TokensAvailable, key.ProviderID, key.SellerID,
+			key.RequestsPerMinute, key.TokensPerMinute)...,
+	))
+	if err != nil {
+		return nil, writeError("update api key", err)
+	}
+
+	if delta := updated.TokensAvailable - previous; delta != 0 {
+		if err := insertLedgerEntry(ctx, tx, updated.ID, nil, "adjust", delta, updated.TokensAvailable); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit api key: %w", err)
+	}
+
+	return updated, nil
+}
+
+// DeleteApiKey removes an api key.
+func (s *Store) DeleteApiKey(id string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	tag, err := s.db.Exec(ctx, `DELETE FROM agc.api_keys WHERE id = $1`, id)
+	if err != nil {
+		return writeError("delete api key", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("failed to delete api key: %w", ErrNotFound)
+	}
+
+	return nil
+}
+
+// ReencryptApiKeys passes every stored api key to reseal inside one transaction and saves
+// the keys it changed, dropping any plaintext. It returns the number of keys saved.
+func (s *Store) ReencryptApiKeys(reseal func(key *types.StoredApiKey) (bool, error)) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	rows, err := tx.Query(ctx, `
+		SELECT id, api_key, key_ciphertext, key_wrapped, COALESCE(key_master_id, ''),
+		       COALESCE(key_fingerprint, ''), COALESCE(key_last4, '')
+		FROM agc.api_keys
+		ORDER BY id
+		FOR UPDATE
+	`)
+	if err != nil {
+		return 0, fmt.Errorf("failed to query api keys: %w", err)
+	}
+
+	keys := []types.StoredApiKey{}
+	for rows.Next() {
+		var key types.StoredApiKey
+		var sealed types.EncryptedKey
+		err := rows.Scan(&key.ID, &key.Plaintext, &sealed.Ciphertext, &sealed.WrappedKey, &sealed.MasterKeyID,
+			&key.Fingerprint, &key.Last4)
+		if err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan api key: %w", err)
+		}
+		if sealed.Ciphertext != nil {
+			key.Sealed = &sealed
+		}
+		keys = append(keys, key)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("error iterating api keys: %w", err)
+	}
+
+	saved := 0
+	for i := range keys {
+		key := &keys[i]
+		changed, err := reseal(key)
+		if err != nil {
+			return 0, fmt.Errorf("failed to re-encrypt api key %s: %w", key.ID, err)
+		}
+		if !changed {
+			continue
+		}
+
+		_, err = tx.Exec(ctx, `
+			UPDATE agc.api_keys SET
+				api_key = NULL, key_ciphertext = $2, key_wrapped = $3, key_master_id = $4,
+				key_fingerprint = $5, key_last4 = $6, updated_at = NOW()
+			WHERE id = $1`,
+			append(append([]any{key.ID}, sealedKeyArgs(key.Sealed)...), key.Fingerprint, key.Last4)...,
+		)
+		if err != nil {
+			return 0, writeError("re-encrypt api key", err)
+		}
+		saved++
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("failed to commit api keys: %w", err)
+	}
+
+	return saved, nil
+}
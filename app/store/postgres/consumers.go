@@ -0,0 +1,266 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/wmbryce/agent-c/app/types"
+)
+
+// consumerColumns lists the columns scanned by scanConsumer
+const consumerColumns = `id, wallet_address, requests_per_minute, tokens_per_minute, created_at, updated_at`
+
+func scanConsumer(row pgx.Row) (*types.Consumer, error) {
+	var consumer types.Consumer
+	err := row.Scan(
+		&consumer.ID,
+		&consumer.WalletAddress,
+		&consumer.RequestsPerMinute,
+		&consumer.TokensPerMinute,
+		&consumer.CreatedAt,
+		&consumer.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &consumer, nil
+}
+
+// CreateConsumer adds a consumer wallet.
+func (s *Store) CreateConsumer(consumer *types.Consumer) (*types.Consumer, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	created, err := scanConsumer(s.db.QueryRow(ctx, `
+		INSERT INTO agc.consumers (wallet_address, requests_per_minute, tokens_per_minute)
+		VALUES ($1, $2, $3)
+		RETURNING `+consumerColumns,
+		consumer.WalletAddress, consumer.RequestsPerMinute, consumer.TokensPerMinute,
+	))
+	if err != nil {
+		return nil, writeError("create consumer", err)
+	}
+
+	return created, nil
+}
+
+// GetConsumers returns every consumer, newest first.
+func (s *Store) GetConsumers() ([]types.Consumer, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	rows, err := s.db.Query(ctx, `SELECT `+consumerColumns+` FROM agc.consumers ORDER BY created_at DESC`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query consumers: %w", err)
+	}
+	defer rows.Close()
+
+	consumers := []types.Consumer{}
+	for rows.Next() {
+		consumer, err := scanConsumer(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan consumer: %w", err)
+		}
+		consumers = append(consumers, *consumer)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating consumers: %w", err)
+	}
+
+	return consumers, nil
+}
+
+// GetConsumer returns a consumer by id.
+func (s *Store) GetConsumer(id string) (*types.Consumer, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	consumer, err := scanConsumer(s.db.QueryRow(ctx, `SELECT `+consumerColumns+` FROM agc.consumers WHERE id = $1`, id))
+	if err != nil {
+		return nil, writeError("get consumer", err)
+	}
+
+	return consumer, nil
+}
+
+// UpdateConsumer changes a consumer's wallet address and rate limits.
+func (s *Store) UpdateConsumer(consumer *types.Consumer) (*types.Consumer, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	updated, err := scanConsumer(s.db.QueryRow(ctx, `
+		UPDATE agc.consumers SET
+			wallet_address = $2, requests_per_minute = $3, tokens_per_minute = $4, updated_at = NOW()
+		WHERE id = $1
+		RETURNING `+consumerColumns,
+		consumer.ID, consumer.WalletAddress, consumer.RequestsPerMinute, consumer.TokensPerMinute,
+	))
+	if err != nil {
+		return nil, writeError("update consumer", err)
+	}
+
+	return updated, nil
+}
+
+// DeleteConsumer removes a consumer along with its keys.
+func (s *Store) DeleteConsumer(id string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	tag, err := s.db.Exec(ctx, `DELETE FROM agc.consumers WHERE id = $1`, id)
+	if err != nil {
+		return writeError("delete consumer", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("failed to delete consumer: %w", ErrNotFound)
+	}
+
+	return nil
+}
+
+// GetConsumerByWallet returns the consumer with a wallet address, compared case-insensitively.
+func (s *Store) GetConsumerByWallet(wallet string) (*types.Consumer, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	consumer, err := scanConsumer(s.db.QueryRow(ctx, `
+		SELECT `+consumerColumns+`
+		FROM agc.consumers
+		WHERE LOWER(wallet_address) = LOWER($1)
+	`, wallet))
+	if err != nil {
+		return nil, writeError("get consumer", err)
+	}
+
+	return consumer, nil
+}
+
+// consumerKeyColumns lists the columns scanned by scanConsumerKey
+const consumerKeyColumns = `id, consumer_id, name, prefix, key_hash, scopes, revoked_at, created_at, updated_at`
+
+func scanConsumerKey(row pgx.Row) (*types.ConsumerKey, error) {
+	var key types.ConsumerKey
+	err := row.Scan(
+		&key.ID,
+		&key.ConsumerID,
+		&key.Name,
+		&key.Prefix,
+		&key.KeyHash,
+		&key.Scopes,
+		&key.RevokedAt,
+		&key.CreatedAt,
+		&key.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+// CreateConsumerKey stores a consumer api key by its prefix and secret hash.
+func (s *Store) CreateConsumerKey(key *types.ConsumerKey) (*types.ConsumerKey, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	created, err := scanConsumerKey(s.db.QueryRow(ctx, `
+		INSERT INTO agc.consumer_keys (consumer_id, name, prefix, key_hash, scopes)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING `+consumerKeyColumns,
+		key.ConsumerID, key.Name, key.Prefix, key.KeyHash, key.Scopes,
+	))
+	if err != nil {
+		return nil, writeError("create consumer key", err)
+	}
+
+	return created, nil
+}
+
+// GetConsumerKeys returns every key issued to a consumer, revoked ones included, newest first.
+func (s *Store) GetConsumerKeys(consumerID string) ([]types.ConsumerKey, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	rows, err := s.db.Query(ctx, `
+		SELECT `+consumerKeyColumns+`
+		FROM agc.consumer_keys
+		WHERE consumer_id = $1
+		ORDER BY created_at DESC
+	`, consumerID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query consumer keys: %w", err)
+	}
+	defer rows.Close()
+
+	keys := []types.ConsumerKey{}
+	for rows.Next() {
+		key, err := scanConsumerKey(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan consumer key: %w", err)
+		}
+		keys = append(keys, *key)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating consumer keys: %w", err)
+	}
+
+	return keys, nil
+}
+
+// GetConsumerKeyByPrefix returns an active consumer key by its prefix along with its consumer.
+func (s *Store) GetConsumerKeyByPrefix(ctx context.Context, prefix string) (*types.ConsumerKey, *types.Consumer, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var key types.ConsumerKey
+	var consumer types.Consumer
+	err := s.db.QueryRow(ctx, `
+		SELECT k.id, k.consumer_id, k.name, k.prefix, k.key_hash, k.scopes, k.revoked_at, k.created_at, k.updated_at,
+		       c.id, c.wallet_address, c.requests_per_minute, c.tokens_per_minute, c.created_at, c.updated_at
+		FROM agc.consumer_keys k
+		JOIN agc.consumers c ON c.id = k.consumer_id
+		WHERE k.prefix = $1 AND k.revoked_at IS NULL
+	`, prefix).Scan(
+		&key.ID,
+		&key.ConsumerID,
+		&key.Name,
+		&key.Prefix,
+		&key.KeyHash,
+		&key.Scopes,
+		&key.RevokedAt,
+		&key.CreatedAt,
+		&key.UpdatedAt,
+		&consumer.ID,
+		&consumer.WalletAddress,
+		&consumer.RequestsPerMinute,
+		&consumer.TokensPerMinute,
+		&consumer.CreatedAt,
+		&consumer.UpdatedAt,
+	)
+	if err != nil {
+		return nil, nil, writeError("get consumer key", err)
+	}
+
+	return &key, &consumer, nil
+}
+
+// RevokeConsumerKey revokes one of a consumer's keys. Revoking a revoked key is a no-op.
+func (s *Store) RevokeConsumerKey(consumerID, id string) (*types.ConsumerKey, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	revoked, err := scanConsumerKey(s.db.QueryRow(ctx, `
+		UPDATE agc.consumer_keys SET revoked_at = COALESCE(revoked_at, NOW()), updated_at = NOW()
+		WHERE id = $1 AND consumer_id = $2
+		RETURNING `+consumerKeyColumns,
+		id, consumerID,
+	))
+	if err != nil {
+		return nil, writeError("revoke consumer key", err)
+	}
+
+	return revoked, nil
+}
@@ -0,0 +1,45 @@
+package postgres
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+var (
+	// ErrNotFound is returned when the requested row does not exist.
+	ErrNotFound = errors.New("record not found")
+	// ErrDuplicate is returned when a row would repeat a unique value.
+	ErrDuplicate = errors.New("record already exists")
+	// ErrInvalidReference is returned when a row references a row that does not exist.
+	ErrInvalidReference = errors.New("referenced record does not exist")
+	// ErrInUse is returned when a row cannot be deleted while other rows reference it.
+	ErrInUse = errors.New("record is still in use")
+)
+
+// writeError wraps a failed admin query, mapping missing rows and constraint
+// violations to the errors above so handlers can report them to the caller
+func writeError(action string, err error) error {
+	if errors.Is(err, pgx.ErrNoRows) {
+		return fmt.Errorf("failed to %s: %w", action, ErrNotFound)
+	}
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		switch pgErr.Code {
+		case "23505":
+			return fmt.Errorf("failed to %s: %w", action, ErrDuplicate)
+		case "23503":
+			// A delete is blocked by rows that reference it, a write references a missing row
+			if strings.HasPrefix(action, "delete") {
+				return fmt.Errorf("failed to %s: %w", action, ErrInUse)
+			}
+			return fmt.Errorf("failed to %s: %w", action, ErrInvalidReference)
+		}
+	}
+
+	return fmt.Errorf("failed to %s: %w", action, err)
+}
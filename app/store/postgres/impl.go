@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 )
@@ -45,6 +46,9 @@ func New(ctx context.Context) *Store{
 	config.MaxConnIdleTime = 30 * time.Minute
 	config.HealthCheckPeriod = time.Minute
 
+	// Trace each query beneath the request that made it
+	config.ConnConfig.Tracer = queryTracer{}
+
 	// Create the connection pool with a timeout context
 	// defer cancel()
 
@@ -61,6 +65,11 @@ func New(ctx context.Context) *Store{
 		return nil
 	}
 
+	// Report pool stats alongside the other metrics
+	if err := prometheus.Register(&poolCollector{pool: pool}); err != nil {
+		logger.Warn().Err(err).Msg("failed to register postgres pool metrics")
+	}
+
 
 	return &Store{logger: &logger, db: pool}
 }
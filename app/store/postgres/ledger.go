@@ -0,0 +1,230 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/wmbryce/agent-c/app/types"
+)
+
+var (
+	// ErrInsufficientTokens is returned when an api key cannot cover a reservation.
+	ErrInsufficientTokens = errors.New("insufficient tokens available")
+	// ErrReservationClosed is returned when a reservation was already settled or released.
+	ErrReservationClosed = errors.New("token reservation is already closed")
+)
+
+// ReserveTokens debits amount from the api key and records an open reservation, which expires
+// after ttl if it is never closed. The conditional update locks the key row, so concurrent
+// reservations can never overdraw it.
+func (s *Store) ReserveTokens(ctx context.Context, apiKeyID string, modelKey string, amount int, ttl time.Duration) (*types.TokenReservation, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var balance int
+	err = tx.QueryRow(ctx, `
+		UPDATE agc.api_keys
+		SET tokens_available = tokens_available - $2, updated_at = NOW()
+		WHERE id = $1 AND tokens_available >= $2
+		RETURNING tokens_available
+	`, apiKeyID, amount).Scan(&balance)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrInsufficientTokens
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to debit api key: %w", err)
+	}
+
+	reservation := types.TokenReservation{
+		ApiKeyID:       apiKeyID,
+		ModelKey:       modelKey,
+		ReservedTokens: amount,
+	}
+	err = tx.QueryRow(ctx, `
+		INSERT INTO agc.token_reservations (api_key_id, model_key, reserved_tokens, expires_at)
+		VALUES ($1, $2, $3, NOW() + $4 * INTERVAL '1 second')
+		RETURNING id, status, created_at, expires_at
+	`, apiKeyID, modelKey, amount, ttl.Seconds()).Scan(
+		&reservation.ID,
+		&reservation.Status,
+		&reservation.CreatedAt,
+		&reservation.ExpiresAt,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create reservation: %w", err)
+	}
+
+	if err := insertLedgerEntry(ctx, tx, apiKeyID, &reservation.ID, "reserve", -amount, balance); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit reservation: %w", err)
+	}
+
+	return &reservation, nil
+}
+
+// SettleTokens closes a reservation at the actual number of tokens used,
+// refunding the unused remainder (or debiting any overage) in one transaction.
+func (s *Store) SettleTokens(ctx context.Context, reservationID string, actual int) (*types.TokenReservation, error) {
+	return s.closeReservation(ctx, reservationID, types.ReservationSettled, actual)
+}
+
+// ReleaseTokens closes a reservation without charging anything, refunding all reserved tokens.
+func (s *Store) ReleaseTokens(ctx context.Context, reservationID string) error {
+	_, err := s.closeReservation(ctx, reservationID, types.ReservationReleased, 0)
+	return err
+}
+
+// expiredReservationsBatch bounds how many reservations one sweep releases
+const expiredReservationsBatch = 500
+
+// ReleaseExpiredReservations releases open reservations past their expiry, refunding their
+// tokens through the ledger, and returns how many it released. Reservations closed
+// concurrently, by their request or another instance's sweep, are skipped.
+func (s *Store) ReleaseExpiredReservations(ctx context.Context) (int, error) {
+	ids, err := s.expiredReservationIDs(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	released := 0
+	for _, id := range ids {
+		_, err := s.closeReservation(ctx, id, types.ReservationReleased, 0)
+		if errors.Is(err, ErrReservationClosed) {
+			continue
+		}
+		if err != nil {
+			return released, err
+		}
+		released++
+	}
+	return released, nil
+}
+
+// expiredReservationIDs returns the oldest open reservations past their expiry
+func (s *Store) expiredReservationIDs(ctx context.Context) ([]string, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	rows, err := s.db.Query(ctx, `
+		SELECT id FROM agc.token_reservations
+		WHERE status = 'reserved' AND expires_at < NOW()
+		ORDER BY expires_at
+		LIMIT $1
+	`, expiredReservationsBatch)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query expired reservations: %w", err)
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan expired reservation: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+func (s *Store) closeReservation(ctx context.Context, reservationID string, status string, actual int) (*types.TokenReservation, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var reservation types.TokenReservation
+	err = tx.QueryRow(ctx, `
+		SELECT id, api_key_id, model_key, reserved_tokens, status, created_at, expires_at
+		FROM agc.token_reservations
+		WHERE id = $1
+		FOR UPDATE
+	`, reservationID).Scan(
+		&reservation.ID,
+		&reservation.ApiKeyID,
+		&reservation.ModelKey,
+		&reservation.ReservedTokens,
+		&reservation.Status,
+		&reservation.CreatedAt,
+		&reservation.ExpiresAt,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock reservation: %w", err)
+	}
+	if reservation.Status != types.ReservationReserved {
+		return nil, ErrReservationClosed
+	}
+
+	// Positive delta refunds the key, negative delta charges an overage
+	delta := reservation.ReservedTokens - actual
+
+	// An overage larger than the balance only takes the key to zero
+	var balance, previous int
+	err = tx.QueryRow(ctx, `
+		UPDATE agc.api_keys k
+		SET tokens_available = GREATEST(k.tokens_available + $2, 0), updated_at = NOW()
+		FROM (SELECT id, tokens_available FROM agc.api_keys WHERE id = $1 FOR UPDATE) old
+		WHERE k.id = old.id
+		RETURNING k.tokens_available, old.tokens_available
+	`, reservation.ApiKeyID, delta).Scan(&balance, &previous)
+	if err != nil {
+		return nil, fmt.Errorf("failed to refund api key: %w", err)
+	}
+
+	err = tx.QueryRow(ctx, `
+		UPDATE agc.token_reservations
+		SET status = $2, settled_tokens = $3, settled_at = NOW()
+		WHERE id = $1
+		RETURNING status, settled_tokens, settled_at
+	`, reservation.ID, status, actual).Scan(
+		&reservation.Status,
+		&reservation.SettledTokens,
+		&reservation.SettledAt,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to close reservation: %w", err)
+	}
+
+	entryType := "settle"
+	if status == types.ReservationReleased {
+		entryType = "release"
+	}
+	// Record the change actually applied, so the ledger always sums to the balance
+	if err := insertLedgerEntry(ctx, tx, reservation.ApiKeyID, &reservation.ID, entryType, balance-previous, balance); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit settlement: %w", err)
+	}
+
+	return &reservation, nil
+}
+
+// insertLedgerEntry records a change to an api key balance. Admin adjustments have no reservation.
+func insertLedgerEntry(ctx context.Context, tx pgx.Tx, apiKeyID string, reservationID *string, entryType string, tokens, balance int) error {
+	_, err := tx.Exec(ctx, `
+		INSERT INTO agc.token_ledger (api_key_id, reservation_id, entry_type, tokens, balance_after)
+		VALUES ($1, $2, $3, $4, $5)
+	`, apiKeyID, reservationID, entryType, tokens, balance)
+	if err != nil {
+		return fmt.Errorf("failed to write ledger entry: %w", err)
+	}
+	return nil
+}
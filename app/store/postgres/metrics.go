@@ -0,0 +1,54 @@
+package postgres
+
+import (
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	poolMaxConns = prometheus.NewDesc("agc_pgxpool_max_conns",
+		"Maximum size of the postgres connection pool.", nil, nil)
+	poolTotalConns = prometheus.NewDesc("agc_pgxpool_total_conns",
+		"Connections currently open, whether idle, acquired or being opened.", nil, nil)
+	poolAcquiredConns = prometheus.NewDesc("agc_pgxpool_acquired_conns",
+		"Connections currently in use by a query.", nil, nil)
+	poolIdleConns = prometheus.NewDesc("agc_pgxpool_idle_conns",
+		"Connections currently idle in the pool.", nil, nil)
+	poolAcquires = prometheus.NewDesc("agc_pgxpool_acquires_total",
+		"Connections acquired from the pool.", nil, nil)
+	poolEmptyAcquires = prometheus.NewDesc("agc_pgxpool_empty_acquires_total",
+		"Acquires that waited for a connection because the pool was empty.", nil, nil)
+	poolCanceledAcquires = prometheus.NewDesc("agc_pgxpool_canceled_acquires_total",
+		"Acquires canceled by their context while waiting for a connection.", nil, nil)
+	poolAcquireDuration = prometheus.NewDesc("agc_pgxpool_acquire_duration_seconds_total",
+		"Total time spent acquiring connections from the pool.", nil, nil)
+)
+
+// poolCollector reports the connection pool's stats each time metrics are scraped
+type poolCollector struct {
+	pool *pgxpool.Pool
+}
+
+func (c *poolCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- poolMaxConns
+	ch <- poolTotalConns
+	ch <- poolAcquiredConns
+	ch <- poolIdleConns
+	ch <- poolAcquires
+	ch <- poolEmptyAcquires
+	ch <- poolCanceledAcquires
+	ch <- poolAcquireDuration
+}
+
+func (c *poolCollector) Collect(ch chan<- prometheus.Metric) {
+	stat := c.pool.Stat()
+
+	ch <- prometheus.MustNewConstMetric(poolMaxConns, prometheus.GaugeValue, float64(stat.MaxConns()))
+	ch <- prometheus.MustNewConstMetric(poolTotalConns, prometheus.GaugeValue, float64(stat.TotalConns()))
+	ch <- prometheus.MustNewConstMetric(poolAcquiredConns, prometheus.GaugeValue, float64(stat.AcquiredConns()))
+	ch <- prometheus.MustNewConstMetric(poolIdleConns, prometheus.GaugeValue, float64(stat.IdleConns()))
+	ch <- prometheus.MustNewConstMetric(poolAcquires, prometheus.CounterValue, float64(stat.AcquireCount()))
+	ch <- prometheus.MustNewConstMetric(poolEmptyAcquires, prometheus.CounterValue, float64(stat.EmptyAcquireCount()))
+	ch <- prometheus.MustNewConstMetric(poolCanceledAcquires, prometheus.CounterValue, float64(stat.CanceledAcquireCount()))
+	ch <- prometheus.MustNewConstMetric(poolAcquireDuration, prometheus.CounterValue, stat.AcquireDuration().Seconds())
+}
@@ -2,7 +2,6 @@ package postgres
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"time"
 
@@ -18,14 +17,22 @@ func (s *Store) CreateModel(model *types.Model) (*types.Model, error) {
 		model.ID = uuid.New().String()
 	}
 
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
 	query := `
-		INSERT INTO agc.models (id, model_key, name, description, provider_id, options_schema_id, response_schema_id, request_url, created_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
-		RETURNING id, model_key, request_url, created_at, updated_at
+		INSERT INTO agc.models (id, model_key, name, description, provider_id, options_schema_id, response_schema_id, request_url,
+		                        fallback_models, response_cache_ttl, requests_per_minute, tokens_per_minute, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+		RETURNING id, model_key, request_url, fallback_models, response_cache_ttl, requests_per_minute, tokens_per_minute,
+		          created_at, updated_at
 	`
 
 	var createdModel types.Model
-	err := s.db.QueryRow(ctx, query,
+	err = tx.QueryRow(ctx, query,
 		model.ID,
 		model.ModelKey,
 		model.Name,
@@ -34,11 +41,19 @@ func (s *Store) CreateModel(model *types.Model) (*types.Model, error) {
 		model.OptionsSchemaID,
 		model.ResponseSchemaID,
 		model.RequestURL,
+		fallbackModels(model.FallbackModels),
+		model.ResponseCacheTTL,
+		model.RequestsPerMinute,
+		model.TokensPerMinute,
 		time.Now(),
 	).Scan(
 		&createdModel.ID,
 		&createdModel.ModelKey,
 		&createdModel.RequestURL,
+		&createdModel.FallbackModels,
+		&createdModel.ResponseCacheTTL,
+		&createdModel.RequestsPerMinute,
+		&createdModel.TokensPerMinute,
 		&createdModel.CreatedAt,
 		&createdModel.UpdatedAt,
 	)
@@ -53,6 +68,17 @@ func (s *Store) CreateModel(model *types.Model) (*types.Model, error) {
 	createdModel.OptionsSchemaID = model.OptionsSchemaID
 	createdModel.ResponseSchemaID = model.ResponseSchemaID
 
+	if model.Price != nil {
+		createdModel.Price, err = insertModelPrice(ctx, tx, createdModel.ID, model.Price)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit model: %w", err)
+	}
+
 	return &createdModel, nil
 }
 
@@ -61,9 +87,11 @@ func (s *Store) GetModels() ([]types.Model, error) {
 	defer cancel()
 
 	query := `
-		SELECT id, model_key, name, description, provider_id, options_schema_id, response_schema_id, request_url, created_at, updated_at
-		FROM agc.models
-		ORDER BY created_at DESC
+		SELECT m.id, m.model_key, m.name, m.description, m.provider_id, m.options_schema_id, m.response_schema_id,
+		       m.request_url, m.fallback_models, m.response_cache_ttl, m.requests_per_minute, m.tokens_per_minute,
+		       m.created_at, m.updated_at, ` + currentPriceColumns + `
+		FROM agc.models m` + currentPriceJoin + `
+		ORDER BY m.created_at DESC
 	`
 
 	rows, err := s.db.Query(ctx, query)
@@ -75,7 +103,8 @@ func (s *Store) GetModels() ([]types.Model, error) {
 	var models []types.Model
 	for rows.Next() {
 		var m types.Model
-		err := rows.Scan(
+		var price priceRow
+		err := rows.Scan(append([]any{
 			&m.ID,
 			&m.ModelKey,
 			&m.Name,
@@ -84,12 +113,17 @@ func (s *Store) GetModels() ([]types.Model, error) {
 			&m.OptionsSchemaID,
 			&m.ResponseSchemaID,
 			&m.RequestURL,
+			&m.FallbackModels,
+			&m.ResponseCacheTTL,
+			&m.RequestsPerMinute,
+			&m.TokensPerMinute,
 			&m.CreatedAt,
 			&m.UpdatedAt,
-		)
+		}, price.dest()...)...)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan model: %w", err)
 		}
+		m.Price = price.price()
 		models = append(models, m)
 	}
 
@@ -100,69 +134,147 @@ func (s *Store) GetModels() ([]types.Model, error) {
 	return models, nil
 }
 
-func (s *Store) GetModelCredentials(modelKey string) (*types.ModelCredentials, error) {
+// UpdateModel replaces a model's fields. Prices are versioned separately and left unchanged.
+func (s *Store) UpdateModel(model *types.Model) (*types.Model, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
 	query := `
-		SELECT m.model_key, m.request_url, ak.api_key, ak.tokens_available, p.name,
-		       p.auth_type, p.auth_header, p.extra_headers, p.request_defaults, p.response_mapping
-		FROM agc.models m
-		JOIN agc.providers p ON m.provider_id = p.id
-		JOIN agc.api_keys ak ON ak.provider_id = p.id
-		WHERE m.model_key = $1
+		UPDATE agc.models SET
+			model_key = $2, name = $3, description = $4, provider_id = $5, options_schema_id = $6,
+			response_schema_id = $7, request_url = $8, fallback_models = $9, response_cache_ttl = $10,
+			requests_per_minute = $11, tokens_per_minute = $12, updated_at = NOW()
+		WHERE id = $1
+		RETURNING id, model_key, name, description, provider_id, options_schema_id, response_schema_id,
+		          request_url, fallback_models, response_cache_ttl, requests_per_minute, tokens_per_minute, created_at, updated_at
 	`
 
-	var creds types.ModelCredentials
-	var authType, authHeader *string
-	var extraHeaders, requestDefaults, responseMapping []byte
-
-	err := s.db.QueryRow(ctx, query, modelKey).Scan(
-		&creds.ModelKey,
-		&creds.RequestURL,
-		&creds.ApiKey,
-		&creds.TokensAvailable,
-		&creds.ProviderName,
-		&authType,
-		&authHeader,
-		&extraHeaders,
-		&requestDefaults,
-		&responseMapping,
+	var updated types.Model
+	err := s.db.QueryRow(ctx, query,
+		model.ID,
+		model.ModelKey,
+		model.Name,
+		model.Description,
+		model.ProviderID,
+		model.OptionsSchemaID,
+		model.ResponseSchemaID,
+		model.RequestURL,
+		fallbackModels(model.FallbackModels),
+		model.ResponseCacheTTL,
+		model.RequestsPerMinute,
+		model.TokensPerMinute,
+	).Scan(
+		&updated.ID,
+		&updated.ModelKey,
+		&updated.Name,
+		&updated.Description,
+		&updated.ProviderID,
+		&updated.OptionsSchemaID,
+		&updated.ResponseSchemaID,
+		&updated.RequestURL,
+		&updated.FallbackModels,
+		&updated.ResponseCacheTTL,
+		&updated.RequestsPerMinute,
+		&updated.TokensPerMinute,
+		&updated.CreatedAt,
+		&updated.UpdatedAt,
 	)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get model credentials: %w", err)
+		return nil, writeError("update model", err)
 	}
 
-	// Parse provider config
-	config := &types.ProviderConfig{
-		ExtraHeaders:    make(map[string]string),
-		RequestDefaults: make(map[string]any),
-		ResponseMapping: make(map[string]string),
-	}
+	return &updated, nil
+}
+
+// DeleteModel removes a model and its prices.
+func (s *Store) DeleteModel(id string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
 
-	if authType != nil {
-		config.AuthType = *authType
+	tag, err := s.db.Exec(ctx, `DELETE FROM agc.models WHERE id = $1`, id)
+	if err != nil {
+		return writeError("delete model", err)
 	}
-	if authHeader != nil {
-		config.AuthHeader = *authHeader
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("failed to delete model: %w", ErrNotFound)
 	}
-	if len(extraHeaders) > 0 {
-		if err := json.Unmarshal(extraHeaders, &config.ExtraHeaders); err != nil {
-			return nil, fmt.Errorf("failed to unmarshal extra_headers: %w", err)
-		}
+
+	return nil
+}
+
+// GetModelCredentials returns every seller key that can serve the model,
+// ordered by the tokens each key still has available.
+func (s *Store) GetModelCredentials(ctx context.Context, modelKey string) ([]types.ModelCredentials, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	query := `
+		SELECT m.model_key, m.request_url, m.fallback_models, m.response_cache_ttl, m.requests_per_minute, m.tokens_per_minute,
+		       m.options_schema_id, m.response_schema_id, ak.id, ak.key_ciphertext, ak.key_wrapped, ak.key_master_id,
+		       ak.tokens_available, ak.requests_per_minute, ak.tokens_per_minute, p.name, ` + providerConfigColumns + `, ` + currentPriceColumns + `
+		FROM agc.models m
+		JOIN agc.providers p ON m.provider_id = p.id
+		JOIN agc.api_keys ak ON ak.provider_id = p.id` + currentPriceJoin + `
+		WHERE m.model_key = $1 AND ak.tokens_available > 0 AND ak.key_ciphertext IS NOT NULL
+		ORDER BY ak.tokens_available DESC, ak.id
+	`
+
+	rows, err := s.db.Query(ctx, query, modelKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get model credentials: %w", err)
 	}
-	if len(requestDefaults) > 0 {
-		if err := json.Unmarshal(requestDefaults, &config.RequestDefaults); err != nil {
-			return nil, fmt.Errorf("failed to unmarshal request_defaults: %w", err)
+	defer rows.Close()
+
+	keys := []types.ModelCredentials{}
+	for rows.Next() {
+		var creds types.ModelCredentials
+		var sealed types.EncryptedKey
+		var config providerConfigRow
+		var price priceRow
+
+		dest := []any{
+			&creds.ModelKey,
+			&creds.RequestURL,
+			&creds.FallbackModels,
+			&creds.ResponseCacheTTL,
+			&creds.ModelRateLimit.RequestsPerMinute,
+			&creds.ModelRateLimit.TokensPerMinute,
+			&creds.OptionsSchemaID,
+			&creds.ResponseSchemaID,
+			&creds.ApiKeyID,
+			&sealed.Ciphertext,
+			&sealed.WrappedKey,
+			&sealed.MasterKeyID,
+			&creds.TokensAvailable,
+			&creds.KeyRateLimit.RequestsPerMinute,
+			&creds.KeyRateLimit.TokensPerMinute,
+			&creds.ProviderName,
 		}
-	}
-	if len(responseMapping) > 0 {
-		if err := json.Unmarshal(responseMapping, &config.ResponseMapping); err != nil {
-			return nil, fmt.Errorf("failed to unmarshal response_mapping: %w", err)
+		dest = append(dest, config.dest()...)
+		if err := rows.Scan(append(dest, price.dest()...)...); err != nil {
+			return nil, fmt.Errorf("failed to scan model credentials: %w", err)
 		}
+
+		creds.SealedKey = &sealed
+		creds.Price = price.price()
+		creds.ProviderConfig, err = config.config()
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, creds)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating model credentials: %w", err)
 	}
 
-	creds.ProviderConfig = config
+	return keys, nil
+}
 
-	return &creds, nil
+// fallbackModels stores a missing fallback chain as an empty array
+func fallbackModels(models []string) []string {
+	if models == nil {
+		return []string{}
+	}
+	return models
 }
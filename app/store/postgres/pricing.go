@@ -0,0 +1,107 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/wmbryce/agent-c/app/types"
+)
+
+// currentPriceJoin joins the price row in effect for model alias m as alias price.
+const currentPriceJoin = `
+		LEFT JOIN LATERAL (
+			SELECT mp.currency, mp.input_price, mp.output_price, mp.cached_input_price, mp.effective_from
+			FROM agc.model_prices mp
+			WHERE mp.model_id = m.id AND mp.effective_from <= NOW()
+			ORDER BY mp.effective_from DESC
+			LIMIT 1
+		) price ON TRUE`
+
+// currentPriceColumns lists the columns exposed by currentPriceJoin, in scan order.
+const currentPriceColumns = `price.currency, price.input_price, price.output_price, price.cached_input_price, price.effective_from`
+
+// priceRow holds the nullable columns of a left-joined price.
+type priceRow struct {
+	currency         *string
+	inputPrice       *float64
+	outputPrice      *float64
+	cachedInputPrice *float64
+	effectiveFrom    *time.Time
+}
+
+func (r *priceRow) dest() []any {
+	return []any{&r.currency, &r.inputPrice, &r.outputPrice, &r.cachedInputPrice, &r.effectiveFrom}
+}
+
+// price returns nil when the model had no price in effect
+func (r *priceRow) price() *types.ModelPrice {
+	if r.currency == nil {
+		return nil
+	}
+	return &types.ModelPrice{
+		Currency:         *r.currency,
+		InputPrice:       *r.inputPrice,
+		OutputPrice:      *r.outputPrice,
+		CachedInputPrice: r.cachedInputPrice,
+		EffectiveFrom:    *r.effectiveFrom,
+	}
+}
+
+// CreateModelPrice adds a new price version for a model. A zero EffectiveFrom takes effect immediately.
+func (s *Store) CreateModelPrice(modelID string, price *types.ModelPrice) (*types.ModelPrice, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	created, err := insertModelPrice(ctx, tx, modelID, price)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit model price: %w", err)
+	}
+
+	return created, nil
+}
+
+func insertModelPrice(ctx context.Context, tx pgx.Tx, modelID string, price *types.ModelPrice) (*types.ModelPrice, error) {
+	effectiveFrom := price.EffectiveFrom
+	if effectiveFrom.IsZero() {
+		effectiveFrom = time.Now()
+	}
+
+	query := `
+		INSERT INTO agc.model_prices (model_id, currency, input_price, output_price, cached_input_price, effective_from)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING currency, input_price, output_price, cached_input_price, effective_from
+	`
+
+	var created types.ModelPrice
+	err := tx.QueryRow(ctx, query,
+		modelID,
+		price.Currency,
+		price.InputPrice,
+		price.OutputPrice,
+		price.CachedInputPrice,
+		effectiveFrom,
+	).Scan(
+		&created.Currency,
+		&created.InputPrice,
+		&created.OutputPrice,
+		&created.CachedInputPrice,
+		&created.EffectiveFrom,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create model price: %w", err)
+	}
+
+	return &created, nil
+}
@@ -0,0 +1,262 @@
+package postgres
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/wmbryce/agent-c/app/types"
+)
+
+// providerConfigColumns lists the config columns of provider alias p, in providerConfigRow scan order.
+const providerConfigColumns = `p.auth_type, p.auth_header, p.extra_headers, p.request_defaults, p.request_mapping,
+		       p.response_mapping, p.stream_defaults, p.stream_mapping, p.retry_policy, p.circuit_breaker`
+
+// providerConfigRow holds the nullable config columns of a provider.
+type providerConfigRow struct {
+	authType        *string
+	authHeader      *string
+	extraHeaders    []byte
+	requestDefaults []byte
+	requestMapping  []byte
+	responseMapping []byte
+	streamDefaults  []byte
+	streamMapping   []byte
+	retryPolicy     []byte
+	circuitBreaker  []byte
+}
+
+func (r *providerConfigRow) dest() []any {
+	return []any{
+		&r.authType, &r.authHeader, &r.extraHeaders, &r.requestDefaults, &r.requestMapping,
+		&r.responseMapping, &r.streamDefaults, &r.streamMapping, &r.retryPolicy, &r.circuitBreaker,
+	}
+}
+
+// config parses the columns into a provider config
+func (r *providerConfigRow) config() (*types.ProviderConfig, error) {
+	config := &types.ProviderConfig{
+		ExtraHeaders:    make(map[string]string),
+		RequestDefaults: make(map[string]any),
+		ResponseMapping: make(map[string]string),
+		StreamDefaults:  make(map[string]any),
+		StreamMapping:   make(map[string]string),
+	}
+
+	if r.authType != nil {
+		config.AuthType = *r.authType
+	}
+	if r.authHeader != nil {
+		config.AuthHeader = *r.authHeader
+	}
+
+	columns := []struct {
+		name  string
+		value []byte
+		dest  any
+	}{
+		{"extra_headers", r.extraHeaders, &config.ExtraHeaders},
+		{"request_defaults", r.requestDefaults, &config.RequestDefaults},
+		{"request_mapping", r.requestMapping, &config.RequestMapping},
+		{"response_mapping", r.responseMapping, &config.ResponseMapping},
+		{"stream_defaults", r.streamDefaults, &config.StreamDefaults},
+		{"stream_mapping", r.streamMapping, &config.StreamMapping},
+		{"retry_policy", r.retryPolicy, &config.RetryPolicy},
+		{"circuit_breaker", r.circuitBreaker, &config.CircuitBreaker},
+	}
+	for _, column := range columns {
+		if len(column.value) == 0 {
+			continue
+		}
+		if err := json.Unmarshal(column.value, column.dest); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal %s: %w", column.name, err)
+		}
+	}
+
+	return config, nil
+}
+
+// providerConfigArgs returns the query arguments for the provider config columns, in
+// providerConfigColumns order. Unset maps are stored empty and unset policies as NULL.
+func providerConfigArgs(config *types.ProviderConfig) ([]any, error) {
+	columns := []struct {
+		name  string
+		value any
+		null  bool
+	}{
+		{"extra_headers", emptyIfNil(config.ExtraHeaders), false},
+		{"request_defaults", emptyIfNil(config.RequestDefaults), false},
+		{"request_mapping", config.RequestMapping, len(config.RequestMapping) == 0},
+		{"response_mapping", emptyIfNil(config.ResponseMapping), false},
+		{"stream_defaults", emptyIfNil(config.StreamDefaults), false},
+		{"stream_mapping", emptyIfNil(config.StreamMapping), false},
+		{"retry_policy", config.RetryPolicy, config.RetryPolicy == nil},
+		{"circuit_breaker", config.CircuitBreaker, config.CircuitBreaker == nil},
+	}
+
+	args := []any{nullString(config.AuthType), nullString(config.AuthHeader)}
+	for _, column := range columns {
+		if column.null {
+			args = append(args, nil)
+			continue
+		}
+		encoded, err := json.Marshal(column.value)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal %s: %w", column.name, err)
+		}
+		args = append(args, encoded)
+	}
+	return args, nil
+}
+
+func emptyIfNil[M ~map[K]V, K comparable, V any](m M) M {
+	if m == nil {
+		return M{}
+	}
+	return m
+}
+
+// nullString stores an empty string as NULL
+func nullString(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+// providerColumns lists the columns scanned by scanProvider.
+const providerColumns = `p.id, p.name, p.description, p.endpoint_url, ` + providerConfigColumns + `, p.created_at, p.updated_at`
+
+func scanProvider(row pgx.Row) (*types.Provider, error) {
+	var provider types.Provider
+	var config providerConfigRow
+
+	dest := append([]any{&provider.ID, &provider.Name, &provider.Description, &provider.EndpointURL}, config.dest()...)
+	if err := row.Scan(append(dest, &provider.CreatedAt, &provider.UpdatedAt)...); err != nil {
+		return nil, err
+	}
+
+	parsed, err := config.config()
+	if err != nil {
+		return nil, err
+	}
+	provider.ProviderConfig = *parsed
+
+	return &provider, nil
+}
+
+// CreateProvider adds a provider.
+func (s *Store) CreateProvider(provider *types.Provider) (*types.Provider, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	configArgs, err := providerConfigArgs(&provider.ProviderConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		INSERT INTO agc.providers AS p (name, description, endpoint_url, auth_type, auth_header, extra_headers,
+		                                request_defaults, request_mapping, response_mapping, stream_defaults,
+		                                stream_mapping, retry_policy, circuit_breaker)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+		RETURNING ` + providerColumns
+
+	args := append([]any{provider.Name, provider.Description, provider.EndpointURL}, configArgs...)
+	created, err := scanProvider(s.db.QueryRow(ctx, query, args...))
+	if err != nil {
+		return nil, writeError("create provider", err)
+	}
+
+	return created, nil
+}
+
+// GetProviders returns every provider, sorted by name.
+func (s *Store) GetProviders() ([]types.Provider, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	query := `SELECT ` + providerColumns + ` FROM agc.providers p ORDER BY p.name`
+
+	rows, err := s.db.Query(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query providers: %w", err)
+	}
+	defer rows.Close()
+
+	providers := []types.Provider{}
+	for rows.Next() {
+		provider, err := scanProvider(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan provider: %w", err)
+		}
+		providers = append(providers, *provider)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating providers: %w", err)
+	}
+
+	return providers, nil
+}
+
+// GetProvider returns a provider by id.
+func (s *Store) GetProvider(id string) (*types.Provider, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	query := `SELECT ` + providerColumns + ` FROM agc.providers p WHERE p.id = $1`
+
+	provider, err := scanProvider(s.db.QueryRow(ctx, query, id))
+	if err != nil {
+		return nil, writeError("get provider", err)
+	}
+
+	return provider, nil
+}
+
+// UpdateProvider replaces a provider's fields and config.
+func (s *Store) UpdateProvider(provider *types.Provider) (*types.Provider, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	configArgs, err := providerConfigArgs(&provider.ProviderConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		UPDATE agc.providers AS p SET
+			name = $2, description = $3, endpoint_url = $4, auth_type = $5, auth_header = $6,
+			extra_headers = $7, request_defaults = $8, request_mapping = $9, response_mapping = $10,
+			stream_defaults = $11, stream_mapping = $12, retry_policy = $13, circuit_breaker = $14,
+			updated_at = NOW()
+		WHERE p.id = $1
+		RETURNING ` + providerColumns
+
+	args := append([]any{provider.ID, provider.Name, provider.Description, provider.EndpointURL}, configArgs...)
+	updated, err := scanProvider(s.db.QueryRow(ctx, query, args...))
+	if err != nil {
+		return nil, writeError("update provider", err)
+	}
+
+	return updated, nil
+}
+
+// DeleteProvider removes a provider along with its models and api keys.
+func (s *Store) DeleteProvider(id string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	tag, err := s.db.Exec(ctx, `DELETE FROM agc.providers WHERE id = $1`, id)
+	if err != nil {
+		return writeError("delete provider", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("failed to delete provider: %w", ErrNotFound)
+	}
+
+	return nil
+}
@@ -0,0 +1,127 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/wmbryce/agent-c/app/types"
+)
+
+// schemaColumns lists the columns scanned by scanModelSchema.
+const schemaColumns = `id, type, name, schema, created_at, updated_at`
+
+func scanModelSchema(row pgx.Row) (*types.ModelSchema, error) {
+	var schema types.ModelSchema
+	var document []byte
+	err := row.Scan(
+		&schema.ID,
+		&schema.Type,
+		&schema.Name,
+		&document,
+		&schema.CreatedAt,
+		&schema.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	schema.Schema = document
+	return &schema, nil
+}
+
+// CreateModelSchema adds a model options or response schema.
+func (s *Store) CreateModelSchema(schema *types.ModelSchema) (*types.ModelSchema, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	query := `
+		INSERT INTO agc.model_schemas (type, name, schema)
+		VALUES ($1, $2, $3)
+		RETURNING ` + schemaColumns
+
+	created, err := scanModelSchema(s.db.QueryRow(ctx, query, schema.Type, schema.Name, []byte(schema.Schema)))
+	if err != nil {
+		return nil, writeError("create model schema", err)
+	}
+
+	return created, nil
+}
+
+// GetModelSchemas returns every model schema, sorted by type and name.
+func (s *Store) GetModelSchemas() ([]types.ModelSchema, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	query := `SELECT ` + schemaColumns + ` FROM agc.model_schemas ORDER BY type, name`
+
+	rows, err := s.db.Query(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query model schemas: %w", err)
+	}
+	defer rows.Close()
+
+	schemas := []types.ModelSchema{}
+	for rows.Next() {
+		schema, err := scanModelSchema(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan model schema: %w", err)
+		}
+		schemas = append(schemas, *schema)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating model schemas: %w", err)
+	}
+
+	return schemas, nil
+}
+
+// GetModelSchema returns a model options or response schema by id.
+func (s *Store) GetModelSchema(id string) (*types.ModelSchema, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	query := `SELECT ` + schemaColumns + ` FROM agc.model_schemas WHERE id = $1`
+
+	schema, err := scanModelSchema(s.db.QueryRow(ctx, query, id))
+	if err != nil {
+		return nil, writeError("get model schema", err)
+	}
+
+	return schema, nil
+}
+
+// UpdateModelSchema replaces a model schema.
+func (s *Store) UpdateModelSchema(schema *types.ModelSchema) (*types.ModelSchema, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	query := `
+		UPDATE agc.model_schemas SET type = $2, name = $3, schema = $4, updated_at = NOW()
+		WHERE id = $1
+		RETURNING ` + schemaColumns
+
+	updated, err := scanModelSchema(s.db.QueryRow(ctx, query, schema.ID, schema.Type, schema.Name, []byte(schema.Schema)))
+	if err != nil {
+		return nil, writeError("update model schema", err)
+	}
+
+	return updated, nil
+}
+
+// DeleteModelSchema removes a model schema that no model uses.
+func (s *Store) DeleteModelSchema(id string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	tag, err := s.db.Exec(ctx, `DELETE FROM agc.model_schemas WHERE id = $1`, id)
+	if err != nil {
+		return writeError("delete model schema", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("failed to delete model schema: %w", ErrNotFound)
+	}
+
+	return nil
+}
@@ -0,0 +1,148 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/wmbryce/agent-c/app/types"
+)
+
+// CreateSeller adds a seller wallet.
+func (s *Store) CreateSeller(seller *types.Seller) (*types.Seller, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var created types.Seller
+	err := s.db.QueryRow(ctx, `
+		INSERT INTO agc.sellers (wallet_address)
+		VALUES ($1)
+		RETURNING id, wallet_address, created_at, updated_at
+	`, seller.WalletAddress).Scan(
+		&created.ID,
+		&created.WalletAddress,
+		&created.CreatedAt,
+		&created.UpdatedAt,
+	)
+	if err != nil {
+		return nil, writeError("create seller", err)
+	}
+
+	return &created, nil
+}
+
+// GetSellers returns every seller, newest first.
+func (s *Store) GetSellers() ([]types.Seller, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	rows, err := s.db.Query(ctx, `
+		SELECT id, wallet_address, created_at, updated_at
+		FROM agc.sellers
+		ORDER BY created_at DESC
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query sellers: %w", err)
+	}
+	defer rows.Close()
+
+	sellers := []types.Seller{}
+	for rows.Next() {
+		var seller types.Seller
+		if err := rows.Scan(&seller.ID, &seller.WalletAddress, &seller.CreatedAt, &seller.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan seller: %w", err)
+		}
+		sellers = append(sellers, seller)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating sellers: %w", err)
+	}
+
+	return sellers, nil
+}
+
+// GetSeller returns a seller by id.
+func (s *Store) GetSeller(id string) (*types.Seller, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var seller types.Seller
+	err := s.db.QueryRow(ctx, `
+		SELECT id, wallet_address, created_at, updated_at
+		FROM agc.sellers
+		WHERE id = $1
+	`, id).Scan(
+		&seller.ID,
+		&seller.WalletAddress,
+		&seller.CreatedAt,
+		&seller.UpdatedAt,
+	)
+	if err != nil {
+		return nil, writeError("get seller", err)
+	}
+
+	return &seller, nil
+}
+
+// UpdateSeller changes a seller's wallet address.
+func (s *Store) UpdateSeller(seller *types.Seller) (*types.Seller, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var updated types.Seller
+	err := s.db.QueryRow(ctx, `
+		UPDATE agc.sellers SET wallet_address = $2, updated_at = NOW()
+		WHERE id = $1
+		RETURNING id, wallet_address, created_at, updated_at
+	`, seller.ID, seller.WalletAddress).Scan(
+		&updated.ID,
+		&updated.WalletAddress,
+		&updated.CreatedAt,
+		&updated.UpdatedAt,
+	)
+	if err != nil {
+		return nil, writeError("update seller", err)
+	}
+
+	return &updated, nil
+}
+
+// DeleteSeller removes a seller along with its api keys.
+func (s *Store) DeleteSeller(id string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	tag, err := s.db.Exec(ctx, `DELETE FROM agc.sellers WHERE id = $1`, id)
+	if err != nil {
+		return writeError("delete seller", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("failed to delete seller: %w", ErrNotFound)
+	}
+
+	return nil
+}
+
+// GetSellerByWallet returns the seller with a wallet address, compared case-insensitively.
+func (s *Store) GetSellerByWallet(wallet string) (*types.Seller, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var seller types.Seller
+	err := s.db.QueryRow(ctx, `
+		SELECT id, wallet_address, created_at, updated_at
+		FROM agc.sellers
+		WHERE LOWER(wallet_address) = LOWER($1)
+	`, wallet).Scan(
+		&seller.ID,
+		&seller.WalletAddress,
+		&seller.CreatedAt,
+		&seller.UpdatedAt,
+	)
+	if err != nil {
+		return nil, writeError("get seller", err)
+	}
+
+	return &seller, nil
+}
@@ -0,0 +1,175 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/wmbryce/agent-c/app/types"
+)
+
+func (s *Store) RecordUsage(ctx context.Context, record *types.UsageRecord) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	query := `
+		INSERT INTO agc.usage_records (consumer_id, model_key, api_key_id, prompt_tokens, completion_tokens,
+		                               total_tokens, cost, currency, latency_ms, provider_status, stream)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		RETURNING id, created_at
+	`
+
+	err := s.db.QueryRow(ctx, query,
+		record.ConsumerID,
+		record.ModelKey,
+		record.ApiKeyID,
+		record.PromptTokens,
+		record.CompletionTokens,
+		record.TotalTokens,
+		record.Cost,
+		record.Currency,
+		record.LatencyMs,
+		record.ProviderStatus,
+		record.Stream,
+	).Scan(&record.ID, &record.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to record usage: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Store) GetUsage(filter *types.UsageFilter) ([]types.UsageRecord, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	where, args := usageWhereClause(filter)
+	args = append(args, filter.Limit, filter.Offset)
+
+	query := fmt.Sprintf(`
+		SELECT id, consumer_id, model_key, api_key_id, prompt_tokens, completion_tokens,
+		       total_tokens, cost, currency, latency_ms, provider_status, stream, created_at
+		FROM agc.usage_records
+		%s
+		ORDER BY created_at DESC
+		LIMIT $%d OFFSET $%d
+	`, where, len(args)-1, len(args))
+
+	rows, err := s.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query usage: %w", err)
+	}
+	defer rows.Close()
+
+	records := []types.UsageRecord{}
+	for rows.Next() {
+		var r types.UsageRecord
+		err := rows.Scan(
+			&r.ID,
+			&r.ConsumerID,
+			&r.ModelKey,
+			&r.ApiKeyID,
+			&r.PromptTokens,
+			&r.CompletionTokens,
+			&r.TotalTokens,
+			&r.Cost,
+			&r.Currency,
+			&r.LatencyMs,
+			&r.ProviderStatus,
+			&r.Stream,
+			&r.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan usage record: %w", err)
+		}
+		records = append(records, r)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating usage records: %w", err)
+	}
+
+	return records, nil
+}
+
+func (s *Store) GetDailyUsage(filter *types.UsageFilter) ([]types.DailyUsage, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	where, args := usageWhereClause(filter)
+
+	query := fmt.Sprintf(`
+		SELECT date_trunc('day', created_at) AS day, model_key, consumer_id, COUNT(*),
+		       SUM(prompt_tokens), SUM(completion_tokens), SUM(total_tokens), SUM(cost), currency
+		FROM agc.usage_records
+		%s
+		GROUP BY day, model_key, consumer_id, currency
+		ORDER BY day DESC, model_key
+	`, where)
+
+	rows, err := s.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query daily usage: %w", err)
+	}
+	defer rows.Close()
+
+	days := []types.DailyUsage{}
+	for rows.Next() {
+		var d types.DailyUsage
+		err := rows.Scan(
+			&d.Day,
+			&d.ModelKey,
+			&d.ConsumerID,
+			&d.Requests,
+			&d.PromptTokens,
+			&d.CompletionTokens,
+			&d.TotalTokens,
+			&d.Cost,
+			&d.Currency,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan daily usage: %w", err)
+		}
+		days = append(days, d)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating daily usage: %w", err)
+	}
+
+	return days, nil
+}
+
+// usageWhereClause builds the WHERE clause and positional arguments for a usage filter
+func usageWhereClause(filter *types.UsageFilter) (string, []any) {
+	var conditions []string
+	var args []any
+
+	if filter.From != nil {
+		args = append(args, *filter.From)
+		conditions = append(conditions, fmt.Sprintf("created_at >= $%d", len(args)))
+	}
+	if filter.To != nil {
+		args = append(args, *filter.To)
+		conditions = append(conditions, fmt.Sprintf("created_at < $%d", len(args)))
+	}
+	if filter.ModelKey != "" {
+		args = append(args, filter.ModelKey)
+		conditions = append(conditions, fmt.Sprintf("model_key = $%d", len(args)))
+	}
+	if filter.ConsumerID != "" {
+		args = append(args, filter.ConsumerID)
+		conditions = append(conditions, fmt.Sprintf("consumer_id = $%d", len(args)))
+	}
+	if filter.ApiKeyID != "" {
+		args = append(args, filter.ApiKeyID)
+		conditions = append(conditions, fmt.Sprintf("api_key_id = $%d", len(args)))
+	}
+
+	if len(conditions) == 0 {
+		return "", args
+	}
+
+	return "WHERE " + strings.Join(conditions, " AND "), args
+}
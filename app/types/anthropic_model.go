@@ -0,0 +1,81 @@
+package types
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// AnthropicMessagesRequest struct to describe an Anthropic Messages API request object.
+// MaxCost and FallbackModels are agent-c extensions to the Anthropic format.
+type AnthropicMessagesRequest struct {
+	Model          string             `json:"model" validate:"required"`
+	MaxTokens      int                `json:"max_tokens" validate:"required,min=1"`
+	Messages       []AnthropicMessage `json:"messages" validate:"required,min=1,dive"`
+	System         AnthropicContent   `json:"system,omitempty"`
+	Temperature    *float32           `json:"temperature,omitempty" validate:"omitempty,min=0,max=1"`
+	TopP           *float32           `json:"top_p,omitempty" validate:"omitempty,min=0,max=1"`
+	StopSequences  []string           `json:"stop_sequences,omitempty"`
+	Stream         bool               `json:"stream,omitempty"`
+	MaxCost        *float64           `json:"max_cost,omitempty" validate:"omitempty,gt=0"`
+	FallbackModels []string           `json:"fallback_models,omitempty" validate:"omitempty,max=5,dive,required"`
+}
+
+// AnthropicMessage struct to describe a conversation turn object.
+type AnthropicMessage struct {
+	Role    string           `json:"role" validate:"required,oneof=user assistant"`
+	Content AnthropicContent `json:"content" validate:"required,min=1"`
+}
+
+// AnthropicContentBlock struct to describe a content block object.
+type AnthropicContentBlock struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+// AnthropicContent is message or system content, sent either as a plain string or as content blocks.
+type AnthropicContent []AnthropicContentBlock
+
+// UnmarshalJSON accepts both the string and the content block forms.
+func (c *AnthropicContent) UnmarshalJSON(data []byte) error {
+	var text string
+	if err := json.Unmarshal(data, &text); err == nil {
+		*c = AnthropicContent{{Type: "text", Text: text}}
+		return nil
+	}
+
+	var blocks []AnthropicContentBlock
+	if err := json.Unmarshal(data, &blocks); err != nil {
+		return err
+	}
+	*c = blocks
+	return nil
+}
+
+// Text joins the text blocks of the content.
+func (c AnthropicContent) Text() string {
+	var parts []string
+	for _, block := range c {
+		if block.Type == "text" {
+			parts = append(parts, block.Text)
+		}
+	}
+	return strings.Join(parts, "\n")
+}
+
+// AnthropicMessagesResponse struct to describe an Anthropic Messages API response object.
+type AnthropicMessagesResponse struct {
+	ID           string                  `json:"id"`
+	Type         string                  `json:"type"`
+	Role         string                  `json:"role"`
+	Model        string                  `json:"model"`
+	Content      []AnthropicContentBlock `json:"content"`
+	StopReason   string                  `json:"stop_reason"`
+	StopSequence *string                 `json:"stop_sequence"`
+	Usage        AnthropicUsage          `json:"usage"`
+}
+
+// AnthropicUsage struct to describe usage object.
+type AnthropicUsage struct {
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+}
@@ -0,0 +1,96 @@
+package types
+
+import (
+	"slices"
+	"time"
+)
+
+// Roles carried in access tokens
+const (
+	RoleSeller   = "seller"
+	RoleConsumer = "consumer"
+	RoleAdmin    = "admin"
+)
+
+// Permissions checked per route. Access tokens carry the permissions of their roles,
+// and consumer api keys carry theirs as scopes.
+const (
+	// PermissionConsume allows calling models through /ai/consume and the compatible APIs
+	PermissionConsume = "consume"
+	// PermissionUsageRead allows reading usage
+	PermissionUsageRead = "usage:read"
+	// PermissionModelsWrite allows creating, pricing, updating and deleting models
+	PermissionModelsWrite = "models:write"
+	// PermissionProvidersAdmin allows managing providers and model schemas
+	PermissionProvidersAdmin = "providers:admin"
+	// PermissionSellersAdmin allows managing sellers and their provider api keys
+	PermissionSellersAdmin = "sellers:admin"
+	// PermissionConsumersAdmin allows managing consumers and issuing their api keys
+	PermissionConsumersAdmin = "consumers:admin"
+)
+
+// RolePermissions lists the permissions each role grants. Sellers have none of their own
+// yet: their api keys are managed by admins.
+var RolePermissions = map[string][]string{
+	RoleAdmin: {
+		PermissionUsageRead,
+		PermissionModelsWrite,
+		PermissionProvidersAdmin,
+		PermissionSellersAdmin,
+		PermissionConsumersAdmin,
+	},
+	RoleConsumer: {PermissionConsume, PermissionUsageRead},
+}
+
+// PermissionsForRoles returns the permissions granted by any of the roles.
+func PermissionsForRoles(roles []string) []string {
+	permissions := []string{}
+	for _, role := range roles {
+		for _, permission := range RolePermissions[role] {
+			if !slices.Contains(permissions, permission) {
+				permissions = append(permissions, permission)
+			}
+		}
+	}
+	return permissions
+}
+
+// SiweNonce struct to describe a nonce issued for a Sign-In with Ethereum message.
+type SiweNonce struct {
+	Nonce     string    `json:"nonce"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// SiweLoginRequest struct to describe a signed Sign-In with Ethereum message.
+type SiweLoginRequest struct {
+	Message   string `json:"message" validate:"required"`
+	Signature string `json:"signature" validate:"required"`
+}
+
+// AuthTokens struct to describe the tokens issued on login and refresh.
+type AuthTokens struct {
+	Access    string   `json:"access"`
+	Refresh   string   `json:"refresh"`
+	SessionID string   `json:"session_id"`
+	Wallet    string   `json:"wallet"`
+	Roles     []string `json:"roles"`
+}
+
+// JWK struct to describe a public key that verifies agent-c access tokens.
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	// N and E are the modulus and exponent of RSA keys
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+	// Crv and X are the curve and public key of Ed25519 keys
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+// JWKS struct to describe the JSON Web Key Set served at /.well-known/jwks.json.
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
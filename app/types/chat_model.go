@@ -7,45 +7,75 @@ type ConsumeModelRequest struct {
 	Messages []ChatMessage          `json:"messages" validate:"required,min=1,dive"`
 	Options  map[string]interface{} `json:"options,omitempty"`
 	MaxCost  float64                `json:"max_cost" validate:"required,gt=0"`
+	Stream   bool                   `json:"stream,omitempty"`
+	// FallbackModels are tried in order when the model fails; nil uses the model's default chain
+	FallbackModels []string `json:"fallback_models,omitempty" validate:"omitempty,max=5,dive,required"`
+	// Cache opts a request into the response cache; requests with temperature 0 are cached anyway
+	Cache bool `json:"cache,omitempty"`
 }
 
 type ModelCredentials struct {
-	ModelKey        string          `json:"model_key"`
-	RequestURL      string          `json:"request_url"`
-	ApiKey          string          `json:"api_key"`
+	ModelKey   string `json:"model_key"`
+	RequestURL string `json:"request_url"`
+	ApiKeyID   string `json:"api_key_id"`
+	// SealedKey is opened only when the provider request is signed
+	SealedKey       *EncryptedKey   `json:"-"`
 	TokensAvailable int             `json:"tokens_available"`
 	ProviderName    string          `json:"provider_name"`
 	ProviderConfig  *ProviderConfig `json:"provider_config"`
+	Price           *ModelPrice     `json:"price"`
+	FallbackModels  []string        `json:"fallback_models"`
+	// ResponseCacheTTL is the model's response cache TTL in seconds, nil for the default
+	ResponseCacheTTL *int `json:"response_cache_ttl"`
+	// ModelRateLimit and KeyRateLimit are the limits of the model and of the seller key
+	ModelRateLimit RateLimit `json:"model_rate_limit"`
+	KeyRateLimit   RateLimit `json:"key_rate_limit"`
+	// OptionsSchemaID and ResponseSchemaID reference the model's JSON Schemas, empty when unset
+	OptionsSchemaID  string `json:"options_schema_id"`
+	ResponseSchemaID string `json:"response_schema_id"`
 }
 
 type ProviderConfig struct {
-	AuthType        string            `json:"auth_type"`
-	AuthHeader      string            `json:"auth_header"`
-	ExtraHeaders    map[string]string `json:"extra_headers"`
-	RequestDefaults map[string]any    `json:"request_defaults"`
-	ResponseMapping map[string]string `json:"response_mapping"`
+	AuthType        string                `json:"auth_type" validate:"omitempty,oneof=bearer api_key"`
+	AuthHeader      string                `json:"auth_header" validate:"required_if=AuthType api_key"`
+	ExtraHeaders    map[string]string     `json:"extra_headers"`
+	RequestDefaults map[string]any        `json:"request_defaults"`
+	RequestMapping  map[string]any        `json:"request_mapping,omitempty"`
+	ResponseMapping map[string]string     `json:"response_mapping"`
+	StreamDefaults  map[string]any        `json:"stream_defaults"`
+	StreamMapping   map[string]string     `json:"stream_mapping"`
+	RetryPolicy     *RetryPolicy          `json:"retry_policy,omitempty"`
+	CircuitBreaker  *CircuitBreakerConfig `json:"circuit_breaker,omitempty"`
 }
 
 type GeneralChatResponse struct {
-	ID               string `json:"id"`
-	Model            string `json:"model"`
-	Content          string `json:"content"`
-	Role             string `json:"role"`
-	FinishReason     string `json:"finish_reason"`
-	PromptTokens     int    `json:"prompt_tokens"`
-	CompletionTokens int    `json:"completion_tokens"`
-	TotalTokens      int    `json:"total_tokens"`
+	ID               string  `json:"id"`
+	Model            string  `json:"model"`
+	Content          string  `json:"content"`
+	Role             string  `json:"role"`
+	FinishReason     string  `json:"finish_reason"`
+	PromptTokens     int     `json:"prompt_tokens"`
+	CompletionTokens int     `json:"completion_tokens"`
+	TotalTokens      int     `json:"total_tokens"`
+	CachedTokens     int     `json:"cached_tokens,omitempty"`
+	Cost             float64 `json:"cost,omitempty"`
 }
 
 type Model struct {
-	ID               string     `json:"id"`
-	ModelKey         string     `json:"model_key" validate:"required"`
-	Name             string     `json:"name" validate:"required"`
-	Description      string     `json:"description" validate:"required"`
-	ProviderID       string     `json:"provider_id" validate:"required,uuid"`
-	OptionsSchemaID  string     `json:"options_schema_id" validate:"required,uuid"`
-	ResponseSchemaID string     `json:"response_schema_id" validate:"required,uuid"`
-	RequestURL       string     `json:"request_url" validate:"required,url"`
-	CreatedAt        time.Time  `json:"created_at" db:"created_at"`
-	UpdatedAt        *time.Time `json:"updated_at" db:"updated_at"`
+	ID               string      `json:"id"`
+	ModelKey         string      `json:"model_key" validate:"required"`
+	Name             string      `json:"name" validate:"required"`
+	Description      string      `json:"description" validate:"required"`
+	ProviderID       string      `json:"provider_id" validate:"required,uuid"`
+	OptionsSchemaID  string      `json:"options_schema_id" validate:"required,uuid"`
+	ResponseSchemaID string      `json:"response_schema_id" validate:"required,uuid"`
+	RequestURL       string      `json:"request_url" validate:"required,url"`
+	CreatedAt        time.Time   `json:"created_at" db:"created_at"`
+	UpdatedAt        *time.Time  `json:"updated_at" db:"updated_at"`
+	Price            *ModelPrice `json:"price,omitempty"`
+	FallbackModels   []string    `json:"fallback_models" validate:"omitempty,max=5,dive,required"`
+	// ResponseCacheTTL is how long cacheable responses are cached, in seconds. Nil uses
+	// RESPONSE_CACHE_TTL_SECONDS and 0 disables the response cache for the model.
+	ResponseCacheTTL *int `json:"response_cache_ttl" validate:"omitempty,min=0"`
+	RateLimit
 }
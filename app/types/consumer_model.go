@@ -0,0 +1,32 @@
+package types
+
+import "time"
+
+// Consumer struct to describe a wallet that calls models through agent-c.
+type Consumer struct {
+	ID            string `json:"id"`
+	WalletAddress string `json:"wallet_address" validate:"required,eth_addr"`
+	RateLimit
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt *time.Time `json:"updated_at"`
+}
+
+// ConsumerKey struct to describe an api key agent-c issued to a consumer. Its scopes are the
+// permissions it grants. The key is shown once when it is created; afterwards only its prefix identifies it.
+type ConsumerKey struct {
+	ID         string     `json:"id"`
+	ConsumerID string     `json:"consumer_id"`
+	Name       string     `json:"name" validate:"max=255"`
+	Prefix     string     `json:"prefix"`
+	KeyHash    string     `json:"-"`
+	Scopes     []string   `json:"scopes" validate:"required,min=1,dive,oneof=consume usage:read"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  *time.Time `json:"updated_at"`
+}
+
+// NewConsumerKey struct to describe a newly issued consumer key, including the key itself.
+type NewConsumerKey struct {
+	ConsumerKey
+	Key string `json:"key"`
+}
@@ -0,0 +1,23 @@
+package types
+
+import "time"
+
+// Token reservation statuses
+const (
+	ReservationReserved = "reserved"
+	ReservationSettled  = "settled"
+	ReservationReleased = "released"
+)
+
+// TokenReservation struct to describe tokens held against an api key for an in-flight request.
+type TokenReservation struct {
+	ID             string     `json:"id"`
+	ApiKeyID       string     `json:"api_key_id"`
+	ModelKey       string     `json:"model_key"`
+	ReservedTokens int        `json:"reserved_tokens"`
+	SettledTokens  *int       `json:"settled_tokens"`
+	Status         string     `json:"status"`
+	CreatedAt      time.Time  `json:"created_at"`
+	ExpiresAt      time.Time  `json:"expires_at"`
+	SettledAt      *time.Time `json:"settled_at"`
+}
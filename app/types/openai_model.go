@@ -1,12 +1,27 @@
 package types
 
 // ChatCompletionRequest struct to describe chat completion request object.
+// MaxCost and FallbackModels are agent-c extensions to the OpenAI format.
 type OpenAIChatCompletionRequest struct {
-	Model       string          `json:"model" validate:"required"`
-	Messages    []ChatMessage   `json:"messages" validate:"required,min=1,dive"`
-	Temperature *float32        `json:"temperature,omitempty" validate:"omitempty,min=0,max=2"`
-	MaxTokens   *int            `json:"max_tokens,omitempty" validate:"omitempty,min=1"`
-	Stream      bool            `json:"stream,omitempty"`
+	Model               string               `json:"model" validate:"required"`
+	Messages            []ChatMessage        `json:"messages" validate:"required,min=1,dive"`
+	Temperature         *float32             `json:"temperature,omitempty" validate:"omitempty,min=0,max=2"`
+	TopP                *float32             `json:"top_p,omitempty" validate:"omitempty,min=0,max=1"`
+	MaxTokens           *int                 `json:"max_tokens,omitempty" validate:"omitempty,min=1"`
+	MaxCompletionTokens *int                 `json:"max_completion_tokens,omitempty" validate:"omitempty,min=1"`
+	PresencePenalty     *float32             `json:"presence_penalty,omitempty" validate:"omitempty,min=-2,max=2"`
+	FrequencyPenalty    *float32             `json:"frequency_penalty,omitempty" validate:"omitempty,min=-2,max=2"`
+	Stop                any                  `json:"stop,omitempty"`
+	User                string               `json:"user,omitempty"`
+	Stream              bool                 `json:"stream,omitempty"`
+	StreamOptions       *OpenAIStreamOptions `json:"stream_options,omitempty"`
+	MaxCost             *float64             `json:"max_cost,omitempty" validate:"omitempty,gt=0"`
+	FallbackModels      []string             `json:"fallback_models,omitempty" validate:"omitempty,max=5,dive,required"`
+}
+
+// OpenAIStreamOptions struct to describe stream options object.
+type OpenAIStreamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
 }
 
 // ChatMessage struct to describe chat message object.
@@ -39,3 +54,25 @@ type Usage struct {
 	TotalTokens      int `json:"total_tokens"`
 }
 
+// ChatCompletionChunk struct to describe a streamed chat completion chunk object.
+type ChatCompletionChunk struct {
+	ID      string        `json:"id"`
+	Object  string        `json:"object"`
+	Created int64         `json:"created"`
+	Model   string        `json:"model"`
+	Choices []ChunkChoice `json:"choices"`
+	Usage   *Usage        `json:"usage,omitempty"`
+}
+
+// ChunkChoice struct to describe a streamed choice object.
+type ChunkChoice struct {
+	Index        int        `json:"index"`
+	Delta        ChunkDelta `json:"delta"`
+	FinishReason *string    `json:"finish_reason"`
+}
+
+// ChunkDelta struct to describe the message delta of a streamed choice.
+type ChunkDelta struct {
+	Role    string `json:"role,omitempty"`
+	Content string `json:"content,omitempty"`
+}
@@ -0,0 +1,12 @@
+package types
+
+import "time"
+
+// ModelPrice struct to describe a model's token prices, in currency units per million tokens.
+type ModelPrice struct {
+	Currency         string    `json:"currency" validate:"required,len=3"`
+	InputPrice       float64   `json:"input_price" validate:"gte=0"`
+	OutputPrice      float64   `json:"output_price" validate:"gte=0"`
+	CachedInputPrice *float64  `json:"cached_input_price,omitempty" validate:"omitempty,gte=0"`
+	EffectiveFrom    time.Time `json:"effective_from"`
+}
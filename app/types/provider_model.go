@@ -0,0 +1,65 @@
+package types
+
+import "time"
+
+// Provider struct to describe a model provider and how requests to it are shaped.
+type Provider struct {
+	ID          string `json:"id"`
+	Name        string `json:"name" validate:"required"`
+	Description string `json:"description" validate:"required"`
+	EndpointURL string `json:"endpoint_url" validate:"required,url"`
+	ProviderConfig
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt *time.Time `json:"updated_at"`
+}
+
+// RetryPolicy struct to describe how transient provider errors are retried.
+// Zero fields fall back to the service defaults.
+type RetryPolicy struct {
+	MaxAttempts   int   `json:"max_attempts"`
+	BaseDelayMs   int   `json:"base_delay_ms"`
+	MaxDelayMs    int   `json:"max_delay_ms"`
+	RetryStatuses []int `json:"retry_statuses"`
+}
+
+// Circuit breaker states
+const (
+	CircuitClosed   = "closed"
+	CircuitOpen     = "open"
+	CircuitHalfOpen = "half_open"
+)
+
+// CircuitBreakerConfig struct to describe when a provider's circuit opens and how it recovers.
+// Zero fields fall back to the service defaults.
+type CircuitBreakerConfig struct {
+	FailureThreshold int `json:"failure_threshold"`
+	OpenDurationMs   int `json:"open_duration_ms"`
+	HalfOpenRequests int `json:"half_open_requests"`
+	WindowSize       int `json:"window_size"`
+}
+
+// ProviderHealth struct to describe a provider's circuit state and its recent calls.
+type ProviderHealth struct {
+	Provider            string     `json:"provider"`
+	State               string     `json:"state"`
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+	RecentRequests      int        `json:"recent_requests"`
+	ErrorRate           float64    `json:"error_rate"`
+	AvgLatencyMs        int64      `json:"avg_latency_ms"`
+	P95LatencyMs        int64      `json:"p95_latency_ms"`
+	OpenedAt            *time.Time `json:"opened_at,omitempty"`
+	// SchemaDrift counts responses that did not match their model's response schema since startup
+	SchemaDrift int `json:"schema_drift"`
+}
+
+// CredentialsCacheStats reports how often model credentials are served from memory
+// instead of Postgres since the service started
+type CredentialsCacheStats struct {
+	Entries  int     `json:"entries"`
+	Capacity int     `json:"capacity"`
+	Hits     uint64  `json:"hits"`
+	Misses   uint64  `json:"misses"`
+	HitRate  float64 `json:"hit_rate"`
+	// Invalidations counts admin changes, from any instance, that dropped the cache
+	Invalidations uint64 `json:"invalidations"`
+}
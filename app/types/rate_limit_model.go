@@ -0,0 +1,32 @@
+package types
+
+import "time"
+
+// What a rate limit applies to
+const (
+	RateLimitScopeConsumer = "consumer"
+	RateLimitScopeModel    = "model"
+	RateLimitScopeApiKey   = "api_key"
+)
+
+// What a rate limit counts
+const (
+	RateLimitRequests = "requests"
+	RateLimitTokens   = "tokens"
+)
+
+// RateLimit struct to describe the per-minute limits of a consumer, model or seller api key.
+// Nil limits are unlimited.
+type RateLimit struct {
+	RequestsPerMinute *int `json:"requests_per_minute" validate:"omitempty,min=1"`
+	TokensPerMinute   *int `json:"tokens_per_minute" validate:"omitempty,min=1"`
+}
+
+// RateLimitStatus struct to describe a rate limit check, reported in RateLimit-* headers.
+type RateLimitStatus struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// Reset is how long until the current window ends
+	Reset time.Duration
+}
@@ -0,0 +1,22 @@
+package types
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Model schema types
+const (
+	SchemaTypeOptions  = "options"
+	SchemaTypeResponse = "response"
+)
+
+// ModelSchema struct to describe a JSON Schema for model options or provider responses.
+type ModelSchema struct {
+	ID        string          `json:"id"`
+	Type      string          `json:"type" validate:"required,oneof=options response"`
+	Name      string          `json:"name" validate:"required"`
+	Schema    json.RawMessage `json:"schema" validate:"required"`
+	CreatedAt time.Time       `json:"created_at"`
+	UpdatedAt *time.Time      `json:"updated_at"`
+}
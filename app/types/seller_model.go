@@ -0,0 +1,47 @@
+package types
+
+import "time"
+
+// Seller struct to describe a wallet that supplies provider api keys.
+type Seller struct {
+	ID            string     `json:"id"`
+	WalletAddress string     `json:"wallet_address" validate:"required,eth_addr"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     *time.Time `json:"updated_at"`
+}
+
+// ApiKey struct to describe a seller's provider api key and the tokens it still offers.
+// The key itself is write-only: it is stored encrypted and only its fingerprint and
+// last four characters are ever returned.
+type ApiKey struct {
+	ID              string        `json:"id"`
+	ApiKey          string        `json:"api_key,omitempty"`
+	Sealed          *EncryptedKey `json:"-"`
+	Fingerprint     string        `json:"fingerprint"`
+	Last4           string        `json:"last4"`
+	TokensAvailable int           `json:"tokens_available" validate:"gte=0"`
+	ProviderID      string        `json:"provider_id" validate:"required,uuid"`
+	SellerID        string        `json:"seller_id" validate:"required,uuid"`
+	RateLimit
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt *time.Time `json:"updated_at"`
+}
+
+// EncryptedKey struct to describe an api key sealed with envelope encryption. Ciphertext
+// is sealed with a random data key, and WrappedKey is that data key sealed with the master
+// key MasterKeyID.
+type EncryptedKey struct {
+	Ciphertext  []byte
+	WrappedKey  []byte
+	MasterKeyID string
+}
+
+// StoredApiKey struct to describe how an api key is stored, for re-encryption.
+// Plaintext is only set on keys stored before encryption at rest.
+type StoredApiKey struct {
+	ID          string
+	Plaintext   *string
+	Sealed      *EncryptedKey
+	Fingerprint string
+	Last4       string
+}
@@ -0,0 +1,20 @@
+package types
+
+import "time"
+
+// Session struct to describe a signed-in wallet's refresh token family. Each refresh
+// rotates the token while the session keeps its id.
+type Session struct {
+	ID         string    `json:"id"`
+	Wallet     string    `json:"wallet"`
+	UserAgent  string    `json:"user_agent"`
+	IP         string    `json:"ip"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+}
+
+// RefreshRequest struct to describe a request carrying a refresh token.
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" validate:"required"`
+}
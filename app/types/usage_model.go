@@ -0,0 +1,55 @@
+package types
+
+import "time"
+
+// UsageRecord struct to describe a single ConsumeModel call attributed to a consumer.
+type UsageRecord struct {
+	ID               string    `json:"id"`
+	ConsumerID       *string   `json:"consumer_id"`
+	ModelKey         string    `json:"model_key"`
+	ApiKeyID         *string   `json:"api_key_id"`
+	PromptTokens     int       `json:"prompt_tokens"`
+	CompletionTokens int       `json:"completion_tokens"`
+	TotalTokens      int       `json:"total_tokens"`
+	Cost             float64   `json:"cost"`
+	Currency         *string   `json:"currency"`
+	LatencyMs        int64     `json:"latency_ms"`
+	ProviderStatus   int       `json:"provider_status"`
+	Stream           bool      `json:"stream"`
+	CreatedAt        time.Time `json:"created_at"`
+}
+
+// UsageQuery struct to describe the query string accepted by the usage endpoints.
+type UsageQuery struct {
+	From       string `query:"from"`
+	To         string `query:"to"`
+	ModelKey   string `query:"model_key"`
+	ConsumerID string `query:"consumer_id" validate:"omitempty,uuid"`
+	ApiKeyID   string `query:"api_key_id" validate:"omitempty,uuid"`
+	Limit      int    `query:"limit" validate:"omitempty,min=1,max=1000"`
+	Offset     int    `query:"offset" validate:"omitempty,min=0"`
+}
+
+// UsageFilter struct to describe the parsed filters applied to usage queries.
+type UsageFilter struct {
+	From       *time.Time
+	To         *time.Time
+	ModelKey   string
+	ConsumerID string
+	ApiKeyID   string
+	Limit      int
+	Offset     int
+}
+
+// DailyUsage struct to describe usage aggregated per day, model and consumer.
+type DailyUsage struct {
+	Day              time.Time `json:"day"`
+	ModelKey         string    `json:"model_key"`
+	ConsumerID       *string   `json:"consumer_id"`
+	Requests         int       `json:"requests"`
+	PromptTokens     int       `json:"prompt_tokens"`
+	CompletionTokens int       `json:"completion_tokens"`
+	TotalTokens      int       `json:"total_tokens"`
+	Cost             float64   `json:"cost"`
+	Currency         *string   `json:"currency"`
+}
@@ -0,0 +1,37 @@
+package utils
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// consumerKeyPrefix marks api keys issued by agent-c
+const consumerKeyPrefix = "agc"
+
+// GenerateConsumerKey creates a consumer api key of the form agc_<prefix>_<secret>. The prefix
+// is stored in clear to look the key up; only a hash of the secret is stored.
+func GenerateConsumerKey() (key, prefix, secret string, err error) {
+	prefixBytes := make([]byte, 6)
+	secretBytes := make([]byte, 24)
+	if _, err := rand.Read(prefixBytes); err != nil {
+		return "", "", "", fmt.Errorf("failed to generate key prefix: %w", err)
+	}
+	if _, err := rand.Read(secretBytes); err != nil {
+		return "", "", "", fmt.Errorf("failed to generate key secret: %w", err)
+	}
+
+	prefix = hex.EncodeToString(prefixBytes)
+	secret = hex.EncodeToString(secretBytes)
+	return consumerKeyPrefix + "_" + prefix + "_" + secret, prefix, secret, nil
+}
+
+// ParseConsumerKey splits a consumer api key into its prefix and secret.
+func ParseConsumerKey(key string) (prefix, secret string, ok bool) {
+	parts := strings.Split(key, "_")
+	if len(parts) != 3 || parts[0] != consumerKeyPrefix || parts[1] == "" || parts[2] == "" {
+		return "", "", false
+	}
+	return parts[1], parts[2], true
+}
@@ -1,8 +1,9 @@
 package utils
 
 import (
-	"crypto/sha256"
+	"crypto/rand"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"os"
 	"strconv"
@@ -18,17 +19,26 @@ type Tokens struct {
 	Refresh string
 }
 
+// TokenSubject struct to describe who tokens are issued to.
+type TokenSubject struct {
+	Wallet      string
+	Roles       []string
+	Permissions []string
+	SellerID    string
+	ConsumerID  string
+}
+
 // GenerateNewTokens func for generate a new Access & Refresh tokens.
-func GenerateNewTokens(id string, credentials []string) (*Tokens, error) {
+func GenerateNewTokens(keys *JWTKeySet, subject *TokenSubject) (*Tokens, error) {
 	// Generate JWT Access token.
-	accessToken, err := generateNewAccessToken(id, credentials)
+	accessToken, err := GenerateNewAccessToken(keys, subject)
 	if err != nil {
 		// Return token generation error.
 		return nil, err
 	}
 
 	// Generate JWT Refresh token.
-	refreshToken, err := generateNewRefreshToken()
+	refreshToken, err := GenerateNewRefreshToken()
 	if err != nil {
 		// Return token generation error.
 		return nil, err
@@ -40,10 +50,8 @@ func GenerateNewTokens(id string, credentials []string) (*Tokens, error) {
 	}, nil
 }
 
-func generateNewAccessToken(id string, credentials []string) (string, error) {
-	// Set secret key from .env file.
-	secret := os.Getenv("JWT_SECRET_KEY")
-
+// GenerateNewAccessToken func for generate a new JWT Access token, signed with the current key of keys.
+func GenerateNewAccessToken(keys *JWTKeySet, subject *TokenSubject) (string, error) {
 	// Set expires minutes count for secret key from .env file.
 	minutesCount, _ := strconv.Atoi(os.Getenv("JWT_SECRET_KEY_EXPIRE_MINUTES_COUNT"))
 
@@ -51,22 +59,21 @@ func generateNewAccessToken(id string, credentials []string) (string, error) {
 	claims := jwt.MapClaims{}
 
 	// Set public claims:
-	claims["id"] = id
+	claims["id"] = subject.Wallet
 	claims["exp"] = time.Now().Add(time.Minute * time.Duration(minutesCount)).Unix()
-	claims["book:create"] = false
-	claims["book:update"] = false
-	claims["book:delete"] = false
 
-	// Set private token credentials:
-	for _, credential := range credentials {
-		claims[credential] = true
+	// Set private token roles and the permissions they grant, with the seller and consumer rows they refer to:
+	claims["roles"] = subject.Roles
+	claims["permissions"] = subject.Permissions
+	if subject.SellerID != "" {
+		claims["seller_id"] = subject.SellerID
+	}
+	if subject.ConsumerID != "" {
+		claims["consumer_id"] = subject.ConsumerID
 	}
 
-	// Create a new JWT access token with claims.
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-
-	// Generate token.
-	t, err := token.SignedString([]byte(secret))
+	// Sign a new JWT access token with claims.
+	t, err := keys.Sign(claims)
 	if err != nil {
 		// Return error, it JWT token generation failed.
 		return "", err
@@ -75,16 +82,12 @@ func generateNewAccessToken(id string, credentials []string) (string, error) {
 	return t, nil
 }
 
-func generateNewRefreshToken() (string, error) {
-	// Create a new SHA256 hash.
-	hash := sha256.New()
-
-	// Create a new now date and time string with salt.
-	refresh := os.Getenv("JWT_REFRESH_KEY") + time.Now().String()
-
-	// See: https://pkg.go.dev/io#Writer.Write
-	_, err := hash.Write([]byte(refresh))
-	if err != nil {
+// GenerateNewRefreshToken func for generate a new opaque Refresh token.
+// Refresh tokens are only valid while their session is stored.
+func GenerateNewRefreshToken() (string, error) {
+	// Create a new random secret.
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
 		// Return error, it refresh token generation failed.
 		return "", err
 	}
@@ -95,13 +98,17 @@ func generateNewRefreshToken() (string, error) {
 	// Set expiration time.
 	expireTime := fmt.Sprint(time.Now().Add(time.Hour * time.Duration(hoursCount)).Unix())
 
-	// Create a new refresh token (sha256 string with salt + expire time).
-	t := hex.EncodeToString(hash.Sum(nil)) + "." + expireTime
+	// Create a new refresh token (random hex string + expire time).
+	t := hex.EncodeToString(secret) + "." + expireTime
 
 	return t, nil
 }
 
-// ParseRefreshToken func for parse second argument from refresh token.
+// ParseRefreshToken func for parse the expire time from refresh token.
 func ParseRefreshToken(refreshToken string) (int64, error) {
-	return strconv.ParseInt(strings.Split(refreshToken, ".")[1], 0, 64)
+	_, expireTime, ok := strings.Cut(refreshToken, ".")
+	if !ok {
+		return 0, errors.New("malformed refresh token")
+	}
+	return strconv.ParseInt(expireTime, 10, 64)
 }
@@ -0,0 +1,192 @@
+package utils
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/wmbryce/agent-c/app/types"
+)
+
+// minRSAKeyBits is the smallest RSA key accepted for signing tokens
+const minRSAKeyBits = 2048
+
+// JWTKeySet signs access tokens with the current private key and verifies them with any active
+// key, selected by the token's kid header. Keys are RSA (RS256) or Ed25519 (EdDSA); their public
+// halves are published as a JWKS so other services can verify agent-c tokens.
+type JWTKeySet struct {
+	currentID string
+	keys      map[string]*jwtKey
+}
+
+type jwtKey struct {
+	method  jwt.SigningMethod
+	private crypto.Signer
+}
+
+// NewJWTKeySet parses PEM encoded private keys by kid. The current key signs new tokens; when
+// currentID is empty the last kid in lexical order is current, so date named keys rotate in.
+func NewJWTKeySet(currentID string, pemKeys map[string][]byte) (*JWTKeySet, error) {
+	set := &JWTKeySet{currentID: currentID, keys: map[string]*jwtKey{}}
+	for kid, contents := range pemKeys {
+		key, err := parseJWTKey(contents)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse jwt key %s: %w", kid, err)
+		}
+		set.keys[kid] = key
+	}
+
+	if len(set.keys) == 0 {
+		return nil, errors.New("no jwt signing key configured")
+	}
+	if set.currentID == "" {
+		kids := set.kids()
+		set.currentID = kids[len(kids)-1]
+	}
+	if _, ok := set.keys[set.currentID]; !ok {
+		return nil, fmt.Errorf("current jwt key %s is not loaded", set.currentID)
+	}
+	return set, nil
+}
+
+// LoadJWTKeySet loads every <kid>.pem private key in dir.
+func LoadJWTKeySet(dir, currentID string) (*JWTKeySet, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list jwt keys: %w", err)
+	}
+
+	pemKeys := map[string][]byte{}
+	for _, path := range paths {
+		contents, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read jwt key: %w", err)
+		}
+		pemKeys[strings.TrimSuffix(filepath.Base(path), ".pem")] = contents
+	}
+	return NewJWTKeySet(currentID, pemKeys)
+}
+
+// JWTKeySetFromEnv loads the keys in JWT_SIGNING_KEYS_DIR, signing with JWT_SIGNING_KEY_ID.
+func JWTKeySetFromEnv() (*JWTKeySet, error) {
+	dir := os.Getenv("JWT_SIGNING_KEYS_DIR")
+	if dir == "" {
+		return nil, errors.New("JWT_SIGNING_KEYS_DIR is not set")
+	}
+	return LoadJWTKeySet(dir, os.Getenv("JWT_SIGNING_KEY_ID"))
+}
+
+// CurrentKeyID returns the kid of the key that signs new tokens.
+func (k *JWTKeySet) CurrentKeyID() string {
+	return k.currentID
+}
+
+// Sign signs claims with the current key and sets its kid header.
+func (k *JWTKeySet) Sign(claims jwt.Claims) (string, error) {
+	if k == nil {
+		return "", errors.New("no jwt signing key configured")
+	}
+
+	key := k.keys[k.currentID]
+	token := jwt.NewWithClaims(key.method, claims)
+	token.Header["kid"] = k.currentID
+	return token.SignedString(key.private)
+}
+
+// Keyfunc returns the public key named by the token's kid header, for jwt.Parse and the JWT
+// middleware. Tokens signed with another algorithm than the key's are rejected.
+func (k *JWTKeySet) Keyfunc(token *jwt.Token) (interface{}, error) {
+	if k == nil {
+		return nil, errors.New("no jwt signing key configured")
+	}
+
+	kid, _ := token.Header["kid"].(string)
+	key, ok := k.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown jwt key %q", kid)
+	}
+	if token.Method.Alg() != key.method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method %s for jwt key %s", token.Method.Alg(), kid)
+	}
+	return key.private.Public(), nil
+}
+
+// JWKS returns the public keys as a JSON Web Key Set.
+func (k *JWTKeySet) JWKS() *types.JWKS {
+	jwks := &types.JWKS{Keys: []types.JWK{}}
+	if k == nil {
+		return jwks
+	}
+
+	for _, kid := range k.kids() {
+		key := k.keys[kid]
+		jwk := types.JWK{Kid: kid, Alg: key.method.Alg(), Use: "sig"}
+		switch public := key.private.Public().(type) {
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(public.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.Kty = "OKP"
+			jwk.Crv = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(public)
+		}
+		jwks.Keys = append(jwks.Keys, jwk)
+	}
+	return jwks
+}
+
+// kids returns the key ids in lexical order
+func (k *JWTKeySet) kids() []string {
+	kids := make([]string, 0, len(k.keys))
+	for kid := range k.keys {
+		kids = append(kids, kid)
+	}
+	sort.Strings(kids)
+	return kids
+}
+
+// parseJWTKey parses a PKCS#8 RSA or Ed25519 private key, or a PKCS#1 RSA private key
+func parseJWTKey(contents []byte) (*jwtKey, error) {
+	block, _ := pem.Decode(contents)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+
+	var private any
+	var err error
+	switch block.Type {
+	case "PRIVATE KEY":
+		private, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		private, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block %s", block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	switch private := private.(type) {
+	case *rsa.PrivateKey:
+		if private.N.BitLen() < minRSAKeyBits {
+			return nil, fmt.Errorf("RSA key must be at least %d bits", minRSAKeyBits)
+		}
+		return &jwtKey{method: jwt.SigningMethodRS256, private: private}, nil
+	case ed25519.PrivateKey:
+		return &jwtKey{method: jwt.SigningMethodEdDSA, private: private}, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %T", private)
+	}
+}
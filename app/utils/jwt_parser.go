@@ -1,55 +1,91 @@
 package utils
 
 import (
-	"os"
+	"errors"
+	"slices"
 	"strings"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/golang-jwt/jwt/v5"
-	"github.com/google/uuid"
 )
 
 // TokenMetadata struct to describe metadata in JWT.
 type TokenMetadata struct {
-	UserID      uuid.UUID
-	Credentials map[string]bool
+	Wallet      string
+	Roles       []string
+	Permissions []string
+	SellerID    string
+	ConsumerID  string
 	Expires     int64
 }
 
-// ExtractTokenMetadata func to extract metadata from JWT.
-func ExtractTokenMetadata(c *fiber.Ctx) (*TokenMetadata, error) {
-	token, err := verifyToken(c)
+// HasRole reports whether the token carries the role.
+func (m *TokenMetadata) HasRole(role string) bool {
+	return slices.Contains(m.Roles, role)
+}
+
+// HasPermission reports whether the token grants the permission.
+func (m *TokenMetadata) HasPermission(permission string) bool {
+	return slices.Contains(m.Permissions, permission)
+}
+
+// ExtractTokenMetadata func to extract metadata from a JWT verified with keys.
+func ExtractTokenMetadata(c *fiber.Ctx, keys *JWTKeySet) (*TokenMetadata, error) {
+	token, err := verifyToken(c, keys)
 	if err != nil {
 		return nil, err
 	}
 
-	// Setting and checking token and credentials.
-	claims, ok := token.Claims.(jwt.MapClaims)
-	if ok && token.Valid {
-		// User ID.
-		userID, err := uuid.Parse(claims["id"].(string))
-		if err != nil {
-			return nil, err
-		}
+	if !token.Valid {
+		return nil, errors.New("token is not valid")
+	}
 
-		// Expires time.
-		expires := int64(claims["exp"].(float64))
+	return TokenMetadataFromToken(token)
+}
 
-		// User credentials.
-		credentials := map[string]bool{
-			"book:create": claims["book:create"].(bool),
-			"book:update": claims["book:update"].(bool),
-			"book:delete": claims["book:delete"].(bool),
-		}
+// TokenMetadataFromToken func to read metadata from a verified JWT.
+func TokenMetadataFromToken(token *jwt.Token) (*TokenMetadata, error) {
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return nil, errors.New("unexpected token claims")
+	}
 
-		return &TokenMetadata{
-			UserID:      userID,
-			Credentials: credentials,
-			Expires:     expires,
-		}, nil
+	// Wallet address.
+	wallet, _ := claims["id"].(string)
+	if wallet == "" {
+		return nil, errors.New("token has no id")
 	}
 
-	return nil, err
+	// Expires time.
+	expires, _ := claims["exp"].(float64)
+
+	// Roles and permissions.
+	roles := stringsClaim(claims, "roles")
+	permissions := stringsClaim(claims, "permissions")
+
+	sellerID, _ := claims["seller_id"].(string)
+	consumerID, _ := claims["consumer_id"].(string)
+
+	return &TokenMetadata{
+		Wallet:      wallet,
+		Roles:       roles,
+		Permissions: permissions,
+		SellerID:    sellerID,
+		ConsumerID:  consumerID,
+		Expires:     int64(expires),
+	}, nil
+}
+
+// stringsClaim reads a claim holding a list of strings
+func stringsClaim(claims jwt.MapClaims, name string) []string {
+	raw, _ := claims[name].([]interface{})
+	values := make([]string, 0, len(raw))
+	for _, value := range raw {
+		if value, ok := value.(string); ok {
+			values = append(values, value)
+		}
+	}
+	return values
 }
 
 func extractToken(c *fiber.Ctx) string {
@@ -64,17 +100,13 @@ func extractToken(c *fiber.Ctx) string {
 	return ""
 }
 
-func verifyToken(c *fiber.Ctx) (*jwt.Token, error) {
+func verifyToken(c *fiber.Ctx, keys *JWTKeySet) (*jwt.Token, error) {
 	tokenString := extractToken(c)
 
-	token, err := jwt.Parse(tokenString, jwtKeyFunc)
+	token, err := jwt.Parse(tokenString, keys.Keyfunc)
 	if err != nil {
 		return nil, err
 	}
 
 	return token, nil
 }
-
-func jwtKeyFunc(token *jwt.Token) (interface{}, error) {
-	return []byte(os.Getenv("JWT_SECRET_KEY")), nil
-}
@@ -0,0 +1,207 @@
+package utils
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/wmbryce/agent-c/app/types"
+)
+
+// ErrKeyDecryption is returned when a sealed api key cannot be opened.
+var ErrKeyDecryption = errors.New("failed to decrypt api key")
+
+// KeyRing seals seller api keys with envelope encryption. Each key is encrypted with its own
+// random AES-256-GCM data key, and the data key is encrypted with the current master key.
+// Older master keys stay in the ring so keys sealed before a rotation can still be opened.
+type KeyRing struct {
+	currentID string
+	keys      map[string][]byte
+}
+
+// NewKeyRing parses master keys given as comma separated "id:base64key" entries of
+// 32-byte keys. The first entry is the current key used to seal new data keys.
+func NewKeyRing(spec string) (*KeyRing, error) {
+	ring := &KeyRing{keys: map[string][]byte{}}
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		id, encoded, ok := strings.Cut(entry, ":")
+		if !ok || id == "" {
+			return nil, fmt.Errorf("master key entry must be id:base64key")
+		}
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode master key %s: %w", id, err)
+		}
+		if len(key) != 32 {
+			return nil, fmt.Errorf("master key %s must be 32 bytes, got %d", id, len(key))
+		}
+
+		if ring.currentID == "" {
+			ring.currentID = id
+		}
+		ring.keys[id] = key
+	}
+
+	if ring.currentID == "" {
+		return nil, errors.New("no master key configured")
+	}
+	return ring, nil
+}
+
+// KeyRingFromEnv loads the master keys from API_KEY_MASTER_KEYS_FILE, a local stand-in
+// for a KMS, or else from API_KEY_MASTER_KEYS.
+func KeyRingFromEnv() (*KeyRing, error) {
+	spec := os.Getenv("API_KEY_MASTER_KEYS")
+	if path := os.Getenv("API_KEY_MASTER_KEYS_FILE"); path != "" {
+		contents, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read master keys file: %w", err)
+		}
+		spec = strings.ReplaceAll(string(contents), "\n", ",")
+	}
+	return NewKeyRing(spec)
+}
+
+// CurrentKeyID returns the id of the master key that seals new data keys.
+func (k *KeyRing) CurrentKeyID() string {
+	return k.currentID
+}
+
+// Seal encrypts an api key under a new data key wrapped by the current master key.
+func (k *KeyRing) Seal(plaintext string) (*types.EncryptedKey, error) {
+	if k == nil {
+		return nil, errors.New("no master key configured")
+	}
+
+	dataKey := make([]byte, 32)
+	if _, err := rand.Read(dataKey); err != nil {
+		return nil, fmt.Errorf("failed to generate data key: %w", err)
+	}
+
+	ciphertext, err := seal(dataKey, []byte(plaintext), nil)
+	if err != nil {
+		return nil, err
+	}
+	wrapped, err := seal(k.keys[k.currentID], dataKey, []byte(k.currentID))
+	if err != nil {
+		return nil, err
+	}
+
+	return &types.EncryptedKey{
+		Ciphertext:  ciphertext,
+		WrappedKey:  wrapped,
+		MasterKeyID: k.currentID,
+	}, nil
+}
+
+// Open decrypts a sealed api key. Call it only where the key is about to be used.
+func (k *KeyRing) Open(sealed *types.EncryptedKey) (string, error) {
+	dataKey, err := k.unwrap(sealed)
+	if err != nil {
+		return "", err
+	}
+	plaintext, err := open(dataKey, sealed.Ciphertext, nil)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrKeyDecryption, err)
+	}
+	return string(plaintext), nil
+}
+
+// Rewrap seals the data key of an api key under the current master key, leaving the
+// key's ciphertext unchanged. It reports whether anything changed.
+func (k *KeyRing) Rewrap(sealed *types.EncryptedKey) (bool, error) {
+	if sealed.MasterKeyID == k.currentID {
+		return false, nil
+	}
+
+	dataKey, err := k.unwrap(sealed)
+	if err != nil {
+		return false, err
+	}
+	wrapped, err := seal(k.keys[k.currentID], dataKey, []byte(k.currentID))
+	if err != nil {
+		return false, err
+	}
+
+	sealed.WrappedKey = wrapped
+	sealed.MasterKeyID = k.currentID
+	return true, nil
+}
+
+func (k *KeyRing) unwrap(sealed *types.EncryptedKey) ([]byte, error) {
+	if k == nil {
+		return nil, fmt.Errorf("%w: no master key configured", ErrKeyDecryption)
+	}
+	if sealed == nil {
+		return nil, fmt.Errorf("%w: api key is not encrypted", ErrKeyDecryption)
+	}
+	masterKey, ok := k.keys[sealed.MasterKeyID]
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown master key %s", ErrKeyDecryption, sealed.MasterKeyID)
+	}
+
+	dataKey, err := open(masterKey, sealed.WrappedKey, []byte(sealed.MasterKeyID))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrKeyDecryption, err)
+	}
+	return dataKey, nil
+}
+
+// seal encrypts with AES-GCM, prefixing the random nonce to the ciphertext
+func seal(key, plaintext, additionalData []byte) ([]byte, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	return aead.Seal(nonce, nonce, plaintext, additionalData), nil
+}
+
+func open(key, sealed, additionalData []byte) ([]byte, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < aead.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	return aead.Open(nil, nonce, ciphertext, additionalData)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// KeyFingerprint identifies an api key without revealing it.
+func KeyFingerprint(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:8])
+}
+
+// KeyLast4 returns the last four characters of an api key for display.
+func KeyLast4(key string) string {
+	if len(key) <= 4 {
+		return key
+	}
+	return key[len(key)-4:]
+}
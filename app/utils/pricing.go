@@ -0,0 +1,77 @@
+package utils
+
+import (
+	"github.com/wmbryce/agent-c/app/types"
+)
+
+// tokensPerPriceUnit is the number of tokens each ModelPrice price applies to.
+const tokensPerPriceUnit = 1_000_000
+
+// Rough token estimation constants, used before the provider reports real usage.
+const (
+	charsPerToken          = 4
+	tokensPerMessage       = 4
+	tokensPerConversation  = 3
+	DefaultMaxOutputTokens = 4096
+)
+
+// CalculateCost prices the token usage reported in a provider response.
+// Cached prompt tokens, which PromptTokens includes, are billed at the cached input price
+// when the model has one.
+func CalculateCost(price *types.ModelPrice, response *types.GeneralChatResponse) float64 {
+	if price == nil || response == nil {
+		return 0
+	}
+
+	cached := min(response.CachedTokens, response.PromptTokens)
+	uncached := response.PromptTokens - cached
+
+	cachedPrice := price.InputPrice
+	if price.CachedInputPrice != nil {
+		cachedPrice = *price.CachedInputPrice
+	}
+
+	cost := float64(uncached)*price.InputPrice +
+		float64(cached)*cachedPrice +
+		float64(response.CompletionTokens)*price.OutputPrice
+
+	return cost / tokensPerPriceUnit
+}
+
+// EstimateCost returns the most a request can cost if the provider uses all maxOutputTokens.
+func EstimateCost(price *types.ModelPrice, promptTokens, maxOutputTokens int) float64 {
+	return CalculateCost(price, &types.GeneralChatResponse{
+		PromptTokens:     promptTokens,
+		CompletionTokens: maxOutputTokens,
+	})
+}
+
+// EstimatePromptTokens approximates the prompt token count of a conversation.
+// It deliberately errs on the high side so pre-flight checks do not under-reserve.
+func EstimatePromptTokens(messages []types.ChatMessage) int {
+	tokens := tokensPerConversation
+	for _, message := range messages {
+		tokens += tokensPerMessage
+		tokens += (len(message.Role) + len(message.Content) + charsPerToken - 1) / charsPerToken
+	}
+	return tokens
+}
+
+// EstimateTextTokens approximates the token count of generated text, such as the part of a
+// completion relayed before its usage was reported.
+func EstimateTextTokens(text string) int {
+	return (len(text) + charsPerToken - 1) / charsPerToken
+}
+
+// MaxOutputTokens returns the completion token limit that applies to a request,
+// taken from the request options, then the provider defaults, then DefaultMaxOutputTokens.
+func MaxOutputTokens(options map[string]interface{}, defaults map[string]any) int {
+	for _, source := range []map[string]any{options, defaults} {
+		for _, key := range []string{"max_tokens", "max_completion_tokens"} {
+			if v := toInt(source[key]); v > 0 {
+				return v
+			}
+		}
+	}
+	return DefaultMaxOutputTokens
+}
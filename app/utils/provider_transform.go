@@ -2,7 +2,10 @@ package utils
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
+	"regexp"
+	"strings"
 
 	"github.com/ohler55/ojg/jp"
 	"github.com/ohler55/ojg/oj"
@@ -47,7 +50,213 @@ func ApplyRequestDefaults(request map[string]interface{}, defaults map[string]an
 	}
 }
 
-// TransformResponse uses JSONPath mappings to transform a provider response into GeneralChatResponse
+// requestPlaceholder matches a template string that is exactly one {{name}} placeholder
+var requestPlaceholder = regexp.MustCompile(`^\{\{\s*([a-z_]+(?:\.[A-Za-z0-9_]+)?)\s*\}\}$`)
+
+// requestSpreadKey is the template key whose {{options}} value spreads every option
+// not placed elsewhere in the template into the enclosing object
+const requestSpreadKey = "*"
+
+// TransformRequest renders a provider request template for a normalized request.
+//
+// Template strings that are a single placeholder are replaced by its value, keeping
+// the value's JSON type. Placeholders without a value are omitted, as are objects and
+// arrays left empty by omission. The placeholders are:
+//
+//	{{model}}          the model key
+//	{{messages}}       all messages as {role, content}
+//	{{chat}}           the non-system messages as {role, content}
+//	{{system}}         the system messages joined into one string
+//	{{contents}}       the non-system messages as Gemini {role: user|model, parts: [{text}]}
+//	{{prompt}}         the conversation as a plain-completion prompt
+//	{{stream}}         true for streaming requests
+//	{{options.<name>}} a single request option
+//	{{options}}        all request options; under the "*" key, those not placed elsewhere
+func TransformRequest(template map[string]any, request *types.ConsumeModelRequest, modelKey string) (map[string]interface{}, error) {
+	r := &requestRenderer{
+		request:  request,
+		modelKey: modelKey,
+		placed:   map[string]bool{},
+	}
+	r.collectPlacedOptions(template)
+
+	rendered, ok, err := r.render(template)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return map[string]interface{}{}, nil
+	}
+	return rendered.(map[string]interface{}), nil
+}
+
+type requestRenderer struct {
+	request  *types.ConsumeModelRequest
+	modelKey string
+	// placed holds the options a template places explicitly, which the spread skips
+	placed map[string]bool
+}
+
+func (r *requestRenderer) collectPlacedOptions(node any) {
+	switch v := node.(type) {
+	case string:
+		if m := requestPlaceholder.FindStringSubmatch(v); m != nil {
+			if option, ok := strings.CutPrefix(m[1], "options."); ok {
+				r.placed[option] = true
+			}
+		}
+	case map[string]any:
+		for _, child := range v {
+			r.collectPlacedOptions(child)
+		}
+	case []any:
+		for _, child := range v {
+			r.collectPlacedOptions(child)
+		}
+	}
+}
+
+// render returns the rendered node, or false when the node is omitted
+func (r *requestRenderer) render(node any) (any, bool, error) {
+	switch v := node.(type) {
+	case string:
+		m := requestPlaceholder.FindStringSubmatch(v)
+		if m == nil {
+			return v, true, nil
+		}
+		return r.lookup(m[1])
+
+	case map[string]any:
+		out := make(map[string]interface{}, len(v))
+		for key, child := range v {
+			if key == requestSpreadKey {
+				continue
+			}
+			rendered, ok, err := r.render(child)
+			if err != nil {
+				return nil, false, err
+			}
+			if ok {
+				out[key] = rendered
+			}
+		}
+		if spread, ok := v[requestSpreadKey]; ok {
+			if err := r.spread(out, spread); err != nil {
+				return nil, false, err
+			}
+		}
+		return out, len(out) > 0 || len(v) == 0, nil
+
+	case []any:
+		out := make([]any, 0, len(v))
+		for _, child := range v {
+			rendered, ok, err := r.render(child)
+			if err != nil {
+				return nil, false, err
+			}
+			if ok {
+				out = append(out, rendered)
+			}
+		}
+		return out, len(out) > 0 || len(v) == 0, nil
+
+	default:
+		return v, true, nil
+	}
+}
+
+// spread merges the options not placed elsewhere into out, without overriding template keys
+func (r *requestRenderer) spread(out map[string]interface{}, spread any) error {
+	value, _ := spread.(string)
+	if m := requestPlaceholder.FindStringSubmatch(value); m == nil || m[1] != "options" {
+		return fmt.Errorf("request template %q key only accepts {{options}}", requestSpreadKey)
+	}
+
+	for key, value := range r.request.Options {
+		if r.placed[key] {
+			continue
+		}
+		if _, exists := out[key]; !exists {
+			out[key] = value
+		}
+	}
+	return nil
+}
+
+func (r *requestRenderer) lookup(name string) (any, bool, error) {
+	if option, ok := strings.CutPrefix(name, "options."); ok {
+		value, exists := r.request.Options[option]
+		return value, exists, nil
+	}
+
+	switch name {
+	case "model":
+		return r.modelKey, true, nil
+	case "messages":
+		return r.request.Messages, true, nil
+	case "chat":
+		chat := []types.ChatMessage{}
+		for _, message := range r.request.Messages {
+			if message.Role != "system" {
+				chat = append(chat, message)
+			}
+		}
+		return chat, len(chat) > 0, nil
+	case "system":
+		var system []string
+		for _, message := range r.request.Messages {
+			if message.Role == "system" {
+				system = append(system, message.Content)
+			}
+		}
+		return strings.Join(system, "\n\n"), len(system) > 0, nil
+	case "contents":
+		contents := []map[string]any{}
+		for _, message := range r.request.Messages {
+			if message.Role == "system" {
+				continue
+			}
+			role := "user"
+			if message.Role == "assistant" {
+				role = "model"
+			}
+			contents = append(contents, map[string]any{
+				"role":  role,
+				"parts": []map[string]any{{"text": message.Content}},
+			})
+		}
+		return contents, len(contents) > 0, nil
+	case "prompt":
+		return completionPrompt(r.request.Messages), true, nil
+	case "stream":
+		return true, r.request.Stream, nil
+	case "options":
+		return r.request.Options, len(r.request.Options) > 0, nil
+	default:
+		return nil, false, fmt.Errorf("unknown request template placeholder %q", name)
+	}
+}
+
+// completionPrompt flattens a conversation into a role-labelled transcript that ends
+// with an open assistant turn
+func completionPrompt(messages []types.ChatMessage) string {
+	var b strings.Builder
+	for _, message := range messages {
+		role := message.Role
+		if role != "" {
+			role = strings.ToUpper(role[:1]) + role[1:]
+		}
+		fmt.Fprintf(&b, "%s: %s\n\n", role, message.Content)
+	}
+	b.WriteString("Assistant:")
+	return b.String()
+}
+
+// TransformResponse uses JSONPath mappings to transform a provider response into GeneralChatResponse.
+// cached_tokens counts cached input that prompt_tokens already includes, as OpenAI reports it.
+// cache_read_tokens and cache_write_tokens count input read from and written to the cache that
+// prompt_tokens leaves out, as Anthropic reports it; both are added to prompt_tokens and reads
+// are counted as cached.
 func TransformResponse(body []byte, mapping map[string]string) (*types.GeneralChatResponse, error) {
 	// Parse the JSON response
 	obj, err := oj.Parse(body)
@@ -56,6 +265,7 @@ func TransformResponse(body []byte, mapping map[string]string) (*types.GeneralCh
 	}
 
 	response := &types.GeneralChatResponse{}
+	var cacheRead, cacheWrite int
 
 	// Extract each field using JSONPath
 	for field, path := range mapping {
@@ -102,9 +312,18 @@ func TransformResponse(body []byte, mapping map[string]string) (*types.GeneralCh
 			response.CompletionTokens = toInt(value)
 		case "total_tokens":
 			response.TotalTokens = toInt(value)
+		case "cached_tokens":
+			response.CachedTokens = toInt(value)
+		case "cache_read_tokens":
+			cacheRead = toInt(value)
+		case "cache_write_tokens":
+			cacheWrite = toInt(value)
 		}
 	}
 
+	response.CachedTokens += cacheRead
+	response.PromptTokens += cacheRead + cacheWrite
+
 	// Calculate total_tokens if not provided
 	if response.TotalTokens == 0 && (response.PromptTokens > 0 || response.CompletionTokens > 0) {
 		response.TotalTokens = response.PromptTokens + response.CompletionTokens
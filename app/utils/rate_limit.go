@@ -0,0 +1,67 @@
+package utils
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// rateLimitResets pairs the remaining-quota and reset headers providers send with 429 responses.
+// OpenAI reports resets as durations ("6m0s"), Anthropic as RFC 3339 timestamps.
+var rateLimitResets = [][2]string{
+	{"x-ratelimit-remaining-requests", "x-ratelimit-reset-requests"},
+	{"x-ratelimit-remaining-tokens", "x-ratelimit-reset-tokens"},
+	{"anthropic-ratelimit-requests-remaining", "anthropic-ratelimit-requests-reset"},
+	{"anthropic-ratelimit-tokens-remaining", "anthropic-ratelimit-tokens-reset"},
+	{"anthropic-ratelimit-input-tokens-remaining", "anthropic-ratelimit-input-tokens-reset"},
+	{"anthropic-ratelimit-output-tokens-remaining", "anthropic-ratelimit-output-tokens-reset"},
+}
+
+// RetryAfter returns how long the provider asked the caller to wait before retrying.
+// It checks retry-after-ms, Retry-After (seconds or HTTP date) and then the reset
+// header of any exhausted OpenAI or Anthropic rate limit, returning false when none apply.
+func RetryAfter(header http.Header, now time.Time) (time.Duration, bool) {
+	if ms, err := strconv.ParseFloat(header.Get("retry-after-ms"), 64); err == nil && ms >= 0 {
+		return time.Duration(ms * float64(time.Millisecond)), true
+	}
+
+	if value := header.Get("Retry-After"); value != "" {
+		if seconds, err := strconv.ParseFloat(value, 64); err == nil && seconds >= 0 {
+			return time.Duration(seconds * float64(time.Second)), true
+		}
+		if at, err := http.ParseTime(value); err == nil {
+			return max(at.Sub(now), 0), true
+		}
+	}
+
+	// Wait for the latest reset among the limits that are used up
+	var wait time.Duration
+	found := false
+	for _, pair := range rateLimitResets {
+		if strings.TrimSpace(header.Get(pair[0])) != "0" {
+			continue
+		}
+		if reset, ok := parseRateLimitReset(header.Get(pair[1]), now); ok {
+			wait = max(wait, reset)
+			found = true
+		}
+	}
+
+	return wait, found
+}
+
+// parseRateLimitReset parses a reset header given either as a duration or a timestamp
+func parseRateLimitReset(value string, now time.Time) (time.Duration, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, false
+	}
+	if d, err := time.ParseDuration(value); err == nil {
+		return max(d, 0), true
+	}
+	if at, err := time.Parse(time.RFC3339, value); err == nil {
+		return max(at.Sub(now), 0), true
+	}
+	return 0, false
+}
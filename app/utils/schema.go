@@ -0,0 +1,139 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/santhosh-tekuri/jsonschema/v6"
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+)
+
+// schemaPrinter renders schema violation messages
+var schemaPrinter = message.NewPrinter(language.English)
+
+// JSONSchema is a compiled JSON Schema along with the defaults of its top-level properties.
+type JSONSchema struct {
+	schema   *jsonschema.Schema
+	defaults map[string]any
+}
+
+// SchemaViolation describes one value that does not satisfy a schema.
+type SchemaViolation struct {
+	// Path is the JSON pointer of the offending value, "" for the whole document
+	Path    string `json:"path"`
+	Message string `json:"message"`
+}
+
+// CompileJSONSchema compiles a raw JSON Schema document.
+func CompileJSONSchema(raw []byte) (*JSONSchema, error) {
+	doc, err := jsonschema.UnmarshalJSON(bytes.NewReader(raw))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse schema: %w", err)
+	}
+
+	compiler := jsonschema.NewCompiler()
+	if err := compiler.AddResource("schema.json", doc); err != nil {
+		return nil, fmt.Errorf("failed to load schema: %w", err)
+	}
+	schema, err := compiler.Compile("schema.json")
+	if err != nil {
+		return nil, fmt.Errorf("failed to compile schema: %w", err)
+	}
+
+	// Collect the defaults of top-level properties
+	var parsed struct {
+		Properties map[string]struct {
+			Default json.RawMessage `json:"default"`
+		} `json:"properties"`
+	}
+	if err := json.Unmarshal(raw, &parsed); err != nil {
+		return nil, fmt.Errorf("failed to parse schema: %w", err)
+	}
+	defaults := map[string]any{}
+	for name, property := range parsed.Properties {
+		if len(property.Default) == 0 {
+			continue
+		}
+		var value any
+		if err := json.Unmarshal(property.Default, &value); err != nil {
+			return nil, fmt.Errorf("failed to parse default of %s: %w", name, err)
+		}
+		defaults[name] = value
+	}
+
+	return &JSONSchema{schema: schema, defaults: defaults}, nil
+}
+
+// ApplyDefaults sets every top-level property default the object does not already have.
+func (s *JSONSchema) ApplyDefaults(object map[string]interface{}) {
+	ApplyRequestDefaults(object, s.defaults)
+}
+
+// Validate checks a Go value against the schema and returns each violation, sorted by path.
+// The value is round-tripped through JSON so any encodable value can be checked.
+func (s *JSONSchema) Validate(value any) ([]SchemaViolation, error) {
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	return s.ValidateJSON(raw)
+}
+
+// ValidateJSON checks a raw JSON document against the schema and returns each violation, sorted by path.
+func (s *JSONSchema) ValidateJSON(raw []byte) ([]SchemaViolation, error) {
+	instance, err := jsonschema.UnmarshalJSON(bytes.NewReader(raw))
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.schema.Validate(instance)
+	if err == nil {
+		return nil, nil
+	}
+	validationErr, ok := err.(*jsonschema.ValidationError)
+	if !ok {
+		return nil, err
+	}
+
+	var violations []SchemaViolation
+	collectViolations(validationErr, &violations)
+	sort.SliceStable(violations, func(i, j int) bool {
+		return violations[i].Path < violations[j].Path
+	})
+	return violations, nil
+}
+
+// collectViolations flattens a validation error tree into its leaf violations
+func collectViolations(err *jsonschema.ValidationError, violations *[]SchemaViolation) {
+	if len(err.Causes) == 0 {
+		path := ""
+		for _, token := range err.InstanceLocation {
+			path += "/" + strings.NewReplacer("~", "~0", "/", "~1").Replace(token)
+		}
+		*violations = append(*violations, SchemaViolation{
+			Path:    path,
+			Message: err.ErrorKind.LocalizedString(schemaPrinter),
+		})
+		return
+	}
+	for _, cause := range err.Causes {
+		collectViolations(cause, violations)
+	}
+}
+
+// FormatViolations joins violations into a single readable message.
+func FormatViolations(violations []SchemaViolation) string {
+	parts := make([]string, 0, len(violations))
+	for _, v := range violations {
+		path := v.Path
+		if path == "" {
+			path = "/"
+		}
+		parts = append(parts, path+": "+v.Message)
+	}
+	return strings.Join(parts, "; ")
+}
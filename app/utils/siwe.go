@@ -0,0 +1,177 @@
+package utils
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// siweHeader ends the first line of a Sign-In with Ethereum message
+const siweHeader = " wants you to sign in with your Ethereum account:"
+
+// SiweMessage struct to describe an EIP-4361 Sign-In with Ethereum message.
+type SiweMessage struct {
+	Domain         string
+	Address        string
+	Statement      string
+	URI            string
+	Version        string
+	ChainID        int64
+	Nonce          string
+	IssuedAt       time.Time
+	ExpirationTime *time.Time
+	NotBefore      *time.Time
+	RequestID      string
+	Resources      []string
+}
+
+// GenerateSiweNonce creates a random alphanumeric nonce for a sign-in message.
+func GenerateSiweNonce() (string, error) {
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	return hex.EncodeToString(nonce), nil
+}
+
+// ParseSiweMessage parses the EIP-4361 text form of a sign-in message.
+func ParseSiweMessage(raw string) (*SiweMessage, error) {
+	lines := strings.Split(strings.ReplaceAll(raw, "\r\n", "\n"), "\n")
+	if len(lines) < 2 || !strings.HasSuffix(lines[0], siweHeader) {
+		return nil, errors.New("message is not a sign-in with ethereum message")
+	}
+
+	message := &SiweMessage{
+		Domain:  strings.TrimSuffix(lines[0], siweHeader),
+		Address: lines[1],
+	}
+	if !common.IsHexAddress(message.Address) {
+		return nil, errors.New("message address is not an ethereum address")
+	}
+
+	// The statement, if any, sits between blank lines before the fields
+	i := 2
+	for i < len(lines) && lines[i] == "" {
+		i++
+	}
+	if i < len(lines) && !strings.HasPrefix(lines[i], "URI: ") {
+		message.Statement = lines[i]
+		i++
+	}
+
+	for ; i < len(lines); i++ {
+		line := lines[i]
+		if line == "" {
+			continue
+		}
+		if line == "Resources:" {
+			for i+1 < len(lines) && strings.HasPrefix(lines[i+1], "- ") {
+				i++
+				message.Resources = append(message.Resources, strings.TrimPrefix(lines[i], "- "))
+			}
+			continue
+		}
+
+		field, value, ok := strings.Cut(line, ": ")
+		if !ok {
+			return nil, fmt.Errorf("unexpected line %q", line)
+		}
+		if err := message.setField(field, value); err != nil {
+			return nil, err
+		}
+	}
+
+	if message.URI == "" || message.Version == "" || message.Nonce == "" || message.IssuedAt.IsZero() {
+		return nil, errors.New("message is missing a required field")
+	}
+	return message, nil
+}
+
+func (m *SiweMessage) setField(field, value string) error {
+	var err error
+	switch field {
+	case "URI":
+		m.URI = value
+	case "Version":
+		m.Version = value
+	case "Chain ID":
+		m.ChainID, err = strconv.ParseInt(value, 10, 64)
+	case "Nonce":
+		m.Nonce = value
+	case "Issued At":
+		m.IssuedAt, err = time.Parse(time.RFC3339, value)
+	case "Expiration Time":
+		m.ExpirationTime, err = parseSiweTime(value)
+	case "Not Before":
+		m.NotBefore, err = parseSiweTime(value)
+	case "Request ID":
+		m.RequestID = value
+	default:
+		return fmt.Errorf("unknown field %q", field)
+	}
+	if err != nil {
+		return fmt.Errorf("invalid %s: %w", field, err)
+	}
+	return nil
+}
+
+func parseSiweTime(value string) (*time.Time, error) {
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// Validate checks the message was meant for this domain and chain and is valid at now.
+// A chainID of 0 accepts any chain.
+func (m *SiweMessage) Validate(domain string, chainID int64, now time.Time) error {
+	if m.Version != "1" {
+		return fmt.Errorf("unsupported message version %s", m.Version)
+	}
+	if !strings.EqualFold(m.Domain, domain) {
+		return fmt.Errorf("message is for domain %s", m.Domain)
+	}
+	if chainID != 0 && m.ChainID != chainID {
+		return fmt.Errorf("message is for chain %d", m.ChainID)
+	}
+	if m.ExpirationTime != nil && !now.Before(*m.ExpirationTime) {
+		return errors.New("message has expired")
+	}
+	if m.NotBefore != nil && now.Before(*m.NotBefore) {
+		return errors.New("message is not valid yet")
+	}
+	return nil
+}
+
+// VerifySiweSignature checks that an EIP-191 personal_sign signature of the raw message
+// was made by address.
+func VerifySiweSignature(raw, address, signature string) error {
+	sig, err := hexutil.Decode(signature)
+	if err != nil || len(sig) != crypto.SignatureLength {
+		return errors.New("signature must be 65 hex encoded bytes")
+	}
+
+	// Wallets sign with a recovery id of 27 or 28
+	if sig[crypto.RecoveryIDOffset] >= 27 {
+		sig[crypto.RecoveryIDOffset] -= 27
+	}
+
+	pub, err := crypto.SigToPub(accounts.TextHash([]byte(raw)), sig)
+	if err != nil {
+		return fmt.Errorf("failed to recover signer: %w", err)
+	}
+	if crypto.PubkeyToAddress(*pub) != common.HexToAddress(address) {
+		return errors.New("signature does not match the message address")
+	}
+	return nil
+}
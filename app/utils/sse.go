@@ -0,0 +1,107 @@
+package utils
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// SSEDone is the data payload providers send to mark the end of a stream.
+const SSEDone = "[DONE]"
+
+// SSEEvent struct to describe a single server-sent event.
+type SSEEvent struct {
+	Event string
+	Data  string
+}
+
+// ReadSSE reads server-sent events from r and calls fn for each complete event.
+// Reading stops at EOF, when fn returns an error, or when a [DONE] payload is received.
+func ReadSSE(r io.Reader, fn func(event SSEEvent) error) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	var event SSEEvent
+	var data []string
+
+	dispatch := func() error {
+		if len(data) == 0 {
+			event = SSEEvent{}
+			return nil
+		}
+		event.Data = strings.Join(data, "\n")
+		current := event
+		event = SSEEvent{}
+		data = data[:0]
+		if current.Data == SSEDone {
+			return io.EOF
+		}
+		return fn(current)
+	}
+
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		// A blank line terminates the current event
+		if line == "" {
+			if err := dispatch(); err != nil {
+				if err == io.EOF {
+					return nil
+				}
+				return err
+			}
+			continue
+		}
+
+		// Lines starting with a colon are comments (keep-alives)
+		if strings.HasPrefix(line, ":") {
+			continue
+		}
+
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+
+		switch field {
+		case "event":
+			event.Event = value
+		case "data":
+			data = append(data, value)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	// Flush a trailing event that was not terminated by a blank line
+	if err := dispatch(); err != nil && err != io.EOF {
+		return err
+	}
+
+	return nil
+}
+
+// WriteSSE writes a single server-sent event with a JSON encoded payload to w.
+func WriteSSE(w io.Writer, event string, payload interface{}) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	if event != "" {
+		if _, err := fmt.Fprintf(w, "event: %s\n", event); err != nil {
+			return err
+		}
+	}
+
+	_, err = fmt.Fprintf(w, "data: %s\n\n", data)
+	return err
+}
+
+// WriteSSEDone writes the [DONE] event that ends an OpenAI style stream.
+func WriteSSEDone(w io.Writer) error {
+	_, err := fmt.Fprintf(w, "data: %s\n\n", SSEDone)
+	return err
+}
@@ -35,14 +35,27 @@ func main() {
 	logger := log.Output(zerolog.ConsoleWriter{Out: os.Stdout})
 
 	ctx := context.Background()
+	shutdownTracing, err := utils.StartTracing(ctx)
+	if err != nil {
+		logger.Fatal().Err(err).Msg("failed to start tracing")
+	}
+	defer shutdownTracing(ctx)
+
 	sqlStore := store.NewSqlStore(ctx)
 	defer sqlStore.Close()
 
+	cacheStore, err := store.NewCacheStore()
+	if err != nil {
+		logger.Fatal().Err(err).Msg("failed to connect to redis")
+	}
+	defer cacheStore.Close()
+
 	app := fiber.New(configs.FiberConfig())
 	middleware.FiberMiddleware(app)
 
-	svc := service.New(&logger, sqlStore, app, nil)
-	routes.New(svc).Setup(app)
+	svc := service.New(&logger, sqlStore, cacheStore, app, nil)
+	routes.New(svc, sqlStore, cacheStore).Setup(app)
+	go svc.SweepReservations(ctx)
 
 	if os.Getenv("STAGE_STATUS") == "dev" {
 		utils.StartServer(app)
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"os"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"github.com/wmbryce/agent-c/app/store"
+	"github.com/wmbryce/agent-c/app/types"
+	"github.com/wmbryce/agent-c/app/utils"
+
+	_ "github.com/joho/godotenv/autoload"
+)
+
+// reencrypt brings every stored seller api key under the current master key: keys stored
+// before encryption at rest are sealed and their plaintext dropped, and keys sealed under an
+// older master key have their data key rewrapped. Run it after adding a new master key to the
+// front of API_KEY_MASTER_KEYS, then retire the old key once it reports no remaining keys.
+func main() {
+	zerolog.TimeFieldFormat = zerolog.TimeFormatUnix
+	logger := log.Output(zerolog.ConsoleWriter{Out: os.Stdout})
+
+	ring, err := utils.KeyRingFromEnv()
+	if err != nil {
+		logger.Fatal().Err(err).Msg("failed to load api key master keys")
+	}
+
+	sqlStore := store.NewSqlStore(context.Background())
+	defer sqlStore.Close()
+
+	saved, err := sqlStore.ReencryptApiKeys(func(key *types.StoredApiKey) (bool, error) {
+		if key.Sealed == nil {
+			if key.Plaintext == nil {
+				return false, nil
+			}
+			sealed, err := ring.Seal(*key.Plaintext)
+			if err != nil {
+				return false, err
+			}
+			key.Sealed = sealed
+			key.Fingerprint = utils.KeyFingerprint(*key.Plaintext)
+			key.Last4 = utils.KeyLast4(*key.Plaintext)
+			key.Plaintext = nil
+			return true, nil
+		}
+
+		// Drop any plaintext left beside a sealed key
+		changed, err := ring.Rewrap(key.Sealed)
+		return changed || key.Plaintext != nil, err
+	})
+	if err != nil {
+		logger.Fatal().Err(err).Msg("failed to re-encrypt api keys")
+	}
+
+	logger.Info().
+		Int("keys", saved).
+		Str("master_key_id", ring.CurrentKeyID()).
+		Msg("re-encrypted api keys")
+
+	// Running services cache sealed keys, so tell them to reload before the old key is retired
+	cacheStore, err := store.NewCacheStore()
+	if err != nil {
+		logger.Warn().Err(err).Msg("failed to connect to redis, cached credentials reload once they expire")
+		return
+	}
+	defer cacheStore.Close()
+	if err := cacheStore.PublishCredentialsInvalidation(); err != nil {
+		logger.Warn().Err(err).Msg("failed to publish credentials invalidation, cached credentials reload once they expire")
+	}
+}
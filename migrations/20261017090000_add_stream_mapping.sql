@@ -0,0 +1,57 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- =============================================
+-- ADD PROVIDER STREAMING COLUMNS
+-- =============================================
+
+ALTER TABLE agc.providers ADD COLUMN stream_defaults JSONB DEFAULT '{}';
+ALTER TABLE agc.providers ADD COLUMN stream_mapping JSONB DEFAULT '{}';
+
+-- =============================================
+-- UPDATE OPENAI PROVIDER STREAMING CONFIG
+-- =============================================
+
+-- OpenAI only reports usage on the final chunk when include_usage is set
+UPDATE agc.providers SET
+  stream_defaults = '{"stream_options": {"include_usage": true}}',
+  stream_mapping = '{
+    "id": "$.id",
+    "model": "$.model",
+    "content": "$.choices[0].delta.content",
+    "role": "$.choices[0].delta.role",
+    "finish_reason": "$.choices[0].finish_reason",
+    "prompt_tokens": "$.usage.prompt_tokens",
+    "completion_tokens": "$.usage.completion_tokens",
+    "total_tokens": "$.usage.total_tokens"
+  }'
+WHERE name = 'OpenAI';
+
+-- =============================================
+-- UPDATE ANTHROPIC PROVIDER STREAMING CONFIG
+-- =============================================
+
+-- Paths cover message_start, content_block_delta and message_delta events
+UPDATE agc.providers SET
+  stream_defaults = '{}',
+  stream_mapping = '{
+    "id": "$.message.id",
+    "model": "$.message.model",
+    "content": "$.delta.text",
+    "role": "$.message.role",
+    "finish_reason": "$.delta.stop_reason",
+    "prompt_tokens": "$.message.usage.input_tokens",
+    "completion_tokens": "$.usage.output_tokens",
+    "total_tokens": null
+  }'
+WHERE name = 'Anthropic';
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+ALTER TABLE agc.providers DROP COLUMN IF EXISTS stream_defaults;
+ALTER TABLE agc.providers DROP COLUMN IF EXISTS stream_mapping;
+
+-- +goose StatementEnd
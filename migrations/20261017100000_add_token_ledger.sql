@@ -0,0 +1,48 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- =============================================
+-- TOKEN RESERVATIONS AND LEDGER
+-- =============================================
+
+-- A key can never be debited below zero
+ALTER TABLE agc.api_keys ADD CONSTRAINT api_keys_tokens_available_check CHECK (tokens_available >= 0);
+
+-- Tokens held against an api key while an upstream request is in flight
+CREATE TABLE IF NOT EXISTS agc.token_reservations (
+    id UUID DEFAULT gen_random_uuid() PRIMARY KEY,
+    api_key_id UUID NOT NULL REFERENCES agc.api_keys (id) ON DELETE CASCADE,
+    model_key VARCHAR (255) NOT NULL,
+    reserved_tokens INT NOT NULL CHECK (reserved_tokens > 0),
+    settled_tokens INT NULL,
+    status VARCHAR (20) NOT NULL DEFAULT 'reserved' CHECK (status IN ('reserved', 'settled', 'released')),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW (),
+    settled_at TIMESTAMP WITH TIME ZONE NULL
+);
+
+-- Append-only audit trail of every change to api_keys.tokens_available.
+-- tokens is negative for debits and positive for refunds.
+CREATE TABLE IF NOT EXISTS agc.token_ledger (
+    id UUID DEFAULT gen_random_uuid() PRIMARY KEY,
+    api_key_id UUID NOT NULL REFERENCES agc.api_keys (id) ON DELETE CASCADE,
+    reservation_id UUID NULL REFERENCES agc.token_reservations (id) ON DELETE SET NULL,
+    entry_type VARCHAR (20) NOT NULL CHECK (entry_type IN ('reserve', 'settle', 'release')),
+    tokens INT NOT NULL,
+    balance_after INT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW ()
+);
+
+CREATE INDEX IF NOT EXISTS token_reservations_open_idx ON agc.token_reservations (status, created_at) WHERE status = 'reserved';
+CREATE INDEX IF NOT EXISTS token_ledger_api_key_idx ON agc.token_ledger (api_key_id, created_at);
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+DROP TABLE IF EXISTS agc.token_ledger;
+DROP TABLE IF EXISTS agc.token_reservations;
+
+ALTER TABLE agc.api_keys DROP CONSTRAINT IF EXISTS api_keys_tokens_available_check;
+
+-- +goose StatementEnd
@@ -0,0 +1,35 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- =============================================
+-- USAGE RECORDS
+-- =============================================
+
+-- One row per ConsumeModel call that reached a provider
+CREATE TABLE IF NOT EXISTS agc.usage_records (
+    id UUID DEFAULT gen_random_uuid() PRIMARY KEY,
+    consumer_id UUID NULL REFERENCES agc.consumers (id) ON DELETE SET NULL,
+    model_key VARCHAR (255) NOT NULL,
+    api_key_id UUID NULL REFERENCES agc.api_keys (id) ON DELETE SET NULL,
+    prompt_tokens INT NOT NULL DEFAULT 0,
+    completion_tokens INT NOT NULL DEFAULT 0,
+    total_tokens INT NOT NULL DEFAULT 0,
+    cost NUMERIC (20, 10) NOT NULL DEFAULT 0,
+    latency_ms BIGINT NOT NULL DEFAULT 0,
+    provider_status INT NOT NULL DEFAULT 0,
+    stream BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW ()
+);
+
+CREATE INDEX IF NOT EXISTS usage_records_created_at_idx ON agc.usage_records (created_at);
+CREATE INDEX IF NOT EXISTS usage_records_consumer_idx ON agc.usage_records (consumer_id, created_at);
+CREATE INDEX IF NOT EXISTS usage_records_model_idx ON agc.usage_records (model_key, created_at);
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+DROP TABLE IF EXISTS agc.usage_records;
+
+-- +goose StatementEnd
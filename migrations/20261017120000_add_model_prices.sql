@@ -0,0 +1,78 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- =============================================
+-- MODEL PRICES
+-- =============================================
+
+-- Prices are in currency units per million tokens. A model may have several rows;
+-- the one with the latest effective_from in the past is the price in effect.
+CREATE TABLE IF NOT EXISTS agc.model_prices (
+    id UUID DEFAULT gen_random_uuid() PRIMARY KEY,
+    model_id UUID NOT NULL REFERENCES agc.models (id) ON DELETE CASCADE,
+    currency VARCHAR (3) NOT NULL DEFAULT 'USD',
+    input_price NUMERIC (20, 10) NOT NULL CHECK (input_price >= 0),
+    output_price NUMERIC (20, 10) NOT NULL CHECK (output_price >= 0),
+    cached_input_price NUMERIC (20, 10) NULL CHECK (cached_input_price >= 0),
+    effective_from TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW (),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW (),
+    UNIQUE (model_id, effective_from)
+);
+
+CREATE INDEX IF NOT EXISTS model_prices_lookup_idx ON agc.model_prices (model_id, effective_from DESC);
+
+ALTER TABLE agc.usage_records ADD COLUMN currency VARCHAR (3) NULL;
+
+-- =============================================
+-- SEED MODEL PRICES (USD per 1M tokens)
+-- =============================================
+
+INSERT INTO agc.model_prices (model_id, currency, input_price, output_price, cached_input_price, effective_from)
+SELECT m.id, 'USD', p.input_price, p.output_price, p.cached_input_price, '2025-01-01T00:00:00Z'
+FROM (VALUES
+    ('gpt-4o', 2.50, 10.00, 1.25),
+    ('gpt-4o-mini', 0.15, 0.60, 0.075),
+    ('chatgpt-4o-latest', 5.00, 15.00, NULL),
+    ('gpt-4-turbo', 10.00, 30.00, NULL),
+    ('gpt-4-turbo-preview', 10.00, 30.00, NULL),
+    ('gpt-4', 30.00, 60.00, NULL),
+    ('gpt-4-32k', 60.00, 120.00, NULL),
+    ('o1', 15.00, 60.00, 7.50),
+    ('o1-mini', 1.10, 4.40, 0.55),
+    ('o1-preview', 15.00, 60.00, 7.50),
+    ('o3-mini', 1.10, 4.40, 0.55),
+    ('gpt-3.5-turbo', 0.50, 1.50, NULL),
+    ('gpt-3.5-turbo-16k', 3.00, 4.00, NULL),
+    ('claude-sonnet-4-5-20250929', 3.00, 15.00, 0.30),
+    ('claude-haiku-4-5-20251001', 1.00, 5.00, 0.10),
+    ('claude-opus-4-5-20251101', 5.00, 25.00, 0.50),
+    ('claude-opus-4-20250514', 15.00, 75.00, 1.50),
+    ('claude-sonnet-4-20250514', 3.00, 15.00, 0.30),
+    ('claude-opus-4-1-20250805', 15.00, 75.00, 1.50),
+    ('claude-3-7-sonnet-20250219', 3.00, 15.00, 0.30),
+    ('claude-3-5-haiku-20241022', 0.80, 4.00, 0.08),
+    ('claude-3-haiku-20240307', 0.25, 1.25, 0.03)
+) AS p (model_key, input_price, output_price, cached_input_price)
+JOIN agc.models m ON m.model_key = p.model_key;
+
+-- OpenAI reports cached prompt tokens as part of prompt_tokens
+UPDATE agc.providers SET
+  response_mapping = response_mapping || '{"cached_tokens": "$.usage.prompt_tokens_details.cached_tokens"}',
+  stream_mapping = stream_mapping || '{"cached_tokens": "$.usage.prompt_tokens_details.cached_tokens"}'
+WHERE name = 'OpenAI';
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+UPDATE agc.providers SET
+  response_mapping = response_mapping - 'cached_tokens',
+  stream_mapping = stream_mapping - 'cached_tokens'
+WHERE name = 'OpenAI';
+
+ALTER TABLE agc.usage_records DROP COLUMN IF EXISTS currency;
+
+DROP TABLE IF EXISTS agc.model_prices;
+
+-- +goose StatementEnd
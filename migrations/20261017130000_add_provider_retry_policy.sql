@@ -0,0 +1,37 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- =============================================
+-- ADD PROVIDER RETRY POLICY
+-- =============================================
+
+-- NULL uses the service defaults: 3 attempts, 250ms base delay, 5s max delay, retry on 429 and 503
+ALTER TABLE agc.providers ADD COLUMN retry_policy JSONB DEFAULT NULL;
+
+UPDATE agc.providers SET
+  retry_policy = '{
+    "max_attempts": 3,
+    "base_delay_ms": 500,
+    "max_delay_ms": 10000,
+    "retry_statuses": [429, 503]
+  }'
+WHERE name = 'OpenAI';
+
+-- Anthropic returns 529 when the API is overloaded
+UPDATE agc.providers SET
+  retry_policy = '{
+    "max_attempts": 3,
+    "base_delay_ms": 500,
+    "max_delay_ms": 10000,
+    "retry_statuses": [429, 503, 529]
+  }'
+WHERE name = 'Anthropic';
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+ALTER TABLE agc.providers DROP COLUMN IF EXISTS retry_policy;
+
+-- +goose StatementEnd
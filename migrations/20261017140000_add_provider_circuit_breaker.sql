@@ -0,0 +1,19 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- =============================================
+-- ADD PROVIDER CIRCUIT BREAKER CONFIG
+-- =============================================
+
+-- NULL uses the service defaults: open after 5 consecutive failures for 30s, 1 half-open probe,
+-- health stats over the last 100 calls
+ALTER TABLE agc.providers ADD COLUMN circuit_breaker JSONB DEFAULT NULL;
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+ALTER TABLE agc.providers DROP COLUMN IF EXISTS circuit_breaker;
+
+-- +goose StatementEnd
@@ -0,0 +1,30 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- =============================================
+-- ADD MODEL FALLBACK CHAINS
+-- =============================================
+
+-- Model keys tried in order when a consume request has no fallback_models of its own
+ALTER TABLE agc.models ADD COLUMN fallback_models TEXT[] NOT NULL DEFAULT '{}';
+
+UPDATE agc.models SET fallback_models = '{claude-sonnet-4-5-20250929, gpt-4o-mini}'
+WHERE model_key = 'gpt-4o';
+
+UPDATE agc.models SET fallback_models = '{gpt-4o, claude-haiku-4-5-20251001}'
+WHERE model_key = 'claude-sonnet-4-5-20250929';
+
+UPDATE agc.models SET fallback_models = '{claude-haiku-4-5-20251001}'
+WHERE model_key = 'gpt-4o-mini';
+
+UPDATE agc.models SET fallback_models = '{gpt-4o-mini}'
+WHERE model_key = 'claude-haiku-4-5-20251001';
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+ALTER TABLE agc.models DROP COLUMN IF EXISTS fallback_models;
+
+-- +goose StatementEnd
@@ -0,0 +1,35 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- =============================================
+-- ADD PROVIDER REQUEST MAPPING
+-- =============================================
+
+-- Template for the outbound request body, see utils.TransformRequest for the placeholders.
+-- NULL sends the normalized {"model", "messages", ...options} body.
+ALTER TABLE agc.providers ADD COLUMN request_mapping JSONB DEFAULT NULL;
+
+-- =============================================
+-- UPDATE ANTHROPIC PROVIDER REQUEST MAPPING
+-- =============================================
+
+-- Anthropic takes the system prompt as a top-level field and names stop sequences differently
+UPDATE agc.providers SET
+  request_mapping = '{
+    "model": "{{model}}",
+    "system": "{{system}}",
+    "messages": "{{chat}}",
+    "stop_sequences": "{{options.stop}}",
+    "stream": "{{stream}}",
+    "*": "{{options}}"
+  }'
+WHERE name = 'Anthropic';
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+ALTER TABLE agc.providers DROP COLUMN IF EXISTS request_mapping;
+
+-- +goose StatementEnd
@@ -0,0 +1,63 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- =============================================
+-- UPDATE OPTIONS SCHEMAS
+-- =============================================
+
+-- Consume options are now validated against these schemas and their defaults are sent upstream.
+
+-- Anthropic: max_tokens defaults to the provider's request default instead of being required,
+-- and the normalized "stop" option is accepted (the request mapping sends it as stop_sequences)
+UPDATE agc.model_schemas SET
+  schema = jsonb_set(
+    jsonb_set(schema - 'required', '{properties,max_tokens,default}', '1024'),
+    '{properties,stop}',
+    '{"type": "array", "items": {"type": "string"}, "description": "Stop sequences, sent as stop_sequences"}'
+  ),
+  updated_at = NOW()
+WHERE id = '00000000-0002-0001-0000-000000000001';
+
+-- OpenAI: the sampling defaults are dropped since the reasoning models sharing this schema
+-- reject those parameters, and stop also accepts a single string
+UPDATE agc.model_schemas SET
+  schema = jsonb_set(
+    schema #- '{properties,temperature,default}'
+           #- '{properties,top_p,default}'
+           #- '{properties,frequency_penalty,default}'
+           #- '{properties,presence_penalty,default}',
+    '{properties,stop}',
+    '{"type": ["string", "array"], "items": {"type": "string"}, "maxItems": 4, "description": "Stop sequences"}'
+  ),
+  updated_at = NOW()
+WHERE id = '00000000-0001-0001-0000-000000000001';
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+UPDATE agc.model_schemas SET
+  schema = jsonb_set(
+    (schema #- '{properties,max_tokens,default}' #- '{properties,stop}'),
+    '{required}', '["max_tokens"]'
+  ),
+  updated_at = NOW()
+WHERE id = '00000000-0002-0001-0000-000000000001';
+
+UPDATE agc.model_schemas SET
+  schema = jsonb_set(
+    jsonb_set(
+      jsonb_set(
+        jsonb_set(
+          jsonb_set(schema, '{properties,temperature,default}', '1'),
+          '{properties,top_p,default}', '1'),
+        '{properties,frequency_penalty,default}', '0'),
+      '{properties,presence_penalty,default}', '0'),
+    '{properties,stop}',
+    '{"type": "array", "items": {"type": "string"}, "maxItems": 4, "description": "Stop sequences"}'
+  ),
+  updated_at = NOW()
+WHERE id = '00000000-0001-0001-0000-000000000001';
+
+-- +goose StatementEnd
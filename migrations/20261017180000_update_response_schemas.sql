@@ -0,0 +1,166 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- =============================================
+-- UPDATE RESPONSE SCHEMAS
+-- =============================================
+
+-- Provider responses are now checked against these schemas, so they require the fields
+-- the response mappings read and allow the nulls the providers actually send.
+
+-- OpenAI: message content is null on tool calls
+UPDATE agc.model_schemas SET
+  schema = '{
+    "type": "object",
+    "properties": {
+        "id": {"type": "string"},
+        "object": {"type": "string"},
+        "created": {"type": "integer"},
+        "model": {"type": "string"},
+        "choices": {
+            "type": "array",
+            "minItems": 1,
+            "items": {
+                "type": "object",
+                "properties": {
+                    "index": {"type": "integer"},
+                    "message": {
+                        "type": "object",
+                        "properties": {
+                            "role": {"type": "string"},
+                            "content": {"type": ["string", "null"]}
+                        },
+                        "required": ["role", "content"]
+                    },
+                    "finish_reason": {"type": ["string", "null"]}
+                },
+                "required": ["message", "finish_reason"]
+            }
+        },
+        "usage": {
+            "type": "object",
+            "properties": {
+                "prompt_tokens": {"type": "integer"},
+                "completion_tokens": {"type": "integer"},
+                "total_tokens": {"type": "integer"}
+            },
+            "required": ["prompt_tokens", "completion_tokens", "total_tokens"]
+        }
+    },
+    "required": ["id", "model", "choices", "usage"]
+  }',
+  updated_at = NOW()
+WHERE id = '00000000-0001-0002-0000-000000000001';
+
+-- Anthropic: stop_sequence is null unless a custom stop sequence ended the message
+UPDATE agc.model_schemas SET
+  schema = '{
+    "type": "object",
+    "properties": {
+        "id": {"type": "string"},
+        "type": {"type": "string"},
+        "role": {"type": "string"},
+        "model": {"type": "string"},
+        "content": {
+            "type": "array",
+            "items": {
+                "type": "object",
+                "properties": {
+                    "type": {"type": "string"},
+                    "text": {"type": "string"}
+                },
+                "required": ["type"]
+            }
+        },
+        "stop_reason": {"type": ["string", "null"]},
+        "stop_sequence": {"type": ["string", "null"]},
+        "usage": {
+            "type": "object",
+            "properties": {
+                "input_tokens": {"type": "integer"},
+                "output_tokens": {"type": "integer"}
+            },
+            "required": ["input_tokens", "output_tokens"]
+        }
+    },
+    "required": ["id", "model", "role", "content", "stop_reason", "usage"]
+  }',
+  updated_at = NOW()
+WHERE id = '00000000-0002-0002-0000-000000000001';
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+UPDATE agc.model_schemas SET
+  schema = '{
+    "type": "object",
+    "properties": {
+        "id": {"type": "string"},
+        "object": {"type": "string"},
+        "created": {"type": "integer"},
+        "model": {"type": "string"},
+        "choices": {
+            "type": "array",
+            "items": {
+                "type": "object",
+                "properties": {
+                    "index": {"type": "integer"},
+                    "message": {
+                        "type": "object",
+                        "properties": {
+                            "role": {"type": "string"},
+                            "content": {"type": "string"}
+                        }
+                    },
+                    "finish_reason": {"type": "string"}
+                }
+            }
+        },
+        "usage": {
+            "type": "object",
+            "properties": {
+                "prompt_tokens": {"type": "integer"},
+                "completion_tokens": {"type": "integer"},
+                "total_tokens": {"type": "integer"}
+            }
+        }
+    }
+  }',
+  updated_at = NOW()
+WHERE id = '00000000-0001-0002-0000-000000000001';
+
+UPDATE agc.model_schemas SET
+  schema = '{
+    "type": "object",
+    "properties": {
+        "id": {"type": "string"},
+        "type": {"type": "string"},
+        "role": {"type": "string"},
+        "model": {"type": "string"},
+        "content": {
+            "type": "array",
+            "items": {
+                "type": "object",
+                "properties": {
+                    "type": {"type": "string"},
+                    "text": {"type": "string"}
+                }
+            }
+        },
+        "stop_reason": {"type": "string"},
+        "stop_sequence": {"type": "string"},
+        "usage": {
+            "type": "object",
+            "properties": {
+                "input_tokens": {"type": "integer"},
+                "output_tokens": {"type": "integer"}
+            }
+        }
+    }
+  }',
+  updated_at = NOW()
+WHERE id = '00000000-0002-0002-0000-000000000001';
+
+-- +goose StatementEnd
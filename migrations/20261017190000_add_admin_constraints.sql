@@ -0,0 +1,38 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- =============================================
+-- ADMIN API CONSTRAINTS
+-- =============================================
+
+-- Admin changes to api_keys.tokens_available are recorded in the ledger as adjustments
+ALTER TABLE agc.token_ledger DROP CONSTRAINT IF EXISTS token_ledger_entry_type_check;
+ALTER TABLE agc.token_ledger ADD CONSTRAINT token_ledger_entry_type_check
+    CHECK (entry_type IN ('reserve', 'settle', 'release', 'adjust'));
+
+-- Deleting a schema must not silently delete the models that use it
+ALTER TABLE agc.models DROP CONSTRAINT IF EXISTS models_options_schema_id_fkey;
+ALTER TABLE agc.models ADD CONSTRAINT models_options_schema_id_fkey
+    FOREIGN KEY (options_schema_id) REFERENCES agc.model_schemas (id) ON DELETE RESTRICT;
+ALTER TABLE agc.models DROP CONSTRAINT IF EXISTS models_response_schema_id_fkey;
+ALTER TABLE agc.models ADD CONSTRAINT models_response_schema_id_fkey
+    FOREIGN KEY (response_schema_id) REFERENCES agc.model_schemas (id) ON DELETE RESTRICT;
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+ALTER TABLE agc.models DROP CONSTRAINT IF EXISTS models_response_schema_id_fkey;
+ALTER TABLE agc.models ADD CONSTRAINT models_response_schema_id_fkey
+    FOREIGN KEY (response_schema_id) REFERENCES agc.model_schemas (id) ON DELETE CASCADE;
+ALTER TABLE agc.models DROP CONSTRAINT IF EXISTS models_options_schema_id_fkey;
+ALTER TABLE agc.models ADD CONSTRAINT models_options_schema_id_fkey
+    FOREIGN KEY (options_schema_id) REFERENCES agc.model_schemas (id) ON DELETE CASCADE;
+
+DELETE FROM agc.token_ledger WHERE entry_type = 'adjust';
+ALTER TABLE agc.token_ledger DROP CONSTRAINT IF EXISTS token_ledger_entry_type_check;
+ALTER TABLE agc.token_ledger ADD CONSTRAINT token_ledger_entry_type_check
+    CHECK (entry_type IN ('reserve', 'settle', 'release'));
+
+-- +goose StatementEnd
@@ -0,0 +1,38 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- =============================================
+-- ENCRYPTED SELLER API KEYS
+-- =============================================
+
+-- Seller keys are stored with envelope encryption: key_ciphertext is sealed with a per-key
+-- data key, and key_wrapped is that data key sealed with the master key key_master_id.
+-- Existing plaintext keys stay in api_key until `make reencrypt` seals them.
+ALTER TABLE agc.api_keys ALTER COLUMN api_key DROP NOT NULL;
+ALTER TABLE agc.api_keys ADD COLUMN key_ciphertext BYTEA NULL;
+ALTER TABLE agc.api_keys ADD COLUMN key_wrapped BYTEA NULL;
+ALTER TABLE agc.api_keys ADD COLUMN key_master_id VARCHAR(64) NULL;
+ALTER TABLE agc.api_keys ADD COLUMN key_fingerprint VARCHAR(64) NULL UNIQUE;
+ALTER TABLE agc.api_keys ADD COLUMN key_last4 VARCHAR(4) NULL;
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+-- Encrypted keys cannot be restored to plaintext in SQL, so refuse to roll back while any
+-- remain rather than drop them; they must be deleted or added again as plaintext first
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM agc.api_keys WHERE api_key IS NULL) THEN
+        RAISE EXCEPTION 'agc.api_keys holds encrypted keys with no plaintext copy; remove them before rolling back';
+    END IF;
+END $$;
+ALTER TABLE agc.api_keys DROP COLUMN key_last4;
+ALTER TABLE agc.api_keys DROP COLUMN key_fingerprint;
+ALTER TABLE agc.api_keys DROP COLUMN key_master_id;
+ALTER TABLE agc.api_keys DROP COLUMN key_wrapped;
+ALTER TABLE agc.api_keys DROP COLUMN key_ciphertext;
+ALTER TABLE agc.api_keys ALTER COLUMN api_key SET NOT NULL;
+
+-- +goose StatementEnd
@@ -0,0 +1,31 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- =============================================
+-- CONSUMER API KEYS
+-- =============================================
+
+-- Api keys agent-c issues to consumers. The prefix identifies a key, and only a bcrypt
+-- hash of its secret is stored. Revoked keys are kept so usage stays attributable.
+CREATE TABLE agc.consumer_keys (
+    id UUID DEFAULT gen_random_uuid() PRIMARY KEY,
+    consumer_id UUID NOT NULL REFERENCES agc.consumers (id) ON DELETE CASCADE,
+    name VARCHAR(255) NOT NULL DEFAULT '',
+    prefix VARCHAR(32) NOT NULL UNIQUE,
+    key_hash VARCHAR(255) NOT NULL,
+    scopes TEXT[] NOT NULL,
+    revoked_at TIMESTAMP WITH TIME ZONE NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP NULL
+);
+
+CREATE INDEX IF NOT EXISTS consumer_keys_consumer_idx ON agc.consumer_keys (consumer_id);
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+DROP TABLE IF EXISTS agc.consumer_keys;
+
+-- +goose StatementEnd
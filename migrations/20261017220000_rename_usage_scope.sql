@@ -0,0 +1,14 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- Consumer key scopes are now the permissions they grant: usage becomes usage:read.
+UPDATE agc.consumer_keys SET scopes = array_replace(scopes, 'usage', 'usage:read');
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+UPDATE agc.consumer_keys SET scopes = array_replace(scopes, 'usage:read', 'usage');
+
+-- +goose StatementEnd
@@ -0,0 +1,15 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- Seconds cacheable responses of a model are cached for. NULL uses RESPONSE_CACHE_TTL_SECONDS,
+-- 0 disables the response cache for the model.
+ALTER TABLE agc.models ADD COLUMN response_cache_ttl INTEGER NULL CHECK (response_cache_ttl >= 0);
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+ALTER TABLE agc.models DROP COLUMN IF EXISTS response_cache_ttl;
+
+-- +goose StatementEnd
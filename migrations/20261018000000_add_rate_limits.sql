@@ -0,0 +1,31 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- =============================================
+-- RATE LIMITS
+-- =============================================
+
+-- Requests and tokens per minute allowed for each consumer, model and seller api key.
+-- NULL is unlimited. Usage is counted in Redis over a sliding one minute window.
+ALTER TABLE agc.consumers
+    ADD COLUMN requests_per_minute INTEGER NULL CHECK (requests_per_minute > 0),
+    ADD COLUMN tokens_per_minute INTEGER NULL CHECK (tokens_per_minute > 0);
+
+ALTER TABLE agc.models
+    ADD COLUMN requests_per_minute INTEGER NULL CHECK (requests_per_minute > 0),
+    ADD COLUMN tokens_per_minute INTEGER NULL CHECK (tokens_per_minute > 0);
+
+ALTER TABLE agc.api_keys
+    ADD COLUMN requests_per_minute INTEGER NULL CHECK (requests_per_minute > 0),
+    ADD COLUMN tokens_per_minute INTEGER NULL CHECK (tokens_per_minute > 0);
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+ALTER TABLE agc.api_keys DROP COLUMN IF EXISTS requests_per_minute, DROP COLUMN IF EXISTS tokens_per_minute;
+ALTER TABLE agc.models DROP COLUMN IF EXISTS requests_per_minute, DROP COLUMN IF EXISTS tokens_per_minute;
+ALTER TABLE agc.consumers DROP COLUMN IF EXISTS requests_per_minute, DROP COLUMN IF EXISTS tokens_per_minute;
+
+-- +goose StatementEnd
@@ -0,0 +1,26 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- =============================================
+-- TOKEN RESERVATION EXPIRY
+-- =============================================
+
+-- Reservations still open after expires_at were abandoned, by a crash, a restart mid-stream or
+-- a failed settlement, and are released back to their api key by the reservation sweeper.
+ALTER TABLE agc.token_reservations ADD COLUMN expires_at TIMESTAMP WITH TIME ZONE NULL;
+
+UPDATE agc.token_reservations SET expires_at = created_at + INTERVAL '1 hour';
+
+ALTER TABLE agc.token_reservations ALTER COLUMN expires_at SET NOT NULL;
+
+CREATE INDEX IF NOT EXISTS token_reservations_expiry_idx ON agc.token_reservations (expires_at) WHERE status = 'reserved';
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+DROP INDEX IF EXISTS agc.token_reservations_expiry_idx;
+ALTER TABLE agc.token_reservations DROP COLUMN IF EXISTS expires_at;
+
+-- +goose StatementEnd
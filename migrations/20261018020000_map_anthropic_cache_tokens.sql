@@ -0,0 +1,25 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- =============================================
+-- ANTHROPIC PROMPT CACHING USAGE
+-- =============================================
+
+-- Anthropic reports input read from and written to the prompt cache apart from input_tokens.
+-- Both count towards prompt tokens, and reads are billed at the cached input price.
+UPDATE agc.providers SET
+  response_mapping = response_mapping || '{"cache_read_tokens": "$.usage.cache_read_input_tokens", "cache_write_tokens": "$.usage.cache_creation_input_tokens"}',
+  stream_mapping = stream_mapping || '{"cache_read_tokens": "$.message.usage.cache_read_input_tokens", "cache_write_tokens": "$.message.usage.cache_creation_input_tokens"}'
+WHERE name = 'Anthropic';
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+UPDATE agc.providers SET
+  response_mapping = response_mapping - 'cache_read_tokens' - 'cache_write_tokens',
+  stream_mapping = stream_mapping - 'cache_read_tokens' - 'cache_write_tokens'
+WHERE name = 'Anthropic';
+
+-- +goose StatementEnd